@@ -0,0 +1,142 @@
+// Package codec serializes API responses and request bodies as JSON, XML,
+// YAML or plain text.
+//
+// XML and YAML go through the JSON form of values, so they follow the same
+// field names and omissions, and are decoded guided by the Go type of the
+// target so that their untyped scalars get the JSON types it expects.
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Codec encodes and decodes values in a media type.
+type Codec interface {
+	MediaType() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+var JSON Codec = jsonCodec{}
+var XML Codec = xmlCodec{}
+var YAML Codec = yamlCodec{}
+var Text Codec = textCodec{}
+
+// ErrNoText is returned by Text for values without a plain text form.
+var ErrNoText = errors.New("no plain text form")
+
+var formats = map[string]Codec{
+	"json": JSON,
+	"xml":  XML,
+	"yaml": YAML,
+	"text": Text,
+}
+
+var mediaTypes = map[string]Codec{
+	"application/json":   JSON,
+	"application/xml":    XML,
+	"text/xml":           XML,
+	"application/yaml":   YAML,
+	"application/x-yaml": YAML,
+	"text/yaml":          YAML,
+	"text/plain":         Text,
+}
+
+// ByFormat returns the codec of a ?format= value: json, xml, yaml or text.
+func ByFormat(format string) (Codec, bool) {
+	c, ok := formats[strings.ToLower(format)]
+
+	return c, ok
+}
+
+// ByContentType returns the codec of a Content-Type header, JSON if empty.
+func ByContentType(contentType string) (Codec, bool) {
+	if contentType == "" {
+		return JSON, true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	if err != nil {
+		return nil, false
+	}
+
+	c, ok := mediaTypes[mediaType]
+
+	return c, ok
+}
+
+// Negotiate returns the codec of the supported media type the Accept header
+// prefers, JSON if the header is empty or accepts anything. It reports false
+// if no supported media type is acceptable.
+func Negotiate(accept string) (Codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return JSON, true
+	}
+
+	type ranged struct {
+		mediaType string
+		q         float64
+	}
+
+	var ranges []ranged
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		if q > 0 {
+			ranges = append(ranges, ranged{mediaType, q})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	for _, r := range ranges {
+		switch r.mediaType {
+		case "*/*", "application/*":
+			return JSON, true
+		case "text/*":
+			return Text, true
+		}
+
+		if c, ok := mediaTypes[r.mediaType]; ok {
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) MediaType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
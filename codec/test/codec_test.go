@@ -0,0 +1,178 @@
+package test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davq23/jokeapi/codec"
+	"github.com/davq23/jokeapi/data"
+)
+
+func testJoke() *data.Joke {
+	author := "3c1b0a3e-7e0e-4b3a-9d43-5c4b7f1f0d2e"
+	avg := 4.5
+
+	return &data.Joke{
+		ID:        "b8e0c7a4-3f27-4c59-8f38-2f0a7c1e9b6d",
+		AuthorID:  &author,
+		Type:      data.JokeTypeTwoPart,
+		Setup:     "Why did the chicken cross the road?",
+		Delivery:  "To get to the other side: #true story\n- really",
+		Language:  "en",
+		Flags:     data.JokeFlags{NSFW: true},
+		Status:    data.JokeStatusApproved,
+		Revision:  3,
+		AvgRating: &avg,
+		Reactions: map[string]uint64{"laugh": 2, "1st": 1},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, c := range []codec.Codec{codec.JSON, codec.XML, codec.YAML} {
+		var buf bytes.Buffer
+
+		if err := c.Encode(&buf, testJoke()); err != nil {
+			t.Fatal(c.MediaType(), err)
+		}
+
+		joke := &data.Joke{}
+
+		if err := c.Decode(&buf, joke); err != nil {
+			t.Fatal(c.MediaType(), err)
+		}
+
+		if !reflect.DeepEqual(joke, testJoke()) {
+			t.Errorf("%s: got %+v, want %+v", c.MediaType(), joke, testJoke())
+		}
+	}
+}
+
+func TestDecodeYAML(t *testing.T) {
+	doc := `# A new joke
+type: single
+text: |
+  Line one
+  Line two
+lang: "en"
+flags: {nsfw: true, political: false}
+`
+
+	joke := &data.Joke{}
+
+	if err := codec.YAML.Decode(strings.NewReader(doc), joke); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &data.Joke{
+		Type:     data.JokeTypeSingle,
+		Text:     "Line one\nLine two\n",
+		Language: "en",
+		Flags:    data.JokeFlags{NSFW: true},
+	}
+
+	if !reflect.DeepEqual(joke, want) {
+		t.Errorf("got %+v, want %+v", joke, want)
+	}
+
+	batch := &data.Batch{}
+	doc = `atomic: true
+operations:
+- method: POST
+  path: /jokes
+  body:
+    type: single
+    text: 42
+- method: DELETE
+  path: /jokes/1
+`
+
+	if err := codec.YAML.Decode(strings.NewReader(doc), batch); err != nil {
+		t.Fatal(err)
+	}
+
+	if !batch.Atomic || len(batch.Operations) != 2 || batch.Operations[1].Method != "DELETE" {
+		t.Fatalf("got %+v", batch)
+	}
+
+	body := map[string]interface{}{"type": "single", "text": 42.0}
+
+	if !reflect.DeepEqual(batch.Operations[0].Body, body) {
+		t.Errorf("got body %#v, want %#v", batch.Operations[0].Body, body)
+	}
+
+	doc = `operations:
+- &create {method: POST, path: /jokes, body: {type: single, text: "0x1F"}}
+- *create
+`
+
+	if err := codec.YAML.Decode(strings.NewReader(doc), batch); err != nil {
+		t.Fatal(err)
+	}
+
+	body = map[string]interface{}{"type": "single", "text": "0x1F"}
+
+	if len(batch.Operations) != 2 || !reflect.DeepEqual(batch.Operations[1].Body, body) {
+		t.Errorf("got %+v, want the aliased operation twice", batch.Operations)
+	}
+}
+
+func TestDecodeXML(t *testing.T) {
+	doc := `<joke><type>single</type><text>A &amp; B</text><lang>en</lang><flags><nsfw>true</nsfw></flags></joke>`
+
+	joke := &data.Joke{}
+
+	if err := codec.XML.Decode(strings.NewReader(doc), joke); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &data.Joke{Type: data.JokeTypeSingle, Text: "A & B", Language: "en", Flags: data.JokeFlags{NSFW: true}}
+
+	if !reflect.DeepEqual(joke, want) {
+		t.Errorf("got %+v, want %+v", joke, want)
+	}
+}
+
+func TestText(t *testing.T) {
+	var buf bytes.Buffer
+
+	qar := &data.QueryAllResponse{Results: data.Jokes{testJoke(), {Type: data.JokeTypeSingle, Text: "Single"}}}
+
+	if err := codec.Text.Encode(&buf, qar); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Why did the chicken cross the road?\nTo get to the other side: #true story\n- really\n\nSingle\n"
+
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	if err := codec.Text.Encode(&buf, &data.User{}); err != codec.ErrNoText {
+		t.Errorf("got %v, want ErrNoText", err)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   codec.Codec
+	}{
+		{"", codec.JSON},
+		{"*/*", codec.JSON},
+		{"application/xml", codec.XML},
+		{"application/json;q=0.5, application/yaml", codec.YAML},
+		{"text/html, text/*;q=0.1", codec.Text},
+		{"text/plain;q=0, application/*", codec.JSON},
+		{"image/png", nil},
+	}
+
+	for _, test := range tests {
+		got, ok := codec.Negotiate(test.accept)
+
+		if got != test.want || ok != (test.want != nil) {
+			t.Errorf("%q: got %v, %v, want %v", test.accept, got, ok, test.want)
+		}
+	}
+}
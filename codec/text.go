@@ -0,0 +1,62 @@
+package codec
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Texter is implemented by values that may have a plain text form, e.g.
+// jokes, which are just their text. PlainText reports false if there is none.
+type Texter interface {
+	PlainText() (string, bool)
+}
+
+// TextReader is implemented by values that can be read from plain text.
+type TextReader interface {
+	FromPlainText(text string) error
+}
+
+type textCodec struct{}
+
+func (textCodec) MediaType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (textCodec) Encode(w io.Writer, v interface{}) error {
+	t, ok := v.(Texter)
+
+	if !ok {
+		return ErrNoText
+	}
+
+	text, ok := t.PlainText()
+
+	if !ok {
+		return ErrNoText
+	}
+
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+
+	_, err := io.WriteString(w, text)
+
+	return err
+}
+
+func (textCodec) Decode(r io.Reader, v interface{}) error {
+	t, ok := v.(TextReader)
+
+	if !ok {
+		return ErrNoText
+	}
+
+	b, err := ioutil.ReadAll(r)
+
+	if err != nil {
+		return err
+	}
+
+	return t.FromPlainText(strings.TrimRight(string(b), "\r\n"))
+}
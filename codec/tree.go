@@ -0,0 +1,253 @@
+package codec
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type nodeKind int
+
+const (
+	nullNode nodeKind = iota
+	scalarNode
+	mappingNode
+	sequenceNode
+)
+
+// node is a document of any of the formats, keeping the order of mappings.
+type node struct {
+	kind  nodeKind
+	value string
+
+	// str is set for scalars that are strings in JSON, and plain for the ones
+	// of other formats whose type can be guessed from their text.
+	str   bool
+	plain bool
+
+	keys     []string
+	children []*node
+}
+
+// toTree returns the JSON form of v as a tree.
+func toTree(v interface{}) (*node, error) {
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	return readJSON(d)
+}
+
+func readJSON(d *json.Decoder) (*node, error) {
+	token, err := d.Token()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		n := &node{kind: sequenceNode}
+
+		if t == '{' {
+			n.kind = mappingNode
+		}
+
+		for d.More() {
+			if n.kind == mappingNode {
+				key, err := d.Token()
+
+				if err != nil {
+					return nil, err
+				}
+
+				n.keys = append(n.keys, key.(string))
+			}
+
+			child, err := readJSON(d)
+
+			if err != nil {
+				return nil, err
+			}
+
+			n.children = append(n.children, child)
+		}
+
+		// The closing delimiter.
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+
+		return n, nil
+	case string:
+		return &node{kind: scalarNode, value: t, str: true}, nil
+	case json.Number:
+		return &node{kind: scalarNode, value: t.String()}, nil
+	case bool:
+		return &node{kind: scalarNode, value: strconv.FormatBool(t)}, nil
+	}
+
+	return &node{kind: nullNode}, nil
+}
+
+// decodeTree decodes n into v through JSON, converting the scalars of n to
+// the types of the fields of v they end up in.
+func decodeTree(n *node, v interface{}) error {
+	b, err := json.Marshal(fromTree(n, reflect.TypeOf(v)))
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func fromTree(n *node, t reflect.Type) interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if n.kind == nullNode {
+		return nil
+	}
+
+	if t == nil || t.Kind() == reflect.Interface {
+		return guess(n)
+	}
+
+	if t == timeType || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return n.value
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := jsonFields(t)
+		m := map[string]interface{}{}
+
+		for i, key := range n.keys {
+			m[key] = fromTree(n.children[i], fields[key])
+		}
+
+		return m
+	case reflect.Map:
+		m := map[string]interface{}{}
+
+		for i, key := range n.keys {
+			m[key] = fromTree(n.children[i], t.Elem())
+		}
+
+		return m
+	case reflect.Slice, reflect.Array:
+		// XML has no sequences, only repeated elements.
+		items := make([]interface{}, 0, len(n.children))
+
+		for _, child := range n.children {
+			items = append(items, fromTree(child, t.Elem()))
+		}
+
+		return items
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(n.value); err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(n.value, 64); err == nil {
+			return json.Number(n.value)
+		}
+	}
+
+	if n.kind != scalarNode {
+		return guess(n)
+	}
+
+	return n.value
+}
+
+// guess converts n without knowing the type it's for.
+func guess(n *node) interface{} {
+	switch n.kind {
+	case mappingNode:
+		m := map[string]interface{}{}
+
+		for i, key := range n.keys {
+			m[key] = guess(n.children[i])
+		}
+
+		return m
+	case sequenceNode:
+		items := make([]interface{}, len(n.children))
+
+		for i, child := range n.children {
+			items[i] = guess(child)
+		}
+
+		return items
+	case nullNode:
+		return nil
+	}
+
+	if n.plain {
+		if b, err := strconv.ParseBool(n.value); err == nil && (n.value == "true" || n.value == "false") {
+			return b
+		}
+
+		if _, err := strconv.ParseFloat(n.value, 64); err == nil {
+			return json.Number(n.value)
+		}
+	}
+
+	return n.value
+}
+
+// jsonFields maps the JSON names of the fields of a struct type to their
+// types, including the fields of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+
+		if name == "-" || field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		embedded := field.Type
+
+		if embedded.Kind() == reflect.Ptr {
+			embedded = embedded.Elem()
+		}
+
+		if field.Anonymous && name == "" && embedded.Kind() == reflect.Struct {
+			for name, fieldType := range jsonFields(embedded) {
+				if _, ok := fields[name]; !ok {
+					fields[name] = fieldType
+				}
+			}
+
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fields[name] = field.Type
+	}
+
+	return fields
+}
@@ -0,0 +1,190 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// xmlCodec writes values in a <response> element, their fields as child
+// elements and the items of lists as <item> elements. Keys that aren't valid
+// element names are written as <entry key="...">.
+type xmlCodec struct{}
+
+func (xmlCodec) MediaType() string {
+	return "application/xml"
+}
+
+func (xmlCodec) Encode(w io.Writer, v interface{}) error {
+	n, err := toTree(v)
+
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+
+	e := xml.NewEncoder(bw)
+	e.Indent("", "  ")
+
+	if err = writeXML(e, xml.StartElement{Name: xml.Name{Local: "response"}}, n); err != nil {
+		return err
+	}
+
+	if err = e.Flush(); err != nil {
+		return err
+	}
+
+	bw.WriteString("\n")
+
+	return bw.Flush()
+}
+
+func writeXML(e *xml.Encoder, start xml.StartElement, n *node) error {
+	if n.kind == nullNode {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "nil"}, Value: "true"})
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch n.kind {
+	case scalarNode:
+		if err := e.EncodeToken(xml.CharData(n.value)); err != nil {
+			return err
+		}
+	case mappingNode:
+		for i, key := range n.keys {
+			child := xml.StartElement{Name: xml.Name{Local: key}}
+
+			if !validXMLName(key) {
+				child = xml.StartElement{
+					Name: xml.Name{Local: "entry"},
+					Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+				}
+			}
+
+			if err := writeXML(e, child, n.children[i]); err != nil {
+				return err
+			}
+		}
+	case sequenceNode:
+		for _, child := range n.children {
+			if err := writeXML(e, xml.StartElement{Name: xml.Name{Local: "item"}}, child); err != nil {
+				return err
+			}
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+func validXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+
+	for i, r := range name {
+		if unicode.IsLetter(r) || r == '_' {
+			continue
+		}
+
+		if i == 0 || !unicode.IsDigit(r) && r != '-' && r != '.' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (xmlCodec) Decode(r io.Reader, v interface{}) error {
+	d := xml.NewDecoder(r)
+
+	for {
+		token, err := d.Token()
+
+		if err == io.EOF {
+			return errors.New("xml: no root element")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			n, err := readXML(d, start)
+
+			if err != nil {
+				return err
+			}
+
+			return decodeTree(n, v)
+		}
+	}
+}
+
+// readXML reads the element of start. Elements with children are mappings,
+// or sequences if all of them are <item> elements, and the rest scalars.
+func readXML(d *xml.Decoder, start xml.StartElement) (*node, error) {
+	n := &node{kind: scalarNode, plain: true}
+	var text strings.Builder
+
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "nil" && attr.Value == "true" {
+			n.kind = nullNode
+		}
+	}
+
+	items := true
+
+	for {
+		token, err := d.Token()
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.StartElement:
+			child, err := readXML(d, t)
+
+			if err != nil {
+				return nil, err
+			}
+
+			key := t.Name.Local
+
+			for _, attr := range t.Attr {
+				if key == "entry" && attr.Name.Local == "key" {
+					key = attr.Value
+				}
+			}
+
+			items = items && key == "item"
+			n.keys = append(n.keys, key)
+			n.children = append(n.children, child)
+		case xml.EndElement:
+			if n.kind == nullNode {
+				return n, nil
+			}
+
+			if len(n.children) == 0 {
+				n.value = text.String()
+			} else if items {
+				n.kind = sequenceNode
+				n.keys = nil
+			} else {
+				n.kind = mappingNode
+			}
+
+			return n, nil
+		}
+	}
+}
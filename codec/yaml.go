@@ -0,0 +1,178 @@
+package codec
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlCodec reads and writes YAML with gopkg.in/yaml.v3, going through its
+// nodes so that mappings keep their order and scalars are converted to the
+// types of the fields they end up in like with the other formats.
+type yamlCodec struct{}
+
+func (yamlCodec) MediaType() string {
+	return "application/yaml"
+}
+
+func (yamlCodec) Encode(w io.Writer, v interface{}) error {
+	n, err := toTree(v)
+
+	if err != nil {
+		return err
+	}
+
+	e := yaml.NewEncoder(w)
+	e.SetIndent(2)
+
+	if err = e.Encode(toYAML(n)); err != nil {
+		return err
+	}
+
+	return e.Close()
+}
+
+func toYAML(n *node) *yaml.Node {
+	switch n.kind {
+	case nullNode:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case mappingNode:
+		yn := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+
+		for i, key := range n.keys {
+			yn.Content = append(yn.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, toYAML(n.children[i]))
+		}
+
+		return yn
+	case sequenceNode:
+		yn := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+
+		for _, child := range n.children {
+			yn.Content = append(yn.Content, toYAML(child))
+		}
+
+		return yn
+	}
+
+	// Strings are quoted if they'd read as something else.
+	if n.str {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: n.value}
+	}
+
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: n.value}
+}
+
+func (yamlCodec) Decode(r io.Reader, v interface{}) error {
+	var doc yaml.Node
+
+	err := yaml.NewDecoder(r).Decode(&doc)
+
+	if err == io.EOF {
+		return decodeTree(&node{kind: nullNode}, v)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	n, err := fromYAML(&doc)
+
+	if err != nil {
+		return err
+	}
+
+	return decodeTree(n, v)
+}
+
+func fromYAML(yn *yaml.Node) (*node, error) {
+	switch yn.Kind {
+	case yaml.DocumentNode:
+		if len(yn.Content) == 0 {
+			return &node{kind: nullNode}, nil
+		}
+
+		return fromYAML(yn.Content[0])
+	case yaml.AliasNode:
+		return fromYAML(yn.Alias)
+	case yaml.MappingNode:
+		n := &node{kind: mappingNode}
+
+		for i := 0; i+1 < len(yn.Content); i += 2 {
+			if yn.Content[i].Kind != yaml.ScalarNode {
+				return nil, errors.New("yaml: mapping keys must be scalars")
+			}
+
+			child, err := fromYAML(yn.Content[i+1])
+
+			if err != nil {
+				return nil, err
+			}
+
+			n.keys = append(n.keys, yn.Content[i].Value)
+			n.children = append(n.children, child)
+		}
+
+		return n, nil
+	case yaml.SequenceNode:
+		n := &node{kind: sequenceNode}
+
+		for _, item := range yn.Content {
+			child, err := fromYAML(item)
+
+			if err != nil {
+				return nil, err
+			}
+
+			n.children = append(n.children, child)
+		}
+
+		return n, nil
+	}
+
+	return fromYAMLScalar(yn)
+}
+
+// fromYAMLScalar converts the YAML spellings of nulls, booleans and numbers,
+// e.g. ~, True or 0x1F, to the JSON ones the tree holds.
+func fromYAMLScalar(yn *yaml.Node) (*node, error) {
+	n := &node{kind: scalarNode, value: yn.Value}
+
+	switch yn.ShortTag() {
+	case "!!null":
+		n.kind = nullNode
+	case "!!str", "!!binary", "!!timestamp":
+		n.str = true
+	case "!!bool":
+		var b bool
+
+		if err := yn.Decode(&b); err != nil {
+			return nil, err
+		}
+
+		n.value, n.plain = strconv.FormatBool(b), true
+	case "!!int":
+		var i int64
+
+		if err := yn.Decode(&i); err == nil {
+			n.value = strconv.FormatInt(i, 10)
+		}
+
+		n.plain = true
+	case "!!float":
+		var f float64
+
+		if err := yn.Decode(&f); err != nil {
+			return nil, err
+		}
+
+		// JSON has no infinities or NaN.
+		if !math.IsInf(f, 0) && !math.IsNaN(f) {
+			n.value, n.plain = strconv.FormatFloat(f, 'g', -1, 64), true
+		}
+	}
+
+	return n, nil
+}
@@ -1,5 +1,14 @@
 package config
 
+import "time"
+
 type Config struct {
-	DBConnectionURI string
+	DBConnectionURI     string
+	TrashRetention      time.Duration
+	PurgeInterval       time.Duration
+	LeaderboardInterval time.Duration
+	ReportHideThreshold uint64
+	LegacySunset        time.Time
+	RequestTimeout      time.Duration
+	StreamTimeout       time.Duration
 }
@@ -3,22 +3,30 @@ package config
 import (
 	"context"
 	"database/sql"
+	"strings"
 
+	"github.com/davq23/jokeapi/data"
 	"github.com/jmoiron/sqlx"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func MongoDBMigration(ctx context.Context, db *mongo.Database) (jc, uc *mongo.Collection) {
+func MongoDBMigration(ctx context.Context, db *mongo.Database) (jc, uc, cc *mongo.Collection) {
 	var unique bool = true
 
 	jc = db.Collection("jokes")
 	uc = db.Collection("users")
+	cc = db.Collection("collections")
 
-	(*uc).Indexes().CreateOne(context.Background(), mongo.IndexModel{Keys: bson.M{
-		"email": 1,
-	}})
+	(*uc).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys: bson.M{"email": 1},
+		},
+		{
+			Keys: bson.M{"deleted_at": 1},
+		},
+	})
 
 	(*jc).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
 		{
@@ -33,8 +41,143 @@ func MongoDBMigration(ctx context.Context, db *mongo.Database) (jc, uc *mongo.Co
 			Keys:    bson.M{"lang": 1},
 			Options: &options.IndexOptions{},
 		},
+		{
+			Keys:    bson.M{"type": 1},
+			Options: &options.IndexOptions{},
+		},
+		{
+			Keys:    bson.M{"status": 1},
+			Options: &options.IndexOptions{},
+		},
+		{
+			Keys:    bson.M{"deleted_at": 1},
+			Options: &options.IndexOptions{},
+		},
+		{
+			Keys:    bson.D{{Key: "id", Value: 1}, {Key: "ratings.user_id", Value: 1}},
+			Options: &options.IndexOptions{},
+		},
+		{
+			Keys:    bson.D{{Key: "score", Value: -1}, {Key: "id", Value: 1}},
+			Options: &options.IndexOptions{},
+		},
+		{
+			Keys:    bson.M{"ratings.rated_at": 1},
+			Options: &options.IndexOptions{},
+		},
+	})
+
+	for _, kind := range data.JokeReactionKinds {
+		(*jc).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "reactions." + kind, Value: -1}, {Key: "id", Value: 1}},
+		})
+
+		(*jc).UpdateMany(ctx, bson.M{"reactions." + kind: bson.M{"$exists": false}}, bson.M{"$set": bson.M{
+			"reactions." + kind: 0,
+		}})
+	}
+
+	db.Collection("joke_reactions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "joke_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "kind", Value: 1}},
+		Options: &options.IndexOptions{
+			Unique: &unique,
+		},
+	})
+
+	db.Collection("joke_revisions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "joke_id", Value: 1}, {Key: "revision", Value: 1}},
+		Options: &options.IndexOptions{
+			Unique: &unique,
+		},
+	})
+
+	db.Collection("joke_favorites").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "joke_id", Value: 1}},
+			Options: &options.IndexOptions{
+				Unique: &unique,
+			},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: 1}, {Key: "id", Value: 1}},
+		},
+	})
+
+	db.Collection("joke_comments").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.M{"id": 1},
+			Options: &options.IndexOptions{
+				Unique: &unique,
+			},
+		},
+		{
+			Keys: bson.D{{Key: "joke_id", Value: 1}, {Key: "parent_id", Value: 1}, {Key: "created_at", Value: 1}, {Key: "id", Value: 1}},
+		},
+	})
+
+	db.Collection("reports").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.M{"id": 1},
+			Options: &options.IndexOptions{
+				Unique: &unique,
+			},
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "joke_id", Value: 1}, {Key: "comment_id", Value: 1}, {Key: "status", Value: 1}},
+		},
+	})
+
+	(*cc).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.M{"id": 1},
+			Options: &options.IndexOptions{
+				Unique: &unique,
+			},
+		},
+		{
+			Keys: bson.M{"slug": 1},
+			Options: &options.IndexOptions{
+				Unique: &unique,
+			},
+		},
+		{
+			Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "id", Value: 1}},
+		},
 	})
 
+	(*jc).UpdateMany(ctx, bson.M{"type": bson.M{"$exists": false}}, bson.M{"$set": bson.M{
+		"type": data.JokeTypeSingle,
+	}})
+
+	(*jc).UpdateMany(ctx, bson.M{"status": bson.M{"$exists": false}}, bson.M{"$set": bson.M{
+		"status": data.JokeStatusApproved,
+	}})
+
+	if cursor, err := (*jc).Find(ctx, bson.M{"rating_count": bson.M{"$exists": false}}); err == nil {
+		for cursor.Next(ctx) {
+			joke := new(data.Joke)
+
+			if err = cursor.Decode(joke); err != nil {
+				continue
+			}
+
+			joke.ComputeRatingAggregates()
+
+			(*jc).UpdateOne(ctx, bson.M{"id": joke.ID}, bson.M{"$set": bson.M{
+				"rating_count":     joke.RatingCount,
+				"rating_sum":       joke.RatingSum,
+				"rating_histogram": joke.RatingHistogram,
+				"score":            joke.Score,
+				"avgRating":        joke.AvgRating,
+			}})
+		}
+
+		cursor.Close(ctx)
+	}
+
 	return
 }
 
@@ -57,10 +200,147 @@ func PostGreSQLMigration(ctx context.Context, db *sqlx.DB) error {
 
 	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS jokes (
 		id BIGSERIAL PRIMARY KEY,
+		type VARCHAR(7) NOT NULL DEFAULT 'single',
 		text VARCHAR(255),
+		setup VARCHAR(255) DEFAULT NULL,
+		delivery VARCHAR(255) DEFAULT NULL,
 		author_id BIGSERIAL DEFAULT NULL,
 		explanation VARCHAR(255) DEFAULT NULL,
-		lang VARCHAR(3) NOT NULL
+		lang VARCHAR(3) NOT NULL,
+		flag_nsfw BOOLEAN NOT NULL DEFAULT FALSE,
+		flag_religious BOOLEAN NOT NULL DEFAULT FALSE,
+		flag_political BOOLEAN NOT NULL DEFAULT FALSE,
+		flag_racist BOOLEAN NOT NULL DEFAULT FALSE,
+		flag_sexist BOOLEAN NOT NULL DEFAULT FALSE,
+		flag_explicit BOOLEAN NOT NULL DEFAULT FALSE,
+		status VARCHAR(8) NOT NULL DEFAULT 'approved',
+		rejection_reason VARCHAR(255) DEFAULT NULL,
+		revision BIGINT NOT NULL DEFAULT 0,
+		deleted_at TIMESTAMP NULL DEFAULT NULL,
+		deleted_by VARCHAR(36) DEFAULT NULL,
+		rating_count BIGINT NOT NULL DEFAULT 0,
+		rating_sum DOUBLE PRECISION NOT NULL DEFAULT 0,
+		avg_rating DOUBLE PRECISION NOT NULL DEFAULT 0,
+		score DOUBLE PRECISION NOT NULL DEFAULT 3,
+		rating_1 BIGINT NOT NULL DEFAULT 0,
+		rating_2 BIGINT NOT NULL DEFAULT 0,
+		rating_3 BIGINT NOT NULL DEFAULT 0,
+		rating_4 BIGINT NOT NULL DEFAULT 0,
+		rating_5 BIGINT NOT NULL DEFAULT 0,
+		favorite_count BIGINT NOT NULL DEFAULT 0,
+		comment_count BIGINT NOT NULL DEFAULT 0,
+		report_count BIGINT NOT NULL DEFAULT 0,
+		hidden BOOLEAN NOT NULL DEFAULT FALSE,
+		reaction_laugh BIGINT NOT NULL DEFAULT 0,
+		reaction_groan BIGINT NOT NULL DEFAULT 0,
+		reaction_facepalm BIGINT NOT NULL DEFAULT 0,
+		reaction_clap BIGINT NOT NULL DEFAULT 0,
+		reaction_confused BIGINT NOT NULL DEFAULT 0
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS joke_ratings (
+		id CHAR(36) PRIMARY KEY,
+		user_id VARCHAR(36) DEFAULT NULL,
+		rating DECIMAL(4,2),
+		rated_at TIMESTAMP NULL DEFAULT NULL,
+		joke_id VARCHAR(36),
+		CONSTRAINT unique_joke_rating_user UNIQUE (joke_id, user_id)
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS joke_revisions (
+		id CHAR(36) PRIMARY KEY,
+		joke_id VARCHAR(36) NOT NULL,
+		revision BIGINT NOT NULL,
+		editor_id VARCHAR(36) DEFAULT NULL,
+		created_at TIMESTAMP NOT NULL,
+		changes TEXT NOT NULL,
+		CONSTRAINT unique_joke_revision UNIQUE (joke_id, revision)
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS joke_favorites (
+		id CHAR(36) PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL,
+		joke_id VARCHAR(36) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		CONSTRAINT unique_joke_favorite_user UNIQUE (user_id, joke_id)
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS joke_comments (
+		id CHAR(36) PRIMARY KEY,
+		joke_id VARCHAR(36) NOT NULL,
+		parent_id CHAR(36) DEFAULT NULL,
+		author_id VARCHAR(36) NOT NULL,
+		text VARCHAR(1000) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NULL DEFAULT NULL,
+		removed_at TIMESTAMP NULL DEFAULT NULL,
+		removed_by VARCHAR(36) DEFAULT NULL
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS joke_reactions (
+		joke_id VARCHAR(36) NOT NULL,
+		user_id VARCHAR(36) NOT NULL,
+		kind VARCHAR(10) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (joke_id, user_id, kind)
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS reports (
+		id CHAR(36) PRIMARY KEY,
+		target_type VARCHAR(7) NOT NULL,
+		joke_id VARCHAR(36) NOT NULL,
+		comment_id CHAR(36) DEFAULT NULL,
+		reporter_id VARCHAR(36) NOT NULL,
+		reason VARCHAR(10) NOT NULL,
+		note VARCHAR(500) DEFAULT NULL,
+		status VARCHAR(9) NOT NULL DEFAULT 'open',
+		created_at TIMESTAMP NOT NULL,
+		resolved_at TIMESTAMP NULL DEFAULT NULL,
+		resolved_by VARCHAR(36) DEFAULT NULL,
+		resolution_note VARCHAR(500) DEFAULT NULL
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS collections (
+		id CHAR(36) PRIMARY KEY,
+		owner_id VARCHAR(36) NOT NULL,
+		name VARCHAR(120) NOT NULL,
+		description VARCHAR(255) DEFAULT NULL,
+		visibility VARCHAR(8) NOT NULL DEFAULT 'private',
+		slug VARCHAR(64) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		CONSTRAINT unique_collection_slug UNIQUE (slug)
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS collection_jokes (
+		collection_id CHAR(36) NOT NULL,
+		joke_id VARCHAR(36) NOT NULL,
+		position INT NOT NULL,
+		PRIMARY KEY (collection_id, joke_id)
 	)`); err != nil {
 		tx.Rollback()
 		return err
@@ -70,12 +350,20 @@ func PostGreSQLMigration(ctx context.Context, db *sqlx.DB) error {
 		id BIGSERIAL PRIMARY KEY,
 		email VARCHAR(120),
 		password VARCHAR(255),
+		admin BOOLEAN NOT NULL DEFAULT FALSE,
+		deleted_at TIMESTAMP NULL DEFAULT NULL,
+		deleted_by VARCHAR(36) DEFAULT NULL,
 		CONSTRAINT unique_email UNIQUE (email)
 	)`); err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	if err = upgradeSQLTables(ctx, db, tx, "current_schema()"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	err = tx.Commit()
 
 	return err
@@ -100,10 +388,55 @@ func MySQLMigration(ctx context.Context, db *sqlx.DB) error {
 
 	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS jokes (
 		id CHAR(36) PRIMARY KEY,
+		type VARCHAR(7) NOT NULL DEFAULT 'single',
 		text VARCHAR(255),
+		setup VARCHAR(255) DEFAULT NULL,
+		delivery VARCHAR(255) DEFAULT NULL,
 		author_id VARCHAR(36) DEFAULT NULL,
 		explanation VARCHAR(255) DEFAULT NULL,
-		lang VARCHAR(3) NOT NULL
+		lang VARCHAR(3) NOT NULL,
+		flag_nsfw BOOLEAN NOT NULL DEFAULT FALSE,
+		flag_religious BOOLEAN NOT NULL DEFAULT FALSE,
+		flag_political BOOLEAN NOT NULL DEFAULT FALSE,
+		flag_racist BOOLEAN NOT NULL DEFAULT FALSE,
+		flag_sexist BOOLEAN NOT NULL DEFAULT FALSE,
+		flag_explicit BOOLEAN NOT NULL DEFAULT FALSE,
+		status VARCHAR(8) NOT NULL DEFAULT 'approved',
+		rejection_reason VARCHAR(255) DEFAULT NULL,
+		revision BIGINT NOT NULL DEFAULT 0,
+		deleted_at TIMESTAMP NULL DEFAULT NULL,
+		deleted_by VARCHAR(36) DEFAULT NULL,
+		rating_count BIGINT NOT NULL DEFAULT 0,
+		rating_sum DOUBLE PRECISION NOT NULL DEFAULT 0,
+		avg_rating DOUBLE PRECISION NOT NULL DEFAULT 0,
+		score DOUBLE PRECISION NOT NULL DEFAULT 3,
+		rating_1 BIGINT NOT NULL DEFAULT 0,
+		rating_2 BIGINT NOT NULL DEFAULT 0,
+		rating_3 BIGINT NOT NULL DEFAULT 0,
+		rating_4 BIGINT NOT NULL DEFAULT 0,
+		rating_5 BIGINT NOT NULL DEFAULT 0,
+		favorite_count BIGINT NOT NULL DEFAULT 0,
+		comment_count BIGINT NOT NULL DEFAULT 0,
+		report_count BIGINT NOT NULL DEFAULT 0,
+		hidden BOOLEAN NOT NULL DEFAULT FALSE,
+		reaction_laugh BIGINT NOT NULL DEFAULT 0,
+		reaction_groan BIGINT NOT NULL DEFAULT 0,
+		reaction_facepalm BIGINT NOT NULL DEFAULT 0,
+		reaction_clap BIGINT NOT NULL DEFAULT 0,
+		reaction_confused BIGINT NOT NULL DEFAULT 0
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS joke_revisions (
+		id CHAR(36) PRIMARY KEY,
+		joke_id VARCHAR(36) NOT NULL,
+		revision BIGINT NOT NULL,
+		editor_id VARCHAR(36) DEFAULT NULL,
+		created_at TIMESTAMP NOT NULL,
+		changes TEXT NOT NULL,
+		CONSTRAINT unique_joke_revision UNIQUE (joke_id, revision)
 	)`); err != nil {
 		tx.Rollback()
 		return err
@@ -111,8 +444,91 @@ func MySQLMigration(ctx context.Context, db *sqlx.DB) error {
 
 	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS joke_ratings (
 		id CHAR(36) PRIMARY KEY,
+		user_id VARCHAR(36) DEFAULT NULL,
 		rating DECIMAL(4,2),
-		joke_id VARCHAR(36)
+		rated_at TIMESTAMP NULL DEFAULT NULL,
+		joke_id VARCHAR(36),
+		CONSTRAINT unique_joke_rating_user UNIQUE (joke_id, user_id)
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS joke_favorites (
+		id CHAR(36) PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL,
+		joke_id VARCHAR(36) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		CONSTRAINT unique_joke_favorite_user UNIQUE (user_id, joke_id)
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS joke_comments (
+		id CHAR(36) PRIMARY KEY,
+		joke_id VARCHAR(36) NOT NULL,
+		parent_id CHAR(36) DEFAULT NULL,
+		author_id VARCHAR(36) NOT NULL,
+		text VARCHAR(1000) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NULL DEFAULT NULL,
+		removed_at TIMESTAMP NULL DEFAULT NULL,
+		removed_by VARCHAR(36) DEFAULT NULL
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS joke_reactions (
+		joke_id VARCHAR(36) NOT NULL,
+		user_id VARCHAR(36) NOT NULL,
+		kind VARCHAR(10) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (joke_id, user_id, kind)
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS reports (
+		id CHAR(36) PRIMARY KEY,
+		target_type VARCHAR(7) NOT NULL,
+		joke_id VARCHAR(36) NOT NULL,
+		comment_id CHAR(36) DEFAULT NULL,
+		reporter_id VARCHAR(36) NOT NULL,
+		reason VARCHAR(10) NOT NULL,
+		note VARCHAR(500) DEFAULT NULL,
+		status VARCHAR(9) NOT NULL DEFAULT 'open',
+		created_at TIMESTAMP NOT NULL,
+		resolved_at TIMESTAMP NULL DEFAULT NULL,
+		resolved_by VARCHAR(36) DEFAULT NULL,
+		resolution_note VARCHAR(500) DEFAULT NULL
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS collections (
+		id CHAR(36) PRIMARY KEY,
+		owner_id VARCHAR(36) NOT NULL,
+		name VARCHAR(120) NOT NULL,
+		description VARCHAR(255) DEFAULT NULL,
+		visibility VARCHAR(8) NOT NULL DEFAULT 'private',
+		slug VARCHAR(64) NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		CONSTRAINT unique_collection_slug UNIQUE (slug)
+	)`); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec(`CREATE TABLE IF NOT EXISTS collection_jokes (
+		collection_id CHAR(36) NOT NULL,
+		joke_id VARCHAR(36) NOT NULL,
+		position INT NOT NULL,
+		PRIMARY KEY (collection_id, joke_id)
 	)`); err != nil {
 		tx.Rollback()
 		return err
@@ -122,13 +538,167 @@ func MySQLMigration(ctx context.Context, db *sqlx.DB) error {
 		id CHAR(36) PRIMARY KEY,
 		email VARCHAR(120),
 		password VARCHAR(255),
+		admin BOOLEAN NOT NULL DEFAULT FALSE,
+		deleted_at TIMESTAMP NULL DEFAULT NULL,
+		deleted_by VARCHAR(36) DEFAULT NULL,
 		CONSTRAINT unique_id UNIQUE (id)
 	)`); err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	if err = upgradeSQLTables(ctx, db, tx, "DATABASE()"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	err = tx.Commit()
 
 	return err
 }
+
+// sqlColumn is a column added to a table after its first release, along with
+// the definition it's added with.
+type sqlColumn struct {
+	table      string
+	name       string
+	definition string
+}
+
+var addedSQLColumns = []sqlColumn{
+	{"jokes", "type", "VARCHAR(7) NOT NULL DEFAULT 'single'"},
+	{"jokes", "setup", "VARCHAR(255) DEFAULT NULL"},
+	{"jokes", "delivery", "VARCHAR(255) DEFAULT NULL"},
+	{"jokes", "flag_nsfw", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"jokes", "flag_religious", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"jokes", "flag_political", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"jokes", "flag_racist", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"jokes", "flag_sexist", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"jokes", "flag_explicit", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"jokes", "status", "VARCHAR(8) NOT NULL DEFAULT 'approved'"},
+	{"jokes", "rejection_reason", "VARCHAR(255) DEFAULT NULL"},
+	{"jokes", "revision", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "deleted_at", "TIMESTAMP NULL DEFAULT NULL"},
+	{"jokes", "deleted_by", "VARCHAR(36) DEFAULT NULL"},
+	{"jokes", "rating_count", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "rating_sum", "DOUBLE PRECISION NOT NULL DEFAULT 0"},
+	{"jokes", "avg_rating", "DOUBLE PRECISION NOT NULL DEFAULT 0"},
+	{"jokes", "score", "DOUBLE PRECISION NOT NULL DEFAULT 3"},
+	{"jokes", "rating_1", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "rating_2", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "rating_3", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "rating_4", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "rating_5", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "favorite_count", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "comment_count", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "report_count", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "hidden", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"jokes", "reaction_laugh", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "reaction_groan", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "reaction_facepalm", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "reaction_clap", "BIGINT NOT NULL DEFAULT 0"},
+	{"jokes", "reaction_confused", "BIGINT NOT NULL DEFAULT 0"},
+	{"joke_ratings", "user_id", "VARCHAR(36) DEFAULT NULL"},
+	{"joke_ratings", "rated_at", "TIMESTAMP NULL DEFAULT NULL"},
+	{"users", "admin", "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{"users", "deleted_at", "TIMESTAMP NULL DEFAULT NULL"},
+	{"users", "deleted_by", "VARCHAR(36) DEFAULT NULL"},
+}
+
+// upgradeSQLTables adds the columns tables created by earlier releases lack,
+// in the schema named by the SQL expression schema, and fills in the rating
+// aggregates of the jokes they already had. Tables created by this release
+// are left as they are.
+func upgradeSQLTables(ctx context.Context, db *sqlx.DB, tx *sql.Tx, schema string) error {
+	existing := make(map[string]bool)
+
+	rows, err := tx.QueryContext(ctx, "SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = "+schema)
+
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var table, column string
+
+		if err = rows.Scan(&table, &column); err != nil {
+			rows.Close()
+			return err
+		}
+
+		existing[strings.ToLower(table)+"."+strings.ToLower(column)] = true
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	added := make(map[string]bool)
+
+	for _, column := range addedSQLColumns {
+		if existing[column.table+"."+column.name] {
+			continue
+		}
+
+		if _, err = tx.ExecContext(ctx, "ALTER TABLE "+column.table+" ADD COLUMN "+column.name+" "+column.definition); err != nil {
+			return err
+		}
+
+		added[column.table+"."+column.name] = true
+	}
+
+	if !existing["joke_ratings.user_id"] {
+		var constraints int
+
+		row := tx.QueryRowContext(ctx, db.Rebind("SELECT COUNT(*) FROM information_schema.table_constraints "+
+			"WHERE table_schema = "+schema+" AND table_name = 'joke_ratings' AND constraint_name = ?"), "unique_joke_rating_user")
+
+		if err = row.Scan(&constraints); err != nil {
+			return err
+		}
+
+		if constraints == 0 {
+			if _, err = tx.ExecContext(ctx, "ALTER TABLE joke_ratings ADD CONSTRAINT unique_joke_rating_user UNIQUE (joke_id, user_id)"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if added["jokes.rating_count"] {
+		_, err = tx.ExecContext(ctx, "UPDATE jokes SET "+
+			"rating_count = (SELECT COUNT(*) FROM joke_ratings r WHERE r.joke_id = jokes.id), "+
+			"rating_sum = (SELECT COALESCE(SUM(r.rating), 0) FROM joke_ratings r WHERE r.joke_id = jokes.id)")
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, db.Rebind("UPDATE jokes SET "+
+			"avg_rating = CASE WHEN rating_count > 0 THEN rating_sum / rating_count ELSE 0 END, "+
+			"score = (? * ? + rating_sum) / (? + rating_count)"),
+			data.RatingPriorWeight, data.RatingPriorMean, data.RatingPriorWeight)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// The buckets follow data.RatingBucket: ratings up to 1 in the first one,
+	// and up to n but above n-1 in the nth one.
+	if added["jokes.rating_1"] {
+		_, err = tx.ExecContext(ctx, "UPDATE jokes SET "+
+			"rating_1 = (SELECT COUNT(*) FROM joke_ratings r WHERE r.joke_id = jokes.id AND r.rating <= 1), "+
+			"rating_2 = (SELECT COUNT(*) FROM joke_ratings r WHERE r.joke_id = jokes.id AND r.rating > 1 AND r.rating <= 2), "+
+			"rating_3 = (SELECT COUNT(*) FROM joke_ratings r WHERE r.joke_id = jokes.id AND r.rating > 2 AND r.rating <= 3), "+
+			"rating_4 = (SELECT COUNT(*) FROM joke_ratings r WHERE r.joke_id = jokes.id AND r.rating > 3 AND r.rating <= 4), "+
+			"rating_5 = (SELECT COUNT(*) FROM joke_ratings r WHERE r.joke_id = jokes.id AND r.rating > 4)")
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
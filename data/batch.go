@@ -0,0 +1,69 @@
+package data
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Batch is a list of API requests run one after the other. Atomic batches run
+// in a single transaction and stop at the first failed operation, undoing the
+// ones before it.
+type Batch struct {
+	Atomic     bool              `json:"atomic"`
+	Operations []*BatchOperation `json:"operations" validate:"required,min=1,max=50,dive"`
+}
+
+// BatchOperation is a request of a batch. Path is relative to the API
+// version of the batch, e.g. /jokes/{id}, and the body is sent as JSON.
+type BatchOperation struct {
+	ID      string            `json:"id,omitempty"`
+	Method  string            `json:"method" validate:"required,oneof=GET POST PUT PATCH DELETE"`
+	Path    string            `json:"path" validate:"required,startswith=/"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+}
+
+// BatchResult is the response to a batch operation. Operations an atomic
+// batch didn't get to have status 424.
+type BatchResult struct {
+	ID     string      `json:"id,omitempty"`
+	Status int         `json:"status"`
+	Body   interface{} `json:"body,omitempty"`
+}
+
+type BatchResponse struct {
+	// Committed reports whether the changes of the operations were kept,
+	// which they always are for batches that aren't atomic.
+	Committed bool           `json:"committed"`
+	Results   []*BatchResult `json:"results"`
+}
+
+func (b *Batch) GetID() (string, error) {
+	return "", ErrNoID
+}
+
+func (b *Batch) GenerateID() error {
+	return nil
+}
+
+func (b *Batch) SetID(id string) {}
+
+func (b *Batch) CheckValidID(id string) error {
+	return ErrInvalidID
+}
+
+func (b *Batch) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(b)
+}
+
+func (b *Batch) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(b)
+}
+
+func (br *BatchResponse) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(br)
+}
+
+func (br *BatchResponse) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(br)
+}
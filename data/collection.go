@@ -0,0 +1,209 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const CollectionPrivate = "private"
+const CollectionUnlisted = "unlisted"
+const CollectionPublic = "public"
+
+var ErrInvalidOrder = errors.New("order must contain every joke of the collection exactly once")
+
+type Collection struct {
+	ID          string    `json:"collection_id" db:"id" bson:"id"`
+	OwnerID     string    `json:"owner_id" db:"owner_id" bson:"owner_id"`
+	Name        string    `json:"name" db:"name" bson:"name" validate:"required,max=120"`
+	Description string    `json:"description,omitempty" db:"description" bson:"description,omitempty" validate:"max=255"`
+	Visibility  string    `json:"visibility" db:"visibility" bson:"visibility" validate:"required,oneof=private unlisted public"`
+	Slug        string    `json:"slug" db:"slug" bson:"slug"`
+	JokeIDs     []string  `json:"joke_ids" db:"-" bson:"joke_ids" validate:"max=500,unique,dive,uuid"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at" bson:"updated_at"`
+	Jokes       Jokes     `json:"jokes,omitempty" db:"-" bson:"-"`
+}
+
+func (c *Collection) GetID() (string, error) {
+	if c.ID == "" {
+		return "", ErrNoID
+	}
+
+	if _, err := uuid.Parse(c.ID); err != nil {
+		return c.ID, ErrInvalidID
+	}
+
+	return c.ID, nil
+}
+
+func (c *Collection) GenerateID() error {
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return err
+	}
+
+	c.ID = id.String()
+
+	return nil
+}
+
+func (c *Collection) SetID(id string) {
+	c.ID = id
+}
+
+func (c *Collection) CheckValidID(id string) error {
+	_, err := uuid.Parse(id)
+
+	return err
+}
+
+// GenerateSlug derives the share link slug from the collection name plus a
+// random suffix, so that unlisted collections can't be guessed from their name.
+func (c *Collection) GenerateSlug() error {
+	suffix, err := uuid.NewRandom()
+
+	if err != nil {
+		return err
+	}
+
+	slug := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+
+		return '-'
+	}, strings.ToLower(c.Name))
+
+	slug = strings.Join(strings.FieldsFunc(slug, func(r rune) bool { return r == '-' }), "-")
+
+	if len(slug) > 48 {
+		slug = strings.TrimRight(slug[:48], "-")
+	}
+
+	if slug != "" {
+		slug += "-"
+	}
+
+	c.Slug = slug + strings.ReplaceAll(suffix.String(), "-", "")[:12]
+
+	return nil
+}
+
+// Reorder replaces JokeIDs with ids, which must be a permutation of them.
+func (c *Collection) Reorder(ids []string) error {
+	if len(ids) != len(c.JokeIDs) {
+		return ErrInvalidOrder
+	}
+
+	positions := make(map[string]bool, len(c.JokeIDs))
+
+	for _, id := range c.JokeIDs {
+		positions[id] = true
+	}
+
+	for _, id := range ids {
+		if !positions[id] {
+			return ErrInvalidOrder
+		}
+
+		delete(positions, id)
+	}
+
+	c.JokeIDs = ids
+
+	return nil
+}
+
+func (c *Collection) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(c)
+}
+
+func (c *Collection) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(c)
+}
+
+func (c *Collection) Shape(version int) interface{} {
+	var jokes interface{}
+
+	if c.Jokes != nil {
+		jokes = c.Jokes.Shape(version)
+	}
+
+	return &struct {
+		*Collection
+		Jokes interface{} `json:"jokes,omitempty"`
+	}{c, jokes}
+}
+
+// PlainText returns the jokes of the collection, if they were fetched.
+func (c *Collection) PlainText() (string, bool) {
+	if c.Jokes == nil {
+		return "", false
+	}
+
+	return c.Jokes.PlainText()
+}
+
+type Collections []*Collection
+
+func (c *Collections) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(c)
+}
+
+func (c *Collections) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(c)
+}
+
+// CollectionOrder is the payload to reorder the jokes of a collection.
+type CollectionOrder struct {
+	CollectionID string   `json:"-"`
+	JokeIDs      []string `json:"joke_ids" validate:"required"`
+}
+
+func (co *CollectionOrder) GetID() (string, error) {
+	if co.CollectionID == "" {
+		return "", ErrNoID
+	}
+
+	if _, err := uuid.Parse(co.CollectionID); err != nil {
+		return co.CollectionID, ErrInvalidID
+	}
+
+	return co.CollectionID, nil
+}
+
+func (co *CollectionOrder) GenerateID() error {
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return err
+	}
+
+	co.CollectionID = id.String()
+
+	return nil
+}
+
+func (co *CollectionOrder) SetID(id string) {
+	co.CollectionID = id
+}
+
+func (co *CollectionOrder) CheckValidID(id string) error {
+	_, err := uuid.Parse(id)
+
+	return err
+}
+
+func (co *CollectionOrder) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(co)
+}
+
+func (co *CollectionOrder) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(co)
+}
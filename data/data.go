@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+
+	"github.com/davq23/jokeapi/codec"
 )
 
 type IDValidation func(string) error
@@ -17,6 +19,12 @@ type Data interface {
 	SetID(string)
 }
 
+// Defaulter is implemented by data with fields that take a default value when
+// left out of a request.
+type Defaulter interface {
+	SetDefaults()
+}
+
 var ErrInvalidID = errors.New("invalid ID")
 var ErrNoID = errors.New("no ID")
 
@@ -36,6 +44,22 @@ func (qar *QueryAllResponse) ToJSON(w io.Writer) error {
 	return json.NewEncoder(w).Encode(qar)
 }
 
+func (qar *QueryAllResponse) Shape(version int) interface{} {
+	shaped := *qar
+	shaped.Results = Shape(qar.Results, version)
+
+	return &shaped
+}
+
+// PlainText returns the plain text of the results, if they have one.
+func (qar *QueryAllResponse) PlainText() (string, bool) {
+	if t, ok := qar.Results.(codec.Texter); ok {
+		return t.PlainText()
+	}
+
+	return "", false
+}
+
 type DeletedResponse struct {
 	DeletedID interface{} `json:"deleted_id"`
 }
@@ -48,6 +72,18 @@ func (del *DeletedResponse) ToJSON(w io.Writer) error {
 	return json.NewEncoder(w).Encode(del)
 }
 
+type RestoredResponse struct {
+	RestoredID interface{} `json:"restored_id"`
+}
+
+func (res *RestoredResponse) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(res)
+}
+
+func (res *RestoredResponse) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(res)
+}
+
 type TokenResponse struct {
 	Token string `json:"token"`
 }
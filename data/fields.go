@@ -0,0 +1,125 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// SelectFields returns v as JSON with only the given top-level fields, or
+// with only those fields in each of its items if it's a list. List responses
+// keep their envelope.
+func SelectFields(v interface{}, fields []string) (interface{}, error) {
+	if qar, ok := v.(*QueryAllResponse); ok {
+		results, err := SelectFields(qar.Results, fields)
+
+		if err != nil {
+			return nil, err
+		}
+
+		selected := *qar
+		selected.Results = results
+
+		return &selected, nil
+	}
+
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if b = bytes.TrimSpace(b); len(b) == 0 || b[0] != '[' {
+		return selectObjectFields(b, fields)
+	}
+
+	var items []json.RawMessage
+
+	if err = json.Unmarshal(b, &items); err != nil {
+		return nil, err
+	}
+
+	selected := make([]interface{}, len(items))
+
+	for i, item := range items {
+		if selected[i], err = selectObjectFields(item, fields); err != nil {
+			return nil, err
+		}
+	}
+
+	return selected, nil
+}
+
+// selectObjectFields returns the fields of the JSON object b, leaving other
+// JSON values as they are.
+func selectObjectFields(b []byte, fields []string) (interface{}, error) {
+	if len(b) == 0 || b[0] != '{' {
+		return json.RawMessage(b), nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+
+	// The opening brace.
+	if _, err := d.Token(); err != nil {
+		return nil, err
+	}
+
+	var selected fieldSet
+
+	for d.More() {
+		key, err := d.Token()
+
+		if err != nil {
+			return nil, err
+		}
+
+		var value json.RawMessage
+
+		if err = d.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		for _, field := range fields {
+			if field == key {
+				selected = append(selected, fieldValue{field, value})
+				break
+			}
+		}
+	}
+
+	return selected, nil
+}
+
+type fieldValue struct {
+	name  string
+	value json.RawMessage
+}
+
+// fieldSet is a JSON object that keeps the order of its fields.
+type fieldSet []fieldValue
+
+func (fs fieldSet) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+
+	b.WriteString("{")
+
+	for i, field := range fs {
+		if i > 0 {
+			b.WriteString(",")
+		}
+
+		name, err := json.Marshal(field.name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		b.Write(name)
+		b.WriteString(":")
+		b.Write(field.value)
+	}
+
+	b.WriteString("}")
+
+	return []byte(b.String()), nil
+}
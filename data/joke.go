@@ -3,18 +3,66 @@ package data
 import (
 	"encoding/json"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const JokeTypeSingle = "single"
+const JokeTypeTwoPart = "twopart"
+
+var JokeFlagNames = []string{"nsfw", "religious", "political", "racist", "sexist", "explicit"}
+
+type JokeFlags struct {
+	NSFW      bool `json:"nsfw" bson:"nsfw"`
+	Religious bool `json:"religious" bson:"religious"`
+	Political bool `json:"political" bson:"political"`
+	Racist    bool `json:"racist" bson:"racist"`
+	Sexist    bool `json:"sexist" bson:"sexist"`
+	Explicit  bool `json:"explicit" bson:"explicit"`
+}
+
 type Joke struct {
-	ID          string      `json:"joke_id" db:"id,omitempty" bson:"id,omitempty"`
-	AuthorID    *string     `json:"author_id,omitempty" db:"author_id" bson:"author_id,omitempty"`
-	Text        string      `json:"text" db:"text" validate:"required"`
-	Explanation string      `json:"explanation,omitempty" db:"explanation,omitempty" bson:"explanation,omitempty"`
-	Language    string      `json:"lang" db:"language" bson:"language" validate:"required"`
-	Ratings     JokeRatings `json:"ratings,omitempty" bson:"ratings,omitempty"`
-	AvgRating   *float64    `json:"avg_rating" bson:"avgRating"`
+	ID              string            `json:"joke_id" db:"id,omitempty" bson:"id,omitempty"`
+	AuthorID        *string           `json:"author_id,omitempty" db:"author_id" bson:"author_id,omitempty"`
+	Author          *UserProfile      `json:"author,omitempty" db:"-" bson:"-"`
+	Type            string            `json:"type" db:"type" bson:"type" validate:"omitempty,oneof=single twopart"`
+	Text            string            `json:"text,omitempty" db:"text" validate:"required_if=Type single,excluded_with=Setup Delivery"`
+	Setup           string            `json:"setup,omitempty" db:"setup" bson:"setup,omitempty" validate:"required_if=Type twopart"`
+	Delivery        string            `json:"delivery,omitempty" db:"delivery" bson:"delivery,omitempty" validate:"required_if=Type twopart"`
+	Explanation     string            `json:"explanation,omitempty" db:"explanation,omitempty" bson:"explanation,omitempty"`
+	Language        string            `json:"lang" db:"language" bson:"language" validate:"required"`
+	Flags           JokeFlags         `json:"flags" db:"-" bson:"flags"`
+	Status          string            `json:"status" db:"status" bson:"status"`
+	RejectionReason string            `json:"rejection_reason,omitempty" db:"rejection_reason" bson:"rejection_reason,omitempty"`
+	Revision        uint64            `json:"revision" db:"revision" bson:"revision"`
+	DeletedAt       *time.Time        `json:"deleted_at,omitempty" db:"deleted_at" bson:"deleted_at,omitempty"`
+	DeletedBy       *string           `json:"deleted_by,omitempty" db:"deleted_by" bson:"deleted_by,omitempty"`
+	Ratings         JokeRatings       `json:"ratings,omitempty" bson:"ratings,omitempty"`
+	AvgRating       *float64          `json:"avg_rating" bson:"avgRating"`
+	RatingCount     uint64            `json:"rating_count" db:"rating_count" bson:"rating_count"`
+	RatingSum       float64           `json:"-" db:"rating_sum" bson:"rating_sum"`
+	Score           float64           `json:"score" db:"score" bson:"score"`
+	RatingHistogram map[string]uint64 `json:"rating_histogram,omitempty" bson:"rating_histogram,omitempty"`
+	Reactions       map[string]uint64 `json:"reactions" db:"-" bson:"reactions"`
+	FavoriteCount   uint64            `json:"favorite_count" db:"favorite_count" bson:"favorite_count"`
+	CommentCount    uint64            `json:"comment_count" db:"comment_count" bson:"comment_count"`
+	ReportCount     uint64            `json:"report_count,omitempty" db:"report_count" bson:"report_count"`
+	Hidden          bool              `json:"hidden,omitempty" db:"hidden" bson:"hidden"`
+}
+
+// Public reports whether anyone may see the joke: it has been approved and
+// hasn't been hidden after being reported.
+func (j *Joke) Public() bool {
+	return j.Status == JokeStatusApproved && !j.Hidden
+}
+
+// SetDefaults makes jokes of no type single jokes.
+func (j *Joke) SetDefaults() {
+	if j.Type == "" {
+		j.Type = JokeTypeSingle
+	}
 }
 
 func (j *Joke) GetID() (string, error) {
@@ -59,6 +107,35 @@ func (j *Joke) ToJSON(w io.Writer) error {
 	return json.NewEncoder(w).Encode(j)
 }
 
+// jokeFields has the fields of Joke without its methods, to be embedded in
+// the shapes of other API versions.
+type jokeFields Joke
+
+// jokeV2 is a joke in API v2, identified by "id" like any other resource.
+type jokeV2 struct {
+	ID string `json:"id"`
+	*jokeFields
+	JokeID *struct{} `json:"joke_id,omitempty"`
+}
+
+func (j *Joke) Shape(version int) interface{} {
+	if version >= APIVersion2 {
+		return &jokeV2{ID: j.ID, jokeFields: (*jokeFields)(j)}
+	}
+
+	return j
+}
+
+// PlainText returns the text of the joke, its setup and delivery on separate
+// lines if it has two parts.
+func (j *Joke) PlainText() (string, bool) {
+	if j.Type == JokeTypeTwoPart {
+		return j.Setup + "\n" + j.Delivery, true
+	}
+
+	return j.Text, true
+}
+
 type Jokes []*Joke
 
 func (j *Jokes) FromJSON(r io.Reader) error {
@@ -68,3 +145,44 @@ func (j *Jokes) FromJSON(r io.Reader) error {
 func (j *Jokes) ToJSON(w io.Writer) error {
 	return json.NewEncoder(w).Encode(j)
 }
+
+func (j Jokes) Shape(version int) interface{} {
+	shaped := make([]interface{}, len(j))
+
+	for i, joke := range j {
+		shaped[i] = joke.Shape(version)
+	}
+
+	return shaped
+}
+
+// PlainText returns the jokes separated by blank lines.
+func (j Jokes) PlainText() (string, bool) {
+	texts := make([]string, len(j))
+
+	for i, joke := range j {
+		texts[i], _ = joke.PlainText()
+	}
+
+	return strings.Join(texts, "\n\n"), true
+}
+
+// InOrder returns the jokes ordered as ids, leaving out the IDs that aren't
+// in j.
+func (j Jokes) InOrder(ids []string) Jokes {
+	jokesByID := make(map[string]*Joke, len(j))
+
+	for _, joke := range j {
+		jokesByID[joke.ID] = joke
+	}
+
+	jokes := make(Jokes, 0, len(j))
+
+	for _, id := range ids {
+		if joke, ok := jokesByID[id]; ok {
+			jokes = append(jokes, joke)
+		}
+	}
+
+	return jokes
+}
@@ -0,0 +1,111 @@
+package data
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JokeComment struct {
+	ID        string       `json:"comment_id" db:"id" bson:"id"`
+	JokeID    string       `json:"joke_id" db:"joke_id" bson:"joke_id"`
+	ParentID  *string      `json:"parent_id,omitempty" db:"parent_id" bson:"parent_id" validate:"omitempty,uuid"`
+	AuthorID  string       `json:"author_id" db:"author_id" bson:"author_id"`
+	Text      string       `json:"text" db:"text" bson:"text" validate:"required,max=1000"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at" bson:"created_at"`
+	UpdatedAt *time.Time   `json:"updated_at,omitempty" db:"updated_at" bson:"updated_at,omitempty"`
+	RemovedAt *time.Time   `json:"removed_at,omitempty" db:"removed_at" bson:"removed_at,omitempty"`
+	RemovedBy *string      `json:"removed_by,omitempty" db:"removed_by" bson:"removed_by,omitempty"`
+	Replies   JokeComments `json:"replies,omitempty" db:"-" bson:"-"`
+}
+
+func (jc *JokeComment) GetID() (string, error) {
+	if jc.ID == "" {
+		return "", ErrNoID
+	}
+
+	if _, err := uuid.Parse(jc.ID); err != nil {
+		return jc.ID, ErrInvalidID
+	}
+
+	return jc.ID, nil
+}
+
+func (jc *JokeComment) GenerateID() error {
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return err
+	}
+
+	jc.ID = id.String()
+
+	return nil
+}
+
+func (jc *JokeComment) SetID(id string) {
+	jc.ID = id
+}
+
+func (jc *JokeComment) CheckValidID(id string) error {
+	_, err := uuid.Parse(id)
+
+	return err
+}
+
+func (jc *JokeComment) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(jc)
+}
+
+func (jc *JokeComment) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(jc)
+}
+
+func (jc *JokeComment) PlainText() (string, bool) {
+	return jc.Text, true
+}
+
+// FromPlainText takes text as the text of the comment.
+func (jc *JokeComment) FromPlainText(text string) error {
+	jc.Text = text
+
+	return nil
+}
+
+type JokeComments []*JokeComment
+
+func (jc *JokeComments) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(jc)
+}
+
+func (jc *JokeComments) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(jc)
+}
+
+// Thread attaches replies to their parent among jc, ordered by creation.
+func (jc JokeComments) Thread(replies JokeComments) {
+	parents := make(map[string]*JokeComment, len(jc))
+
+	for _, comment := range jc {
+		parents[comment.ID] = comment
+	}
+
+	for _, reply := range replies {
+		if reply.ParentID == nil {
+			continue
+		}
+
+		if parent, ok := parents[*reply.ParentID]; ok {
+			parent.Replies = append(parent.Replies, reply)
+		}
+	}
+
+	for _, comment := range jc {
+		sort.SliceStable(comment.Replies, func(i, j int) bool {
+			return comment.Replies[i].CreatedAt.Before(comment.Replies[j].CreatedAt)
+		})
+	}
+}
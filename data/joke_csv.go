@@ -0,0 +1,98 @@
+package data
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// JokeCSVHeader lists the columns of jokes exported as CSV. Imports read the
+// content columns among them, in any order, and ignore the others.
+var JokeCSVHeader = []string{
+	"joke_id", "author_id", "type", "text", "setup", "delivery", "explanation", "lang", "status",
+	"flag_nsfw", "flag_religious", "flag_political", "flag_racist", "flag_sexist", "flag_explicit",
+	"revision", "rating_count", "avg_rating", "score", "favorite_count", "comment_count",
+}
+
+func (j *JokeFlags) csvFlags() map[string]*bool {
+	return map[string]*bool{
+		"flag_nsfw":      &j.NSFW,
+		"flag_religious": &j.Religious,
+		"flag_political": &j.Political,
+		"flag_racist":    &j.Racist,
+		"flag_sexist":    &j.Sexist,
+		"flag_explicit":  &j.Explicit,
+	}
+}
+
+// CSVRecord returns the joke as a record of JokeCSVHeader columns.
+func (j *Joke) CSVRecord() []string {
+	authorID, avgRating := "", ""
+
+	if j.AuthorID != nil {
+		authorID = *j.AuthorID
+	}
+
+	if j.AvgRating != nil {
+		avgRating = strconv.FormatFloat(*j.AvgRating, 'f', -1, 64)
+	}
+
+	record := []string{j.ID, authorID, j.Type, j.Text, j.Setup, j.Delivery, j.Explanation, j.Language, j.Status}
+	flags := j.Flags.csvFlags()
+
+	for _, name := range JokeFlagNames {
+		record = append(record, strconv.FormatBool(*flags["flag_"+name]))
+	}
+
+	return append(record,
+		strconv.FormatUint(j.Revision, 10),
+		strconv.FormatUint(j.RatingCount, 10),
+		avgRating,
+		strconv.FormatFloat(j.Score, 'f', -1, 64),
+		strconv.FormatUint(j.FavoriteCount, 10),
+		strconv.FormatUint(j.CommentCount, 10))
+}
+
+// FromCSVRecord sets the content of the joke from a record with the given
+// header. Empty flags are false.
+func (j *Joke) FromCSVRecord(header []string, record []string) error {
+	if len(record) != len(header) {
+		return fmt.Errorf("expected %d columns, got %d", len(header), len(record))
+	}
+
+	flags := j.Flags.csvFlags()
+
+	for i, column := range header {
+		value := record[i]
+
+		switch column {
+		case "type":
+			j.Type = value
+		case "text":
+			j.Text = value
+		case "setup":
+			j.Setup = value
+		case "delivery":
+			j.Delivery = value
+		case "explanation":
+			j.Explanation = value
+		case "lang":
+			j.Language = value
+		default:
+			flag, ok := flags[column]
+
+			if !ok || value == "" {
+				continue
+			}
+
+			b, err := strconv.ParseBool(value)
+
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", column, value)
+			}
+
+			*flag = b
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package data
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JokeFavorite struct {
+	ID        string    `json:"favorite_id" db:"id" bson:"id"`
+	UserID    string    `json:"user_id" db:"user_id" bson:"user_id"`
+	JokeID    string    `json:"joke_id" db:"joke_id" bson:"joke_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at" bson:"created_at"`
+}
+
+func (jf *JokeFavorite) GenerateID() error {
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return err
+	}
+
+	jf.ID = id.String()
+
+	return nil
+}
+
+func (jf *JokeFavorite) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(jf)
+}
+
+func (jf *JokeFavorite) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(jf)
+}
@@ -0,0 +1,33 @@
+package data
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JokeImportReport sums up a bulk import of jokes.
+type JokeImportReport struct {
+	Imported uint64             `json:"imported"`
+	Failed   uint64             `json:"failed"`
+	Errors   []*JokeImportError `json:"errors"`
+
+	// Aborted is set when the import stopped early, e.g. on a malformed
+	// document. The jokes imported until then are kept.
+	Aborted bool   `json:"aborted,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// JokeImportError is a record of an import that was skipped.
+type JokeImportError struct {
+	Line   uint64               `json:"line"`
+	Detail string               `json:"detail,omitempty"`
+	Errors []*ProblemFieldError `json:"errors,omitempty"`
+}
+
+func (jir *JokeImportReport) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(jir)
+}
+
+func (jir *JokeImportReport) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(jir)
+}
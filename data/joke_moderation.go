@@ -0,0 +1,60 @@
+package data
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/google/uuid"
+)
+
+const JokeStatusPending = "pending"
+const JokeStatusApproved = "approved"
+const JokeStatusRejected = "rejected"
+
+type JokeModeration struct {
+	JokeID string `json:"-"`
+	Status string `json:"status" validate:"required,oneof=approved rejected"`
+	Reason string `json:"reason,omitempty" validate:"required_if=Status rejected"`
+}
+
+func (jm *JokeModeration) SetID(id string) {
+	jm.JokeID = id
+}
+
+func (jm *JokeModeration) GetID() (string, error) {
+	if jm.JokeID == "" {
+		return "", ErrNoID
+	}
+
+	if _, err := uuid.Parse(jm.JokeID); err != nil {
+		return jm.JokeID, ErrInvalidID
+	}
+
+	return jm.JokeID, nil
+}
+
+func (jm *JokeModeration) GenerateID() error {
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return err
+	}
+
+	jm.JokeID = id.String()
+
+	return nil
+}
+
+func (jm *JokeModeration) CheckValidID(id string) error {
+	_, err := uuid.Parse(id)
+
+	return err
+}
+
+func (jm *JokeModeration) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(jm)
+}
+
+func (jm *JokeModeration) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(jm)
+}
@@ -3,14 +3,16 @@ package data
 import (
 	"encoding/json"
 	"io"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type JokeRating struct {
-	ID     string  `json:"rating_id" bson:"id,omitempty"`
-	UserID *string `json:"user_id" bson:"user_id,omitempty"`
-	Rating float64 `json:"rating" validate:"required,gt=0,lte=5" bson:"rating"`
+	ID      string    `json:"rating_id" bson:"id,omitempty"`
+	UserID  *string   `json:"user_id" bson:"user_id,omitempty"`
+	Rating  float64   `json:"rating" validate:"required,gt=0,lte=5" bson:"rating"`
+	RatedAt time.Time `json:"rated_at" bson:"rated_at"`
 }
 
 func (jr *JokeRating) SetID(id string) {
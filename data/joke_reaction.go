@@ -0,0 +1,46 @@
+package data
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+var JokeReactionKinds = []string{"laugh", "groan", "facepalm", "clap", "confused"}
+
+func IsJokeReactionKind(kind string) bool {
+	for _, name := range JokeReactionKinds {
+		if name == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewJokeReactionCounts returns counts with every reaction kind set to zero,
+// so jokes can be sorted by any kind without missing values.
+func NewJokeReactionCounts() map[string]uint64 {
+	counts := make(map[string]uint64, len(JokeReactionKinds))
+
+	for _, kind := range JokeReactionKinds {
+		counts[kind] = 0
+	}
+
+	return counts
+}
+
+type JokeReaction struct {
+	JokeID    string    `json:"joke_id" db:"joke_id" bson:"joke_id"`
+	UserID    string    `json:"user_id" db:"user_id" bson:"user_id"`
+	Kind      string    `json:"kind" db:"kind" bson:"kind"`
+	CreatedAt time.Time `json:"created_at" db:"created_at" bson:"created_at"`
+}
+
+func (jr *JokeReaction) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(jr)
+}
+
+func (jr *JokeReaction) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(jr)
+}
@@ -0,0 +1,104 @@
+package data
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JokeRevisionChange struct {
+	Field string `json:"field" bson:"field"`
+	From  string `json:"from" bson:"from"`
+	To    string `json:"to" bson:"to"`
+}
+
+type JokeRevision struct {
+	ID        string                `json:"revision_id" bson:"id"`
+	JokeID    string                `json:"joke_id" bson:"joke_id"`
+	Revision  uint64                `json:"revision" bson:"revision"`
+	EditorID  *string               `json:"editor_id" bson:"editor_id"`
+	CreatedAt time.Time             `json:"created_at" bson:"created_at"`
+	Changes   []*JokeRevisionChange `json:"changes" bson:"changes"`
+}
+
+func (jr *JokeRevision) GenerateID() error {
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return err
+	}
+
+	jr.ID = id.String()
+
+	return nil
+}
+
+func (jr *JokeRevision) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(jr)
+}
+
+func (jr *JokeRevision) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(jr)
+}
+
+type JokeRevisions []*JokeRevision
+
+func (jr *JokeRevisions) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(jr)
+}
+
+func (jr *JokeRevisions) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(jr)
+}
+
+// revisionFields lists the joke fields tracked by revisions, keyed by their JSON name.
+func (j *Joke) revisionFields() map[string]*string {
+	return map[string]*string{
+		"type":        &j.Type,
+		"text":        &j.Text,
+		"setup":       &j.Setup,
+		"delivery":    &j.Delivery,
+		"explanation": &j.Explanation,
+		"lang":        &j.Language,
+	}
+}
+
+// Diff returns the changes needed to turn j into updated.
+func (j *Joke) Diff(updated *Joke) []*JokeRevisionChange {
+	var changes []*JokeRevisionChange
+
+	current := j.revisionFields()
+	next := updated.revisionFields()
+
+	for _, field := range []string{"type", "text", "setup", "delivery", "explanation", "lang"} {
+		if *current[field] != *next[field] {
+			changes = append(changes, &JokeRevisionChange{
+				Field: field,
+				From:  *current[field],
+				To:    *next[field],
+			})
+		}
+	}
+
+	return changes
+}
+
+// RevertTo undoes every revision newer than rev. revisions must be sorted by
+// ascending revision number.
+func (j *Joke) RevertTo(revisions JokeRevisions, rev uint64) {
+	fields := j.revisionFields()
+
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if revisions[i].Revision <= rev {
+			continue
+		}
+
+		for _, change := range revisions[i].Changes {
+			if field, ok := fields[change.Field]; ok {
+				*field = change.From
+			}
+		}
+	}
+}
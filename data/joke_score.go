@@ -0,0 +1,52 @@
+package data
+
+import (
+	"math"
+	"strconv"
+)
+
+// Jokes with few ratings are pulled towards RatingPriorMean as if they had
+// RatingPriorWeight extra ratings of that value, so a single 5-star vote
+// doesn't outrank a joke with hundreds of good ones.
+const RatingPriorMean = 3.0
+const RatingPriorWeight = 10.0
+
+// RatingBucket returns the histogram bucket ("1" to "5") a rating falls in.
+func RatingBucket(rating float64) string {
+	bucket := int(math.Ceil(rating))
+
+	if bucket < 1 {
+		bucket = 1
+	} else if bucket > 5 {
+		bucket = 5
+	}
+
+	return strconv.Itoa(bucket)
+}
+
+func (j *Joke) UpdateScore() {
+	avgRating := 0.0
+
+	if j.RatingCount > 0 {
+		avgRating = j.RatingSum / float64(j.RatingCount)
+	}
+
+	j.AvgRating = &avgRating
+	j.Score = (RatingPriorWeight*RatingPriorMean + j.RatingSum) / (RatingPriorWeight + float64(j.RatingCount))
+}
+
+// ComputeRatingAggregates rebuilds the rating count, sum, histogram and
+// score from the embedded Ratings.
+func (j *Joke) ComputeRatingAggregates() {
+	j.RatingCount = 0
+	j.RatingSum = 0
+	j.RatingHistogram = make(map[string]uint64)
+
+	for _, rating := range j.Ratings {
+		j.RatingCount++
+		j.RatingSum += rating.Rating
+		j.RatingHistogram[RatingBucket(rating.Rating)]++
+	}
+
+	j.UpdateScore()
+}
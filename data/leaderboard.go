@@ -0,0 +1,48 @@
+package data
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+const LeaderboardDay = "day"
+const LeaderboardWeek = "week"
+const LeaderboardMonth = "month"
+const LeaderboardAll = "all"
+const LeaderboardTrending = "trending"
+
+type JokeRatingEvent struct {
+	JokeID   string    `bson:"joke_id"`
+	Language string    `bson:"language"`
+	Rating   float64   `bson:"rating"`
+	RatedAt  time.Time `bson:"rated_at"`
+}
+
+type JokeRatingEvents []*JokeRatingEvent
+
+type Leaderboard struct {
+	Period    string    `json:"period"`
+	Language  string    `json:"lang,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Results   Jokes     `json:"results"`
+}
+
+func (lb *Leaderboard) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(lb)
+}
+
+func (lb *Leaderboard) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(lb)
+}
+
+func (lb *Leaderboard) Shape(version int) interface{} {
+	return &struct {
+		*Leaderboard
+		Results interface{} `json:"results"`
+	}{lb, lb.Results.Shape(version)}
+}
+
+func (lb *Leaderboard) PlainText() (string, bool) {
+	return lb.Results.PlainText()
+}
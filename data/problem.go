@@ -0,0 +1,36 @@
+package data
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// ProblemTypeValidation is the type of the problems listing the fields of a
+// payload that failed validation.
+const ProblemTypeValidation = "/problems/validation"
+
+// Problem is an error response as described by RFC 7807.
+type Problem struct {
+	Type      string               `json:"type"`
+	Title     string               `json:"title"`
+	Status    int                  `json:"status"`
+	Detail    string               `json:"detail,omitempty"`
+	RequestID string               `json:"request_id,omitempty"`
+	Errors    []*ProblemFieldError `json:"errors,omitempty"`
+}
+
+// ProblemFieldError is a field of a payload that failed a validation rule,
+// e.g. {"field": "rating", "rule": "lte", "param": "5"}.
+type ProblemFieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+func (p *Problem) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(p)
+}
+
+func (p *Problem) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(p)
+}
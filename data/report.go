@@ -0,0 +1,133 @@
+package data
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const ReportTargetJoke = "joke"
+const ReportTargetComment = "comment"
+
+const ReportStatusOpen = "open"
+const ReportStatusUpheld = "upheld"
+const ReportStatusDismissed = "dismissed"
+
+type Report struct {
+	ID             string     `json:"report_id" db:"id" bson:"id"`
+	TargetType     string     `json:"target_type" db:"target_type" bson:"target_type"`
+	JokeID         string     `json:"joke_id" db:"joke_id" bson:"joke_id"`
+	CommentID      *string    `json:"comment_id,omitempty" db:"comment_id" bson:"comment_id"`
+	ReporterID     string     `json:"reporter_id" db:"reporter_id" bson:"reporter_id"`
+	Reason         string     `json:"reason" db:"reason" bson:"reason" validate:"required,oneof=spam offensive hateful harassment plagiarism other"`
+	Note           string     `json:"note,omitempty" db:"note" bson:"note,omitempty" validate:"max=500,required_if=Reason other"`
+	Status         string     `json:"status" db:"status" bson:"status"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at" bson:"created_at"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty" db:"resolved_at" bson:"resolved_at,omitempty"`
+	ResolvedBy     *string    `json:"resolved_by,omitempty" db:"resolved_by" bson:"resolved_by,omitempty"`
+	ResolutionNote string     `json:"resolution_note,omitempty" db:"resolution_note" bson:"resolution_note,omitempty"`
+}
+
+func (rp *Report) GetID() (string, error) {
+	if rp.ID == "" {
+		return "", ErrNoID
+	}
+
+	if _, err := uuid.Parse(rp.ID); err != nil {
+		return rp.ID, ErrInvalidID
+	}
+
+	return rp.ID, nil
+}
+
+func (rp *Report) GenerateID() error {
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return err
+	}
+
+	rp.ID = id.String()
+
+	return nil
+}
+
+func (rp *Report) SetID(id string) {
+	rp.ID = id
+}
+
+func (rp *Report) CheckValidID(id string) error {
+	_, err := uuid.Parse(id)
+
+	return err
+}
+
+func (rp *Report) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(rp)
+}
+
+func (rp *Report) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(rp)
+}
+
+type Reports []*Report
+
+func (rp *Reports) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(rp)
+}
+
+func (rp *Reports) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(rp)
+}
+
+// ReportResolution is a moderator's verdict on a report, applied to every
+// open report on the same target.
+type ReportResolution struct {
+	ReportID string `json:"-"`
+	Status   string `json:"status" validate:"required,oneof=upheld dismissed"`
+	Note     string `json:"note,omitempty" validate:"max=500"`
+}
+
+func (rr *ReportResolution) SetID(id string) {
+	rr.ReportID = id
+}
+
+func (rr *ReportResolution) GetID() (string, error) {
+	if rr.ReportID == "" {
+		return "", ErrNoID
+	}
+
+	if _, err := uuid.Parse(rr.ReportID); err != nil {
+		return rr.ReportID, ErrInvalidID
+	}
+
+	return rr.ReportID, nil
+}
+
+func (rr *ReportResolution) GenerateID() error {
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return err
+	}
+
+	rr.ReportID = id.String()
+
+	return nil
+}
+
+func (rr *ReportResolution) CheckValidID(id string) error {
+	_, err := uuid.Parse(id)
+
+	return err
+}
+
+func (rr *ReportResolution) FromJSON(r io.Reader) error {
+	return json.NewDecoder(r).Decode(rr)
+}
+
+func (rr *ReportResolution) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(rr)
+}
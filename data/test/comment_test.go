@@ -0,0 +1,36 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davq23/jokeapi/data"
+)
+
+func TestThread(t *testing.T) {
+	first, second, missing := "first", "second", "missing"
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	comments := data.JokeComments{{ID: first}, {ID: second}}
+
+	comments.Thread(data.JokeComments{
+		{ID: "late", ParentID: &first, CreatedAt: start.Add(2 * time.Minute)},
+		{ID: "orphan", ParentID: &missing, CreatedAt: start},
+		{ID: "early", ParentID: &first, CreatedAt: start.Add(time.Minute)},
+		{ID: "top", CreatedAt: start},
+		{ID: "only", ParentID: &second, CreatedAt: start},
+	})
+
+	got := map[string][]string{}
+
+	for _, comment := range comments {
+		for _, reply := range comment.Replies {
+			got[comment.ID] = append(got[comment.ID], reply.ID)
+		}
+	}
+
+	if len(got) != 2 || len(got[first]) != 2 || got[first][0] != "early" || got[first][1] != "late" ||
+		len(got[second]) != 1 || got[second][0] != "only" {
+		t.Errorf("got replies %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/davq23/jokeapi/data"
+)
+
+func TestSelectFields(t *testing.T) {
+	author := "3c1b0a3e-7e0e-4b3a-9d43-5c4b7f1f0d2e"
+	joke := &data.Joke{
+		ID:       "b8e0c7a4-3f27-4c59-8f38-2f0a7c1e9b6d",
+		AuthorID: &author,
+		Type:     data.JokeTypeSingle,
+		Text:     "A joke",
+		Flags:    data.JokeFlags{NSFW: true},
+		Author:   &data.UserProfile{ID: author},
+	}
+	cursor := "next"
+
+	tests := []struct {
+		name   string
+		v      interface{}
+		fields []string
+		want   string
+	}{
+		{"object", joke, []string{"text", "joke_id"},
+			`{"joke_id":"b8e0c7a4-3f27-4c59-8f38-2f0a7c1e9b6d","text":"A joke"}`},
+		{"nested values are kept whole", joke, []string{"flags", "author"},
+			`{"flags":{"nsfw":true,"religious":false,"political":false,"racist":false,"sexist":false,"explicit":false},"author":{"user_id":"3c1b0a3e-7e0e-4b3a-9d43-5c4b7f1f0d2e","admin":false}}`},
+		{"unknown fields", joke, []string{"punchline"}, `{}`},
+		{"list", data.Jokes{joke, joke}, []string{"type"}, `[{"type":"single"},{"type":"single"}]`},
+		{"list envelope", &data.QueryAllResponse{ResultCount: 1, CursorNext: &cursor, Limit: 1, Results: data.Jokes{joke}}, []string{"text"},
+			`{"result_count":1,"cursor_next":"next","offset":"","limit":1,"results":[{"text":"A joke"}]}`},
+		{"scalars", "text", []string{"text"}, `"text"`},
+	}
+
+	for _, test := range tests {
+		selected, err := data.SelectFields(test.v, test.fields)
+
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		b, err := json.Marshal(selected)
+
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		var got, want interface{}
+
+		json.Unmarshal(b, &got)
+		json.Unmarshal([]byte(test.want), &want)
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %s, want %s", test.name, b, test.want)
+		}
+	}
+}
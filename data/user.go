@@ -3,15 +3,18 @@ package data
 import (
 	"encoding/json"
 	"io"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type User struct {
-	ID       string `json:"user_id" bson:"id,omitempty"`
-	Email    string `json:"email" validate:"required,email" bson:"email"`
-	Password string `json:"password,omitempty" validate:"required,password" bson:"password"`
-	Admin    bool   `json:"admin" bson:"admin"`
+	ID        string     `json:"user_id" bson:"id,omitempty"`
+	Email     string     `json:"email" validate:"required,email" bson:"email"`
+	Password  string     `json:"password,omitempty" validate:"required,password" bson:"password"`
+	Admin     bool       `json:"admin" bson:"admin"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	DeletedBy *string    `json:"deleted_by,omitempty" bson:"deleted_by,omitempty"`
 }
 
 func (u *User) SetID(id string) {
@@ -56,6 +59,16 @@ func (j *User) ToJSON(w io.Writer) error {
 	return json.NewEncoder(w).Encode(j)
 }
 
+// UserProfile is what anyone may see of a user, e.g. as the author of a joke.
+type UserProfile struct {
+	ID    string `json:"user_id"`
+	Admin bool   `json:"admin"`
+}
+
+func (u *User) Profile() *UserProfile {
+	return &UserProfile{ID: u.ID, Admin: u.Admin}
+}
+
 type Users []*User
 
 func (j *Users) FromJSON(r io.Reader) error {
@@ -0,0 +1,20 @@
+package data
+
+// API versions served side by side.
+const APIVersion1 = 1
+const APIVersion2 = 2
+
+// Shaper is implemented by responses whose JSON differs between API
+// versions. Shape returns what to encode for the given version.
+type Shaper interface {
+	Shape(version int) interface{}
+}
+
+// Shape returns v shaped for the version if it's a Shaper, or v itself.
+func Shape(v interface{}, version int) interface{} {
+	if shaper, ok := v.(Shaper); ok {
+		return shaper.Shape(version)
+	}
+
+	return v
+}
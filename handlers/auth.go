@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"log"
 	"net/http"
 	"time"
@@ -9,6 +8,7 @@ import (
 	"github.com/davq23/jokeapi/data"
 	"github.com/davq23/jokeapi/middlewares"
 	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
 	"github.com/dgrijalva/jwt-go"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -24,28 +24,22 @@ type Auth struct {
 func NewAuth(am *middlewares.Auth, l *log.Logger, repo repositories.UserCRUD, vm *middlewares.Validation) *Auth {
 	au := &Auth{am: am, l: l, repo: repo, vm: vm}
 
-	au.loginUser = au.vm.DataValidation(au.login, middlewares.UserParamKey{})
+	au.loginUser = middlewares.NewParam(
+		au.vm.DataValidation(au.login, middlewares.UserParamKey{}),
+		middlewares.UserParamKey{}, &data.User{})
 
 	return au
 }
 
-func (au *Auth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	switch r.Method {
-	case http.MethodPost:
-		uCtx := context.WithValue(r.Context(), middlewares.UserParamKey{}, &data.User{})
-		au.loginUser(w, r.WithContext(uCtx))
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-	}
+func (au *Auth) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodPost, "/login", au.loginUser)
 }
 
 func (au *Auth) login(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middlewares.UserParamKey{}).(*data.User)
 
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
 		return
 	}
 
@@ -54,10 +48,10 @@ func (au *Auth) login(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if err == repositories.ErrUnknownEmail {
 			au.l.Println(err.Error())
-			http.Error(w, "Invalid email or password", http.StatusBadRequest)
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, "Invalid email or password")
 		} else {
 			au.l.Println(err.Error())
-			http.Error(w, "Database error", http.StatusInternalServerError)
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Database error")
 		}
 
 		return
@@ -68,10 +62,10 @@ func (au *Auth) login(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if err == bcrypt.ErrMismatchedHashAndPassword {
 			au.l.Println(err.Error())
-			http.Error(w, "Invalid email or password", http.StatusBadRequest)
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, "Invalid email or password")
 		} else {
 			au.l.Println(err.Error())
-			http.Error(w, "Application error", http.StatusInternalServerError)
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Application error")
 		}
 
 		return
@@ -88,12 +82,12 @@ func (au *Auth) login(w http.ResponseWriter, r *http.Request) {
 
 	if res.Token, err = token.SignedString(au.am.Secret); err != nil {
 		au.l.Println(err.Error())
-		http.Error(w, "Application error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Application error")
 		return
 	}
 
-	if err = res.ToJSON(w); err != nil {
+	if err = writeResponse(w, r, res); err != nil {
 		au.l.Println(err.Error())
-		http.Error(w, "Application error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Application error")
 	}
 }
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+var errBatchFailed = errors.New("batch operation failed")
+
+// Batch runs lists of requests through the handlers of the API, optionally
+// in a single repository transaction.
+type Batch struct {
+	l        *log.Logger
+	api      http.Handler
+	tr       repositories.Transactor
+	vm       *middlewares.Validation
+	am       *middlewares.Auth
+	runBatch http.HandlerFunc
+}
+
+func NewBatch(l *log.Logger, api http.Handler, tr repositories.Transactor, v *middlewares.Validation, auth *middlewares.Auth) *Batch {
+	b := &Batch{l: l, api: api, tr: tr, vm: v, am: auth}
+
+	b.runBatch = middlewares.NewParam(
+		b.am.Auth(b.vm.DataValidation(b.run, middlewares.BatchParamKey{}), false),
+		middlewares.BatchParamKey{}, &data.Batch{})
+
+	return b
+}
+
+func (b *Batch) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodPost, "/batch", b.runBatch)
+}
+
+// batchRecorder keeps the response to a batch operation.
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (br *batchRecorder) Header() http.Header {
+	return br.header
+}
+
+func (br *batchRecorder) WriteHeader(status int) {
+	if br.status == 0 {
+		br.status = status
+	}
+}
+
+func (br *batchRecorder) Write(p []byte) (int, error) {
+	br.WriteHeader(http.StatusOK)
+
+	return br.body.Write(p)
+}
+
+func (b *Batch) run(w http.ResponseWriter, r *http.Request) {
+	batch, ok := r.Context().Value(middlewares.BatchParamKey{}).(*data.Batch)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	response := &data.BatchResponse{Committed: true}
+
+	if !batch.Atomic {
+		for _, op := range batch.Operations {
+			response.Results = append(response.Results, b.serve(r, op))
+		}
+	} else {
+		err := b.tr.Transaction(r.Context(), func(ctx context.Context) error {
+			// Transactions may be retried.
+			response.Results = response.Results[:0]
+
+			for i, op := range batch.Operations {
+				result := b.serve(r.WithContext(ctx), op)
+				response.Results = append(response.Results, result)
+
+				if result.Status < http.StatusBadRequest {
+					continue
+				}
+
+				for _, skipped := range batch.Operations[i+1:] {
+					response.Results = append(response.Results, &data.BatchResult{
+						ID:     skipped.ID,
+						Status: http.StatusFailedDependency,
+					})
+				}
+
+				return errBatchFailed
+			}
+
+			return nil
+		})
+
+		if err != nil && err != errBatchFailed {
+			b.l.Println(err.Error())
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+			return
+		}
+
+		response.Committed = err == nil
+	}
+
+	err := writeResponse(w, r, response)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+// serve runs the operation as a request with the credentials, context and
+// API version of the batch.
+func (b *Batch) serve(r *http.Request, op *data.BatchOperation) *data.BatchResult {
+	result := &data.BatchResult{ID: op.ID}
+
+	u, err := url.Parse(op.Path)
+
+	if err != nil || strings.Trim(u.Path, "/") == "batch" {
+		result.Status = http.StatusBadRequest
+		result.Body = &data.Problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(http.StatusBadRequest),
+			Status:    http.StatusBadRequest,
+			Detail:    "Invalid operation path",
+			RequestID: middlewares.RequestID(r),
+		}
+
+		return result
+	}
+
+	var body io.Reader = http.NoBody
+
+	if op.Body != nil {
+		buf, err := json.Marshal(op.Body)
+
+		if err != nil {
+			b.l.Println(err.Error())
+			result.Status = http.StatusBadRequest
+
+			return result
+		}
+
+		body = bytes.NewReader(buf)
+	}
+
+	sub, err := http.NewRequestWithContext(r.Context(), op.Method, u.String(), body)
+
+	if err != nil {
+		b.l.Println(err.Error())
+		result.Status = http.StatusBadRequest
+
+		return result
+	}
+
+	sub.Header.Set("Authorization", r.Header.Get("Authorization"))
+	sub.Header.Set("Content-Type", "application/json")
+
+	for name, value := range op.Headers {
+		sub.Header.Set(name, value)
+	}
+
+	rec := &batchRecorder{header: http.Header{}}
+
+	// The operations are served in the API version of the batch, as the
+	// routes of api aren't mounted under any.
+	middlewares.APIVersion(b.api, middlewares.RequestAPIVersion(r)).ServeHTTP(rec, sub)
+
+	result.Status = rec.status
+
+	if result.Status == 0 {
+		result.Status = http.StatusOK
+	}
+
+	if rec.body.Len() > 0 {
+		var v interface{}
+
+		if err := json.Unmarshal(rec.body.Bytes(), &v); err == nil {
+			result.Body = v
+		} else {
+			result.Body = rec.body.String()
+		}
+	}
+
+	return result
+}
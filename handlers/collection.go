@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+type Collection struct {
+	l                 *log.Logger
+	repo              repositories.CollectionCRUD
+	jokeRepo          repositories.JokeCRUD
+	vm                *middlewares.Validation
+	am                *middlewares.Auth
+	getCollection     http.HandlerFunc
+	getCollections    http.HandlerFunc
+	getShared         http.HandlerFunc
+	insertCollection  http.HandlerFunc
+	updateCollection  http.HandlerFunc
+	reorderCollection http.HandlerFunc
+	deleteCollection  http.HandlerFunc
+}
+
+func NewCollection(l *log.Logger, repo repositories.CollectionCRUD, jokeRepo repositories.JokeCRUD, vm *middlewares.Validation, am *middlewares.Auth) *Collection {
+	c := &Collection{l: l, repo: repo, jokeRepo: jokeRepo, vm: vm, am: am}
+
+	c.getCollection = middlewares.NewParam(
+		c.am.OptionalAuth(c.vm.PathIDValidation(c.fetchOne, middlewares.CollectionParamKey{}, "id")),
+		middlewares.CollectionParamKey{}, &data.Collection{})
+
+	c.getCollections = c.am.Auth(middlewares.FetchAllQueryURL(c.fetchAll), false)
+	c.getShared = c.fetchShared
+
+	c.insertCollection = middlewares.NewParam(
+		c.am.Auth(c.vm.DataValidation(c.insert, middlewares.CollectionParamKey{}), false),
+		middlewares.CollectionParamKey{}, &data.Collection{})
+
+	c.updateCollection = middlewares.NewParam(c.am.Auth(
+		c.vm.PathIDValidation(
+			c.vm.DataValidation(c.update, middlewares.CollectionParamKey{}), middlewares.CollectionParamKey{}, "id"),
+		false), middlewares.CollectionParamKey{}, &data.Collection{})
+
+	c.reorderCollection = middlewares.NewParam(c.am.Auth(
+		c.vm.PathIDValidation(
+			c.vm.DataValidation(c.reorder, middlewares.CollectionOrderParamKey{}), middlewares.CollectionOrderParamKey{}, "id"),
+		false), middlewares.CollectionOrderParamKey{}, &data.CollectionOrder{})
+
+	c.deleteCollection = middlewares.NewParam(
+		c.am.Auth(c.vm.PathIDValidation(c.delete, middlewares.CollectionParamKey{}, "id"), false),
+		middlewares.CollectionParamKey{}, &data.Collection{})
+
+	return c
+}
+
+func (c *Collection) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodGet, "/collections", c.getCollections)
+	rt.HandleFunc(http.MethodPost, "/collections", c.insertCollection)
+	rt.HandleFunc(http.MethodGet, "/collections/shared/{slug}", c.getShared)
+	rt.HandleFunc(http.MethodGet, "/collections/{id}", c.getCollection)
+	rt.HandleFunc(http.MethodPut, "/collections/{id}", c.updateCollection)
+	rt.HandleFunc(http.MethodDelete, "/collections/{id}", c.deleteCollection)
+	rt.HandleFunc(http.MethodPut, "/collections/{id}/order", c.reorderCollection)
+}
+
+// owned fetches the collection with id, failing with ErrUnknownID when the
+// authenticated user neither owns it nor is an admin.
+func (c *Collection) owned(r *http.Request, id string) (*data.Collection, error) {
+	auth, ok := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok {
+		return nil, repositories.ErrUnknownID
+	}
+
+	collection, err := c.repo.FetchOne(r.Context(), id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !auth.Admin && collection.OwnerID != auth.ID {
+		return nil, repositories.ErrUnknownID
+	}
+
+	return collection, nil
+}
+
+// withJokes loads the jokes of collection that the requester may see.
+func (c *Collection) withJokes(r *http.Request, collection *data.Collection) error {
+	jokes, err := c.jokeRepo.FetchMany(r.Context(), collection.JokeIDs)
+
+	if err != nil {
+		return err
+	}
+
+	collection.Jokes = make(data.Jokes, 0, len(jokes))
+
+	for _, joke := range jokes.InOrder(collection.JokeIDs) {
+		if canSee(r, joke) {
+			collection.Jokes = append(collection.Jokes, joke)
+		}
+	}
+
+	return nil
+}
+
+// checkJokes reports whether every joke added to collection since current,
+// nil for new collections, exists. The jokes it had already may have been
+// hidden or trashed since, which doesn't stop them from being kept.
+func (c *Collection) checkJokes(r *http.Request, collection *data.Collection, current *data.Collection) (bool, error) {
+	if collection.JokeIDs == nil {
+		collection.JokeIDs = []string{}
+	}
+
+	kept := make(map[string]bool)
+
+	if current != nil {
+		for _, id := range current.JokeIDs {
+			kept[id] = true
+		}
+	}
+
+	added := make([]string, 0, len(collection.JokeIDs))
+
+	for _, id := range collection.JokeIDs {
+		if !kept[id] {
+			kept[id] = true
+			added = append(added, id)
+		}
+	}
+
+	jokes, err := c.jokeRepo.FetchMany(r.Context(), added)
+
+	if err != nil {
+		return false, err
+	}
+
+	return len(jokes) == len(added), nil
+}
+
+func (c *Collection) fetchAll(w http.ResponseWriter, r *http.Request) {
+	params, ok := r.Context().Value(middlewares.FetchQueryURLParamsKey{}).(*middlewares.FetchQueryURLParams)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	collections, cursorNext, err := c.repo.FetchAll(r.Context(), auth.ID, params.Limit, params.Offset, params.Direction)
+
+	if err != nil {
+		if err == repositories.ErrInvalidOffset {
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	var qar data.QueryAllResponse
+	qar.ResultCount = uint64(len(collections))
+	qar.CursorNext = cursorNext
+	qar.Offset = params.Offset
+	qar.Limit = params.Limit
+	qar.Results = collections
+
+	err = writeResponse(w, r, &qar)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (c *Collection) fetchOne(w http.ResponseWriter, r *http.Request) {
+	collection, ok := r.Context().Value(middlewares.CollectionParamKey{}).(*data.Collection)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	collection, err := c.repo.FetchOne(r.Context(), collection.ID)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Collection ID")
+			return
+		}
+
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	// Unlisted collections are only reachable through their share link.
+	if collection.Visibility != data.CollectionPublic &&
+		(!okAuth || !auth.Admin && collection.OwnerID != auth.ID) {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Collection ID")
+		return
+	}
+
+	if err = c.withJokes(r, collection); err != nil {
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	err = writeResponse(w, r, collection)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (c *Collection) fetchShared(w http.ResponseWriter, r *http.Request) {
+	slug := router.Param(r, "slug")
+
+	collection, err := c.repo.FetchBySlug(r.Context(), slug)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown collection")
+			return
+		}
+
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if collection.Visibility == data.CollectionPrivate {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown collection")
+		return
+	}
+
+	if err = c.withJokes(r, collection); err != nil {
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	err = writeResponse(w, r, collection)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (c *Collection) insert(w http.ResponseWriter, r *http.Request) {
+	collection, ok := r.Context().Value(middlewares.CollectionParamKey{}).(*data.Collection)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	found, err := c.checkJokes(r, collection, nil)
+
+	if err != nil {
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if !found {
+		middlewares.WriteProblem(w, r, http.StatusUnprocessableEntity, "Unknown Joke ID")
+		return
+	}
+
+	if err = collection.GenerateID(); err == nil {
+		err = collection.GenerateSlug()
+	}
+
+	if err != nil {
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	collection.OwnerID = auth.ID
+	collection.CreatedAt = time.Now().UTC()
+	collection.UpdatedAt = collection.CreatedAt
+	collection.Jokes = nil
+
+	_, err = c.repo.Insert(r.Context(), collection)
+
+	if err != nil {
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	err = writeResponse(w, r, collection)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (c *Collection) update(w http.ResponseWriter, r *http.Request) {
+	collection, ok := r.Context().Value(middlewares.CollectionParamKey{}).(*data.Collection)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	current, err := c.owned(r, collection.ID)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Collection ID")
+			return
+		}
+
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	found, err := c.checkJokes(r, collection, current)
+
+	if err != nil {
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if !found {
+		middlewares.WriteProblem(w, r, http.StatusUnprocessableEntity, "Unknown Joke ID")
+		return
+	}
+
+	collection.OwnerID = current.OwnerID
+	collection.Slug = current.Slug
+	collection.CreatedAt = current.CreatedAt
+	collection.UpdatedAt = time.Now().UTC()
+	collection.Jokes = nil
+
+	_, err = c.repo.Update(r.Context(), collection.ID, collection)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Collection ID")
+			return
+		}
+
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	err = writeResponse(w, r, collection)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (c *Collection) reorder(w http.ResponseWriter, r *http.Request) {
+	order, ok := r.Context().Value(middlewares.CollectionOrderParamKey{}).(*data.CollectionOrder)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	collection, err := c.owned(r, order.CollectionID)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Collection ID")
+			return
+		}
+
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if err = collection.Reorder(order.JokeIDs); err != nil {
+		middlewares.WriteProblem(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	collection.UpdatedAt = time.Now().UTC()
+
+	_, err = c.repo.Update(r.Context(), collection.ID, collection)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Collection ID")
+			return
+		}
+
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	err = writeResponse(w, r, collection)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (c *Collection) delete(w http.ResponseWriter, r *http.Request) {
+	collection, ok := r.Context().Value(middlewares.CollectionParamKey{}).(*data.Collection)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	if _, err := c.owned(r, collection.ID); err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Collection ID")
+			return
+		}
+
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	collectionID, err := c.repo.Delete(r.Context(), collection.ID)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Collection ID")
+			return
+		}
+
+		c.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	result := data.DeletedResponse{
+		DeletedID: collectionID,
+	}
+
+	err = writeResponse(w, r, result)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
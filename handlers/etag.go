@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+)
+
+// entityTag returns the strong entity tag of a response body, which differs
+// between the formats and API versions of the same resource.
+func entityTag(body []byte, contentType string) string {
+	sum := sha256.Sum256(append([]byte(contentType+"\n"), body...))
+
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// matchesTag reports whether the If-Match or If-None-Match header matches
+// tag. Weak tags only match with weak comparison, i.e. for If-None-Match.
+func matchesTag(header string, tag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeTagged writes v like writeResponse along with the entity tag of its
+// body, or replies 304 if the client has it already. It's for lists, whose
+// entries have no single revision to tag them with.
+func writeTagged(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	body, contentType, err := encodeResponse(r, v)
+
+	if err != nil {
+		return err
+	}
+
+	tag := entityTag(body, contentType)
+	w.Header().Set("ETag", tag)
+
+	if header := r.Header.Get("If-None-Match"); header != "" && matchesTag(header, tag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	_, err = w.Write(body)
+
+	return err
+}
+
+// jokeTag returns the entity tag of a joke, which is that of its revision
+// whatever the format, fields or expansions of the representation.
+func jokeTag(joke *data.Joke) string {
+	return `"` + joke.ID + "-" + strconv.FormatUint(joke.Revision, 10) + `"`
+}
+
+// tagRevisions returns the revisions of the joke the tags of the If-Match
+// header name.
+func tagRevisions(header string, id string) []uint64 {
+	var revisions []uint64
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.Trim(strings.TrimSpace(candidate), `"`)
+
+		if !strings.HasPrefix(candidate, id+"-") {
+			continue
+		}
+
+		revision, err := strconv.ParseUint(strings.TrimPrefix(candidate, id+"-"), 10, 64)
+
+		if err == nil {
+			revisions = append(revisions, revision)
+		}
+	}
+
+	return revisions
+}
+
+// writeJoke writes the joke like writeResponse along with the tag of its
+// revision, or replies 304 if the client has that revision already.
+func writeJoke(w http.ResponseWriter, r *http.Request, joke *data.Joke) error {
+	tag := jokeTag(joke)
+	w.Header().Set("ETag", tag)
+
+	if header := r.Header.Get("If-None-Match"); header != "" && matchesTag(header, tag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	return writeResponse(w, r, joke)
+}
+
+// matchedRevisionKey is the context key under which ifMatch puts the revision
+// of the joke the precondition names.
+type matchedRevisionKey struct{}
+
+// matchedRevision returns the revision of the joke ifMatch checked.
+func matchedRevision(r *http.Request) uint64 {
+	revision, _ := r.Context().Value(matchedRevisionKey{}).(uint64)
+
+	return revision
+}
+
+// ifMatch requires the If-Match header of edits of the joke under
+// JokeParamKey, replying 428 without it and 412 if it names no revision of
+// the joke. The edit itself fails if the revision isn't the current one by
+// then. Only If-Match: * needs the current revision fetched.
+func (j *Joke) ifMatch(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		joke, ok := r.Context().Value(middlewares.JokeParamKey{}).(*data.Joke)
+
+		if !ok {
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+			return
+		}
+
+		header := strings.TrimSpace(r.Header.Get("If-Match"))
+
+		if header == "" {
+			middlewares.WriteProblem(w, r, http.StatusPreconditionRequired, "Edits need the ETag of the joke in If-Match")
+			return
+		}
+
+		revisions := tagRevisions(header, joke.ID)
+
+		if header == "*" || len(revisions) > 1 {
+			current, err := j.repo.FetchOne(r.Context(), joke.ID)
+
+			if err != nil {
+				if err == repositories.ErrUnknownID {
+					middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+					return
+				}
+
+				j.l.Println(err.Error())
+				middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+				return
+			}
+
+			if header == "*" || matchesTag(header, jokeTag(current), false) {
+				revisions = []uint64{current.Revision}
+			} else {
+				revisions = nil
+			}
+		}
+
+		if len(revisions) == 0 {
+			middlewares.WriteProblem(w, r, http.StatusPreconditionFailed, "The joke has changed since")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), matchedRevisionKey{}, revisions[0])
+
+		next(w, r.WithContext(ctx))
+	})
+}
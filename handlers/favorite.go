@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+type Favorite struct {
+	l              *log.Logger
+	repo           repositories.JokeCRUD
+	vm             *middlewares.Validation
+	am             *middlewares.Auth
+	getFavorites   http.HandlerFunc
+	insertFavorite http.HandlerFunc
+	deleteFavorite http.HandlerFunc
+}
+
+func NewFavorite(l *log.Logger, repo repositories.JokeCRUD, vm *middlewares.Validation, am *middlewares.Auth) *Favorite {
+	f := &Favorite{l: l, repo: repo, vm: vm, am: am}
+
+	f.getFavorites = middlewares.NewParam(f.am.Auth(middlewares.FetchAllQueryURL(
+		f.vm.PathIDValidation(f.fetchAll, middlewares.UserParamKey{}, "id")), false),
+		middlewares.UserParamKey{}, &data.User{})
+
+	f.insertFavorite = middlewares.NewParam(middlewares.NewParam(f.am.Auth(f.vm.PathIDValidation(
+		f.vm.PathIDValidation(f.insert, middlewares.JokeParamKey{}, "jokeID"), middlewares.UserParamKey{}, "id"), false),
+		middlewares.UserParamKey{}, &data.User{}), middlewares.JokeParamKey{}, &data.Joke{})
+
+	f.deleteFavorite = middlewares.NewParam(middlewares.NewParam(f.am.Auth(f.vm.PathIDValidation(
+		f.vm.PathIDValidation(f.delete, middlewares.JokeParamKey{}, "jokeID"), middlewares.UserParamKey{}, "id"), false),
+		middlewares.UserParamKey{}, &data.User{}), middlewares.JokeParamKey{}, &data.Joke{})
+
+	return f
+}
+
+func (f *Favorite) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodGet, "/users/{id}/favorites", f.getFavorites)
+	rt.HandleFunc(http.MethodPost, "/users/{id}/favorites/{jokeID}", f.insertFavorite)
+	rt.HandleFunc(http.MethodDelete, "/users/{id}/favorites/{jokeID}", f.deleteFavorite)
+}
+
+// owner returns the user whose favorites are requested, or false if the
+// authenticated user may not access them.
+func (f *Favorite) owner(r *http.Request) (*data.User, bool) {
+	user, ok := r.Context().Value(middlewares.UserParamKey{}).(*data.User)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		return nil, false
+	}
+
+	return user, auth.Admin || user.ID == auth.ID
+}
+
+func (f *Favorite) fetchAll(w http.ResponseWriter, r *http.Request) {
+	params, ok := r.Context().Value(middlewares.FetchQueryURLParamsKey{}).(*middlewares.FetchQueryURLParams)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	user, ok := f.owner(r)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown User ID")
+		return
+	}
+
+	jokes, cursorNext, err := f.repo.FetchFavorites(r.Context(), user.ID, params.Limit, params.Offset, params.Direction)
+
+	if err != nil {
+		if err == repositories.ErrInvalidOffset {
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		f.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	// Jokes may have gone back to moderation or been rejected since they
+	// were added.
+	visible := make(data.Jokes, 0, len(jokes))
+
+	for _, joke := range jokes {
+		if canSee(r, joke) {
+			visible = append(visible, joke)
+		}
+	}
+
+	var qar data.QueryAllResponse
+	qar.ResultCount = uint64(len(visible))
+	qar.CursorNext = cursorNext
+	qar.Offset = params.Offset
+	qar.Limit = params.Limit
+	qar.Results = visible
+
+	err = writeResponse(w, r, &qar)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (f *Favorite) insert(w http.ResponseWriter, r *http.Request) {
+	joke, ok := r.Context().Value(middlewares.JokeParamKey{}).(*data.Joke)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	user, ok := f.owner(r)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown User ID")
+		return
+	}
+
+	visible, err := jokeVisible(r, f.repo, joke.ID)
+
+	if err != nil {
+		f.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if !visible {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+		return
+	}
+
+	favorite := &data.JokeFavorite{
+		UserID:    user.ID,
+		JokeID:    joke.ID,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	err = favorite.GenerateID()
+
+	if err != nil {
+		f.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	_, err = f.repo.AddFavorite(r.Context(), favorite)
+
+	if err != nil {
+		switch err {
+		case repositories.ErrUnknownID:
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+		case repositories.ErrDuplicateFavorite:
+			middlewares.WriteProblem(w, r, http.StatusConflict, "Joke already in favorites")
+		default:
+			f.l.Println(err.Error())
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		}
+
+		return
+	}
+
+	err = writeResponse(w, r, favorite)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (f *Favorite) delete(w http.ResponseWriter, r *http.Request) {
+	joke, ok := r.Context().Value(middlewares.JokeParamKey{}).(*data.Joke)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	user, ok := f.owner(r)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown User ID")
+		return
+	}
+
+	jokeID, err := f.repo.RemoveFavorite(r.Context(), joke.ID, user.ID)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Joke not in favorites")
+			return
+		}
+
+		f.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	result := data.DeletedResponse{
+		DeletedID: jokeID,
+	}
+
+	err = writeResponse(w, r, result)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
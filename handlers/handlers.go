@@ -1 +1,121 @@
 package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/davq23/jokeapi/codec"
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+)
+
+// jokeVisible reports whether the requester may see the joke and whatever
+// hangs off it, following the same rules as fetching the joke itself.
+func jokeVisible(r *http.Request, repo repositories.JokeCRUD, jokeID string) (bool, error) {
+	joke, err := repo.FetchOne(r.Context(), jokeID)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return canSee(r, joke), nil
+}
+
+// canSee reports whether the requester may see joke: anyone if it's public,
+// only its author and admins otherwise.
+func canSee(r *http.Request, joke *data.Joke) bool {
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	return joke.Public() ||
+		okAuth && (auth.Admin || joke.AuthorID != nil && *joke.AuthorID == auth.ID)
+}
+
+// writeJSON writes v shaped for the API version the request came through.
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	return json.NewEncoder(w).Encode(data.Shape(v, middlewares.RequestAPIVersion(r)))
+}
+
+// writeResponse writes v shaped for the API version the request came through,
+// in the format it asked for.
+func writeResponse(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	body, contentType, err := encodeResponse(r, v)
+
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	_, err = w.Write(body)
+
+	return err
+}
+
+// encodeResponse returns the body writeResponse writes for v and its content
+// type, trimmed to the fields the request asked for. Requests for plain text
+// get JSON if v has no plain text form.
+func encodeResponse(r *http.Request, v interface{}) ([]byte, string, error) {
+	var body bytes.Buffer
+	c := middlewares.ResponseCodec(r)
+
+	if c == codec.Text {
+		err := c.Encode(&body, v)
+
+		if err != codec.ErrNoText {
+			return body.Bytes(), c.MediaType(), err
+		}
+
+		body.Reset()
+		c = codec.JSON
+	}
+
+	shaped := data.Shape(v, middlewares.RequestAPIVersion(r))
+
+	if fields := middlewares.RequestFields(r); len(fields) > 0 {
+		var err error
+
+		if shaped, err = data.SelectFields(shaped, fields); err != nil {
+			return nil, "", err
+		}
+	}
+
+	err := c.Encode(&body, shaped)
+
+	return body.Bytes(), c.MediaType(), err
+}
+
+// patchedFields returns the fields the patch changed, or replies 422 and
+// returns false if any of them isn't writable.
+func patchedFields(w http.ResponseWriter, r *http.Request, writable []string) ([]string, bool) {
+	fields, _ := r.Context().Value(middlewares.PatchFieldsKey{}).([]string)
+
+	var errs []*data.ProblemFieldError
+
+	for _, field := range fields {
+		if !contains(writable, field) {
+			errs = append(errs, &data.ProblemFieldError{Field: field, Rule: "readonly"})
+		}
+	}
+
+	if len(errs) > 0 {
+		middlewares.WriteValidationProblem(w, r, errs...)
+		return nil, false
+	}
+
+	return fields, true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -8,82 +8,131 @@ import (
 	"github.com/davq23/jokeapi/data"
 	"github.com/davq23/jokeapi/middlewares"
 	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
 )
 
+// expandedRatings is how many ratings ?expand=ratings embeds in a joke.
+const expandedRatings = 10
+
 type Joke struct {
 	l          *log.Logger
 	repo       repositories.JokeCRUD
+	users      repositories.UserCRUD
 	vm         *middlewares.Validation
 	am         *middlewares.Auth
 	getJoke    http.HandlerFunc
 	getJokes   http.HandlerFunc
 	insertJoke http.HandlerFunc
 	updateJoke http.HandlerFunc
+	patchJoke  http.HandlerFunc
 	deleteJoke http.HandlerFunc
+	revisions  *JokeRevision
+	comments   *JokeComment
+	reactions  *JokeReaction
+	imports    *JokeImport
 }
 
-func NewJoke(l *log.Logger, repo repositories.JokeCRUD, v *middlewares.Validation, auth *middlewares.Auth) *Joke {
-	j := &Joke{l: l, repo: repo, vm: v, am: auth}
+func NewJoke(l *log.Logger, repo repositories.JokeCRUD, users repositories.UserCRUD, v *middlewares.Validation, auth *middlewares.Auth) *Joke {
+	j := &Joke{l: l, repo: repo, users: users, vm: v, am: auth}
 
-	j.getJoke = j.vm.OneIDURLValidation(j.fetchOne, middlewares.JokeParamKey{})
-	j.getJokes = middlewares.FetchAllQueryURL(j.fetchAll)
+	j.getJoke = middlewares.NewParam(
+		j.am.OptionalAuth(j.vm.PathIDValidation(
+			middlewares.FieldsQueryURL(j.fetchOne, "author", "ratings"), middlewares.JokeParamKey{}, "id")),
+		middlewares.JokeParamKey{}, &data.Joke{})
 
-	j.insertJoke = j.am.Auth(j.vm.DataValidation(j.insert, middlewares.JokeParamKey{}), false)
-	j.updateJoke = j.am.Auth(
-		j.vm.OneIDURLValidation(
-			j.vm.DataValidation(j.update, middlewares.JokeParamKey{}), middlewares.JokeParamKey{}),
-		true)
-	j.deleteJoke = j.am.Auth(j.vm.OneIDURLValidation(j.delete, middlewares.JokeParamKey{}), false)
+	j.getJokes = middlewares.FetchAllQueryURL(middlewares.JokeFilterQueryURL(
+		middlewares.FieldsQueryURL(j.fetchAll, "author", "ratings")))
 
-	return j
-}
+	j.insertJoke = middlewares.NewParam(
+		j.am.Auth(j.vm.DataValidation(j.insert, middlewares.JokeParamKey{}), false),
+		middlewares.JokeParamKey{}, &data.Joke{})
 
-func (j *Joke) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	j.updateJoke = middlewares.NewParam(j.am.Auth(
+		j.vm.PathIDValidation(
+			j.ifMatch(j.vm.DataValidation(j.update, middlewares.JokeParamKey{})), middlewares.JokeParamKey{}, "id"),
+		true), middlewares.JokeParamKey{}, &data.Joke{})
 
-	switch r.Method {
-	case http.MethodGet:
-		if r.URL.Path == "/jokes" || r.URL.Path == "/jokes/" {
-			j.getJokes(w, r)
-		} else {
-			jkCtx := context.WithValue(r.Context(), middlewares.JokeParamKey{}, &data.Joke{})
-			j.getJoke(w, r.WithContext(jkCtx))
-		}
+	j.patchJoke = middlewares.NewParam(j.am.Auth(
+		j.vm.PathIDValidation(
+			j.ifMatch(j.vm.PatchValidation(j.patch, middlewares.JokeParamKey{}, j.current)), middlewares.JokeParamKey{}, "id"),
+		true), middlewares.JokeParamKey{}, &data.Joke{})
 
-	case http.MethodPost:
-		jkCtx := context.WithValue(r.Context(), middlewares.JokeParamKey{}, &data.Joke{})
-		j.insertJoke(w, r.WithContext(jkCtx))
+	j.deleteJoke = middlewares.NewParam(
+		j.am.Auth(j.vm.PathIDValidation(j.ifMatch(j.delete), middlewares.JokeParamKey{}, "id"), false),
+		middlewares.JokeParamKey{}, &data.Joke{})
 
-	case http.MethodPut:
-		jkCtx := context.WithValue(r.Context(), middlewares.JokeParamKey{}, &data.Joke{})
-		j.updateJoke(w, r.WithContext(jkCtx))
+	j.revisions = NewJokeRevision(l, repo, v, auth)
+	j.comments = NewJokeComment(l, repo, v, auth)
+	j.reactions = NewJokeReaction(l, repo, v, auth)
+	j.imports = NewJokeImport(l, repo, v, auth)
 
-	case http.MethodDelete:
-		jkCtx := context.WithValue(r.Context(), middlewares.JokeParamKey{}, &data.Joke{})
-		j.deleteJoke(w, r.WithContext(jkCtx))
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-	}
+	return j
+}
+
+func (j *Joke) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodGet, "/jokes", j.getJokes)
+	rt.HandleFunc(http.MethodPost, "/jokes", j.insertJoke)
+	rt.HandleFunc(http.MethodGet, "/jokes/{id}", j.getJoke)
+	rt.HandleFunc(http.MethodPut, "/jokes/{id}", j.updateJoke)
+	rt.HandleFunc(http.MethodPatch, "/jokes/{id}", j.patchJoke)
+	rt.HandleFunc(http.MethodDelete, "/jokes/{id}", j.deleteJoke)
+
+	j.revisions.Routes(rt)
+	j.comments.Routes(rt)
+	j.reactions.Routes(rt)
+	j.imports.Routes(rt)
 }
 
 func (j *Joke) delete(w http.ResponseWriter, r *http.Request) {
 	joke, ok := r.Context().Value(middlewares.JokeParamKey{}).(*data.Joke)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
 
-	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
 		return
 	}
 
-	objectID, err := j.repo.Delete(r.Context(), joke.ID)
+	// Only admins may trash the jokes of others.
+	if !auth.Admin {
+		current, err := j.repo.FetchOne(r.Context(), joke.ID)
+
+		if err != nil {
+			if err == repositories.ErrUnknownID {
+				middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+				return
+			}
+
+			j.l.Println(err.Error())
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+			return
+		}
+
+		if !canSee(r, current) {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+			return
+		}
+
+		if current.AuthorID == nil || *current.AuthorID != auth.ID {
+			middlewares.WriteProblem(w, r, http.StatusForbidden, "Forbidden")
+			return
+		}
+	}
+
+	objectID, err := j.repo.Delete(r.Context(), joke.ID, matchedRevision(r), auth.ID)
 
 	if err != nil {
 		if err == repositories.ErrUnknownID {
-			http.Error(w, "Unknown Joke ID", http.StatusNotFound)
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+			return
+		}
+
+		if err == repositories.ErrStaleRevision {
+			middlewares.WriteProblem(w, r, http.StatusPreconditionFailed, "The joke has changed since")
 			return
 		}
 
 		j.l.Println(err.Error())
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 		return
 	}
 
@@ -91,34 +140,43 @@ func (j *Joke) delete(w http.ResponseWriter, r *http.Request) {
 		DeletedID: objectID,
 	}
 
-	err = result.ToJSON(w)
+	err = writeResponse(w, r, result)
 
 	if err != nil {
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 	}
 }
 
 func (j *Joke) fetchAll(w http.ResponseWriter, r *http.Request) {
 	params, ok := r.Context().Value(middlewares.FetchQueryURLParamsKey{}).(*middlewares.FetchQueryURLParams)
+	filter, okFilter := r.Context().Value(middlewares.JokeFilterParamsKey{}).(*repositories.JokeFilter)
 
-	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+	if !ok || !okFilter {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
 		return
 	}
 
-	jokes, cursorNext, err := j.repo.FetchAll(r.Context(), params.Limit, params.Offset, params.Direction)
+	filter.Status = data.JokeStatusApproved
+
+	jokes, cursorNext, err := j.repo.FetchAll(r.Context(), params.Limit, params.Offset, params.Direction, filter)
 
 	if err != nil {
 		if err == repositories.ErrInvalidOffset {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 			return
 		} else {
 			j.l.Println(err.Error())
-			http.Error(w, "Unexpected error", http.StatusInternalServerError)
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 			return
 		}
 	}
 
+	if err = j.expand(r, jokes...); err != nil {
+		j.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
 	var qar data.QueryAllResponse
 	qar.ResultCount = uint64(len(jokes))
 	qar.CursorNext = cursorNext
@@ -126,18 +184,19 @@ func (j *Joke) fetchAll(w http.ResponseWriter, r *http.Request) {
 	qar.Limit = params.Limit
 	qar.Results = jokes
 
-	err = qar.ToJSON(w)
+	err = writeTagged(w, r, &qar)
 
 	if err != nil {
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 	}
 }
 
 func (j *Joke) fetchOne(w http.ResponseWriter, r *http.Request) {
 	joke, ok := r.Context().Value(middlewares.JokeParamKey{}).(*data.Joke)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
 
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
 		return
 	}
 
@@ -145,34 +204,110 @@ func (j *Joke) fetchOne(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		if err == repositories.ErrUnknownID {
-			http.Error(w, "Unknown Joke ID", http.StatusNotFound)
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
 			return
 		}
 
 		j.l.Println(err.Error())
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if !joke.Public() &&
+		(!okAuth || !auth.Admin && (joke.AuthorID == nil || *joke.AuthorID != auth.ID)) {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+		return
+	}
+
+	if err = j.expand(r, joke); err != nil {
+		j.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 		return
 	}
 
-	err = joke.ToJSON(w)
+	err = writeJoke(w, r, joke)
 
 	if err != nil {
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 	}
 }
 
+// expand embeds in the jokes the related resources the request asked for:
+// the profile of their author and their first ratings, each fetched for all
+// the jokes at once.
+func (j *Joke) expand(r *http.Request, jokes ...*data.Joke) error {
+	params, ok := r.Context().Value(middlewares.FieldsQueryURLParamsKey{}).(*middlewares.FieldsQueryURLParams)
+
+	if !ok || len(jokes) == 0 {
+		return nil
+	}
+
+	if params.Expands("author") {
+		authorIDs := make([]string, 0, len(jokes))
+		seen := make(map[string]bool)
+
+		for _, joke := range jokes {
+			if joke.AuthorID != nil && !seen[*joke.AuthorID] {
+				seen[*joke.AuthorID] = true
+				authorIDs = append(authorIDs, *joke.AuthorID)
+			}
+		}
+
+		authors, err := j.users.FetchMany(r.Context(), authorIDs)
+
+		if err != nil {
+			return err
+		}
+
+		profiles := make(map[string]*data.UserProfile, len(authors))
+
+		for _, author := range authors {
+			profiles[author.ID] = author.Profile()
+		}
+
+		// Authors may have been deleted since.
+		for _, joke := range jokes {
+			if joke.AuthorID != nil {
+				joke.Author = profiles[*joke.AuthorID]
+			}
+		}
+	}
+
+	if params.Expands("ratings") {
+		jokeIDs := make([]string, len(jokes))
+
+		for i, joke := range jokes {
+			jokeIDs[i] = joke.ID
+		}
+
+		ratings, err := j.repo.FetchFirstRatings(r.Context(), jokeIDs, expandedRatings)
+
+		if err != nil {
+			return err
+		}
+
+		for _, joke := range jokes {
+			joke.Ratings = ratings[joke.ID]
+		}
+	}
+
+	return nil
+}
+
 func (j *Joke) insert(w http.ResponseWriter, r *http.Request) {
 	joke, ok := r.Context().Value(middlewares.JokeParamKey{}).(*data.Joke)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
 
-	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
 		return
 	}
-	err := joke.GenerateID()
+
+	err := prepareNewJoke(joke, auth)
 
 	if err != nil {
 		j.l.Println(err.Error())
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 		return
 	}
 
@@ -180,41 +315,136 @@ func (j *Joke) insert(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		j.l.Println(err.Error())
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 		return
 	}
 
-	err = joke.ToJSON(w)
+	err = writeResponse(w, r, joke)
 
 	if err != nil {
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 	}
 }
 
 func (j *Joke) update(w http.ResponseWriter, r *http.Request) {
 	joke, ok := r.Context().Value(middlewares.JokeParamKey{}).(*data.Joke)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	// Edits are made to the revision the client saw.
+	joke.Revision = matchedRevision(r)
+
+	_, err := j.repo.Update(r.Context(), joke.ID, joke, auth.ID)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+			return
+		}
+
+		if err == repositories.ErrStaleRevision {
+			middlewares.WriteProblem(w, r, http.StatusPreconditionFailed, "The joke has changed since")
+			return
+		}
+
+		j.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	w.Header().Set("ETag", jokeTag(joke))
+	err = writeResponse(w, r, joke)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+// current returns the stored joke patches apply to.
+func (j *Joke) current(ctx context.Context, id string) (data.Data, error) {
+	joke, err := j.repo.FetchOne(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return joke, nil
+}
+
+func (j *Joke) patch(w http.ResponseWriter, r *http.Request) {
+	joke, ok := r.Context().Value(middlewares.JokeParamKey{}).(*data.Joke)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	fields, ok := patchedFields(w, r, repositories.JokePatchFields)
 
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	_, err := j.repo.Update(r.Context(), joke.ID, joke)
+	// Edits are made to the revision the client saw.
+	joke.Revision = matchedRevision(r)
+
+	_, err := j.repo.Patch(r.Context(), joke.ID, joke, fields, auth.ID)
 
 	if err != nil {
 		if err == repositories.ErrUnknownID {
-			http.Error(w, "Unknown Joke ID", http.StatusNotFound)
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+			return
+		}
+
+		if err == repositories.ErrStaleRevision {
+			middlewares.WriteProblem(w, r, http.StatusPreconditionFailed, "The joke has changed since")
 			return
 		}
 
 		j.l.Println(err.Error())
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 		return
 	}
 
-	err = joke.ToJSON(w)
+	w.Header().Set("ETag", jokeTag(joke))
+	err = writeResponse(w, r, joke)
 
 	if err != nil {
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+// prepareNewJoke gives a joke about to be inserted a new ID and auth as its
+// author, and resets what its author can't set. Jokes by admins are approved
+// right away.
+func prepareNewJoke(joke *data.Joke, auth middlewares.AuthParams) error {
+	joke.AuthorID = &auth.ID
+	joke.Author = nil
+	joke.RejectionReason = ""
+	joke.Ratings = nil
+	joke.RatingCount = 0
+	joke.RatingSum = 0
+	joke.RatingHistogram = nil
+	joke.Reactions = data.NewJokeReactionCounts()
+	joke.FavoriteCount = 0
+	joke.CommentCount = 0
+	joke.ReportCount = 0
+	joke.Hidden = false
+	joke.Revision = 0
+	joke.DeletedAt = nil
+	joke.DeletedBy = nil
+	joke.UpdateScore()
+
+	if auth.Admin {
+		joke.Status = data.JokeStatusApproved
+	} else {
+		joke.Status = data.JokeStatusPending
 	}
+
+	return joke.GenerateID()
 }
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+type JokeComment struct {
+	l             *log.Logger
+	repo          repositories.JokeCRUD
+	vm            *middlewares.Validation
+	am            *middlewares.Auth
+	getComments   http.HandlerFunc
+	insertComment http.HandlerFunc
+	updateComment http.HandlerFunc
+	deleteComment http.HandlerFunc
+}
+
+func NewJokeComment(l *log.Logger, repo repositories.JokeCRUD, v *middlewares.Validation, auth *middlewares.Auth) *JokeComment {
+	jc := &JokeComment{l: l, repo: repo, vm: v, am: auth}
+
+	jc.getComments = middlewares.NewParam(
+		jc.am.OptionalAuth(middlewares.FetchAllQueryURL(jc.vm.JokeCommentURLValidation(jc.fetchAll))),
+		middlewares.JokeCommentParamKey{}, &data.JokeComment{})
+
+	jc.insertComment = middlewares.NewParam(jc.am.Auth(
+		jc.vm.DataValidation(jc.vm.JokeCommentURLValidation(jc.insert), middlewares.JokeCommentParamKey{}), false),
+		middlewares.JokeCommentParamKey{}, &data.JokeComment{})
+
+	jc.updateComment = middlewares.NewParam(jc.am.Auth(
+		jc.vm.DataValidation(jc.vm.JokeCommentURLValidation(jc.update), middlewares.JokeCommentParamKey{}), false),
+		middlewares.JokeCommentParamKey{}, &data.JokeComment{})
+
+	jc.deleteComment = middlewares.NewParam(
+		jc.am.Auth(jc.vm.JokeCommentURLValidation(jc.delete), false),
+		middlewares.JokeCommentParamKey{}, &data.JokeComment{})
+
+	return jc
+}
+
+func (jc *JokeComment) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodGet, "/jokes/{id}/comments", jc.getComments)
+	rt.HandleFunc(http.MethodPost, "/jokes/{id}/comments", jc.insertComment)
+	rt.HandleFunc(http.MethodPut, "/jokes/{id}/comments/{commentID}", jc.updateComment)
+	rt.HandleFunc(http.MethodDelete, "/jokes/{id}/comments/{commentID}", jc.deleteComment)
+}
+
+func (jc *JokeComment) fetchAll(w http.ResponseWriter, r *http.Request) {
+	params, ok := r.Context().Value(middlewares.FetchQueryURLParamsKey{}).(*middlewares.FetchQueryURLParams)
+	comment, okComment := r.Context().Value(middlewares.JokeCommentParamKey{}).(*data.JokeComment)
+
+	if !ok || !okComment {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	visible, err := jokeVisible(r, jc.repo, comment.JokeID)
+
+	if err != nil {
+		jc.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if !visible {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+		return
+	}
+
+	comments, cursorNext, err := jc.repo.FetchComments(r.Context(), comment.JokeID, params.Limit, params.Offset, params.Direction)
+
+	if err != nil {
+		if err == repositories.ErrInvalidOffset {
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		jc.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	var qar data.QueryAllResponse
+	qar.ResultCount = uint64(len(comments))
+	qar.CursorNext = cursorNext
+	qar.Offset = params.Offset
+	qar.Limit = params.Limit
+	qar.Results = comments
+
+	err = writeResponse(w, r, &qar)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (jc *JokeComment) insert(w http.ResponseWriter, r *http.Request) {
+	comment, ok := r.Context().Value(middlewares.JokeCommentParamKey{}).(*data.JokeComment)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	visible, err := jokeVisible(r, jc.repo, comment.JokeID)
+
+	if err != nil {
+		jc.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if !visible {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+		return
+	}
+
+	if err = comment.GenerateID(); err != nil {
+		jc.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	comment.AuthorID = auth.ID
+	comment.CreatedAt = time.Now().UTC()
+	comment.UpdatedAt = nil
+	comment.RemovedAt = nil
+	comment.RemovedBy = nil
+	comment.Replies = nil
+
+	_, err = jc.repo.InsertComment(r.Context(), comment)
+
+	if err != nil {
+		switch err {
+		case repositories.ErrUnknownID:
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+		case repositories.ErrInvalidParent:
+			middlewares.WriteProblem(w, r, http.StatusUnprocessableEntity, "Invalid parent comment")
+		default:
+			jc.l.Println(err.Error())
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		}
+
+		return
+	}
+
+	err = writeResponse(w, r, comment)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (jc *JokeComment) update(w http.ResponseWriter, r *http.Request) {
+	comment, ok := r.Context().Value(middlewares.JokeCommentParamKey{}).(*data.JokeComment)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	current, err := jc.repo.FetchComment(r.Context(), comment.JokeID, comment.ID)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Comment ID")
+			return
+		}
+
+		jc.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if current.RemovedAt != nil {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Comment ID")
+		return
+	}
+
+	if current.AuthorID != auth.ID {
+		middlewares.WriteProblem(w, r, http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	now := time.Now().UTC()
+
+	current.Text = comment.Text
+	current.UpdatedAt = &now
+
+	_, err = jc.repo.UpdateComment(r.Context(), current)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Comment ID")
+			return
+		}
+
+		jc.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	err = writeResponse(w, r, current)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (jc *JokeComment) delete(w http.ResponseWriter, r *http.Request) {
+	comment, ok := r.Context().Value(middlewares.JokeCommentParamKey{}).(*data.JokeComment)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	current, err := jc.repo.FetchComment(r.Context(), comment.JokeID, comment.ID)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Comment ID")
+			return
+		}
+
+		jc.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	var commentID string
+
+	// Authors delete their comment, which is only blanked out if it has
+	// replies so the rest of the thread keeps its context, while moderators
+	// always blank it out.
+	switch {
+	case current.AuthorID == auth.ID:
+		commentID, err = jc.repo.DeleteComment(r.Context(), current.JokeID, current.ID)
+	case auth.Admin:
+		commentID, err = jc.repo.RemoveComment(r.Context(), current.JokeID, current.ID, auth.ID)
+	default:
+		middlewares.WriteProblem(w, r, http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Comment ID")
+			return
+		}
+
+		jc.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	result := data.DeletedResponse{
+		DeletedID: commentID,
+	}
+
+	err = writeResponse(w, r, result)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
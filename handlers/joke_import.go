@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+const importBatchSize = 500
+const exportPageSize = 500
+
+// importMaxErrors caps the skipped records listed in an import report, which
+// still counts all of them.
+const importMaxErrors = 100
+
+const ndjsonType = "application/x-ndjson"
+const csvType = "text/csv"
+
+// JokeImport imports and exports jokes in bulk, streaming them as JSON arrays,
+// NDJSON or CSV.
+type JokeImport struct {
+	l           *log.Logger
+	repo        repositories.JokeCRUD
+	vm          *middlewares.Validation
+	am          *middlewares.Auth
+	importJokes http.HandlerFunc
+	exportJokes http.HandlerFunc
+}
+
+func NewJokeImport(l *log.Logger, repo repositories.JokeCRUD, v *middlewares.Validation, auth *middlewares.Auth) *JokeImport {
+	ji := &JokeImport{l: l, repo: repo, vm: v, am: auth}
+
+	ji.importJokes = ji.am.Auth(ji.importAll, true)
+	ji.exportJokes = ji.am.Auth(ji.exportAll, true)
+
+	return ji
+}
+
+func (ji *JokeImport) Routes(rt *router.Router) {
+	rt.Handle(http.MethodPost, "/jokes/import", middlewares.Streaming(ji.importJokes))
+	rt.Handle(http.MethodGet, "/jokes/export", middlewares.Streaming(ji.exportJokes))
+}
+
+var errUnsupportedImport = errors.New("jokes can be imported as application/json, " + ndjsonType + " or " + csvType)
+
+// errSkipRecord wraps the errors of records that can be skipped without
+// losing track of the document.
+type errSkipRecord struct {
+	err error
+}
+
+func (e *errSkipRecord) Error() string {
+	return e.err.Error()
+}
+
+// jokeReader reads the jokes of an import one at a time, along with the line
+// each starts on. It returns io.EOF after the last one.
+type jokeReader interface {
+	Next(joke *data.Joke) (uint64, error)
+}
+
+func newJokeReader(r io.Reader, mediaType string) (jokeReader, error) {
+	switch mediaType {
+	case "application/json":
+		return newJSONJokeReader(r)
+	case ndjsonType, "application/ndjson":
+		return &ndjsonJokeReader{r: bufio.NewReader(r)}, nil
+	case csvType:
+		return newCSVJokeReader(r)
+	}
+
+	return nil, errUnsupportedImport
+}
+
+// lineCounter counts the lines of what it reads, so that offsets reported by
+// a decoder reading ahead of them can be turned into line numbers.
+type lineCounter struct {
+	r        io.Reader
+	read     int64
+	newlines []int64
+	line     uint64
+}
+
+func (lc *lineCounter) Read(p []byte) (int, error) {
+	n, err := lc.r.Read(p)
+
+	for i := 0; i < n; i++ {
+		if p[i] == '\n' {
+			lc.newlines = append(lc.newlines, lc.read+int64(i))
+		}
+	}
+
+	lc.read += int64(n)
+
+	return n, err
+}
+
+// lineAt returns the line of offset, which can't be lower than in previous
+// calls.
+func (lc *lineCounter) lineAt(offset int64) uint64 {
+	for len(lc.newlines) > 0 && lc.newlines[0] < offset {
+		lc.newlines = lc.newlines[1:]
+		lc.line++
+	}
+
+	return lc.line + 1
+}
+
+type jsonJokeReader struct {
+	lc *lineCounter
+	d  *json.Decoder
+}
+
+func newJSONJokeReader(r io.Reader) (*jsonJokeReader, error) {
+	lc := &lineCounter{r: r}
+	d := json.NewDecoder(lc)
+
+	if token, err := d.Token(); err != nil || token != json.Delim('[') {
+		return nil, errors.New("expected a JSON array")
+	}
+
+	return &jsonJokeReader{lc, d}, nil
+}
+
+func (jjr *jsonJokeReader) Next(joke *data.Joke) (uint64, error) {
+	if !jjr.d.More() {
+		return 0, io.EOF
+	}
+
+	var raw json.RawMessage
+
+	if err := jjr.d.Decode(&raw); err != nil {
+		return jjr.lc.lineAt(jjr.d.InputOffset()), err
+	}
+
+	line := jjr.lc.lineAt(jjr.d.InputOffset() - int64(len(raw)))
+
+	if err := json.Unmarshal(raw, joke); err != nil {
+		return line, &errSkipRecord{err}
+	}
+
+	return line, nil
+}
+
+type ndjsonJokeReader struct {
+	r    *bufio.Reader
+	line uint64
+}
+
+func (njr *ndjsonJokeReader) Next(joke *data.Joke) (uint64, error) {
+	for {
+		text, err := njr.r.ReadBytes('\n')
+
+		if err != nil && (err != io.EOF || len(text) == 0) {
+			return njr.line, err
+		}
+
+		njr.line++
+
+		if len(bytes.TrimSpace(text)) == 0 {
+			continue
+		}
+
+		if err := json.Unmarshal(text, joke); err != nil {
+			return njr.line, &errSkipRecord{err}
+		}
+
+		return njr.line, nil
+	}
+}
+
+type csvJokeReader struct {
+	r      *csv.Reader
+	header []string
+	line   uint64
+}
+
+func newCSVJokeReader(r io.Reader) (*csvJokeReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+
+	if err != nil {
+		return nil, errors.New("expected a CSV header")
+	}
+
+	header = append([]string(nil), header...)
+
+	for i, column := range header {
+		header[i] = strings.TrimSpace(column)
+	}
+
+	return &csvJokeReader{r: cr, header: header, line: 1 + newlinesIn(header)}, nil
+}
+
+func (cjr *csvJokeReader) Next(joke *data.Joke) (uint64, error) {
+	record, err := cjr.r.Read()
+
+	if parseErr, ok := err.(*csv.ParseError); ok {
+		// The reader moves on to the line after the error.
+		cjr.line = uint64(parseErr.Line)
+
+		return uint64(parseErr.StartLine), &errSkipRecord{err}
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	// Quoted fields may span lines.
+	line := cjr.line + 1
+	cjr.line += 1 + newlinesIn(record)
+
+	if err := joke.FromCSVRecord(cjr.header, record); err != nil {
+		return line, &errSkipRecord{err}
+	}
+
+	return line, nil
+}
+
+func newlinesIn(record []string) uint64 {
+	n := 0
+
+	for _, field := range record {
+		n += strings.Count(field, "\n")
+	}
+
+	return uint64(n)
+}
+
+// importAll reads the jokes in the request body as a JSON array, NDJSON or
+// CSV depending on its Content-Type, and inserts the valid ones in batches.
+// Invalid records are reported by line and skipped.
+func (ji *JokeImport) importAll(w http.ResponseWriter, r *http.Request) {
+	auth, ok := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	reader, err := newJokeReader(r.Body, mediaType)
+
+	if err == errUnsupportedImport {
+		middlewares.WriteProblem(w, r, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report := &data.JokeImportReport{Errors: []*data.JokeImportError{}}
+	batch := make(data.Jokes, 0, importBatchSize)
+
+	skip := func(importErr *data.JokeImportError) {
+		report.Failed++
+
+		if len(report.Errors) < importMaxErrors {
+			report.Errors = append(report.Errors, importErr)
+		}
+	}
+
+	flush := func() error {
+		inserted, err := ji.repo.InsertMany(r.Context(), batch)
+		report.Imported += uint64(inserted)
+
+		if err != nil {
+			ji.l.Println(err.Error())
+			report.Failed += uint64(len(batch)) - uint64(inserted)
+			report.Aborted = true
+			report.Detail = "Unexpected error"
+		}
+
+		batch = batch[:0]
+
+		return err
+	}
+
+	for {
+		record := new(data.Joke)
+		line, err := reader.Next(record)
+
+		if err == io.EOF {
+			break
+		}
+
+		if skipErr, ok := err.(*errSkipRecord); ok {
+			skip(&data.JokeImportError{Line: line, Detail: skipErr.Error()})
+			continue
+		}
+
+		if err != nil {
+			report.Aborted = true
+			report.Detail = fmt.Sprintf("Malformed document at line %d", line)
+			break
+		}
+
+		joke := &data.Joke{
+			Type:        record.Type,
+			Text:        record.Text,
+			Setup:       record.Setup,
+			Delivery:    record.Delivery,
+			Explanation: record.Explanation,
+			Language:    record.Language,
+			Flags:       record.Flags,
+		}
+
+		fieldErrs, err := ji.vm.ValidateData(r.Context(), joke)
+
+		if err != nil {
+			ji.l.Println(err.Error())
+			skip(&data.JokeImportError{Line: line, Detail: "Invalid payload"})
+			continue
+		}
+
+		if len(fieldErrs) > 0 {
+			skip(&data.JokeImportError{Line: line, Errors: fieldErrs})
+			continue
+		}
+
+		if err = prepareNewJoke(joke, auth); err != nil {
+			ji.l.Println(err.Error())
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+			return
+		}
+
+		batch = append(batch, joke)
+
+		if len(batch) == importBatchSize && flush() != nil {
+			break
+		}
+	}
+
+	// The valid jokes read before a malformed part are imported too.
+	if len(batch) > 0 {
+		flush()
+	}
+
+	err = writeResponse(w, r, report)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+// exportAll streams every joke that isn't in the trash, page by page, in the
+// format given by the format query param: json (the default), ndjson or csv.
+func (ji *JokeImport) exportAll(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+
+	if format == "" {
+		format = "json"
+	}
+
+	var contentType string
+
+	switch format {
+	case "json":
+		contentType = "application/json"
+	case "ndjson":
+		contentType = ndjsonType
+	case "csv":
+		contentType = csvType
+	default:
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "Invalid format")
+		return
+	}
+
+	filter := &repositories.JokeFilter{IncludeHidden: true}
+	jokes, cursorNext, err := ji.repo.FetchAll(r.Context(), exportPageSize, "", repositories.FetchNext, filter)
+
+	if err != nil {
+		ji.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="jokes.`+format+`"`)
+
+	var cw *csv.Writer
+
+	switch format {
+	case "json":
+		io.WriteString(w, "[")
+	case "csv":
+		cw = csv.NewWriter(w)
+		cw.Write(data.JokeCSVHeader)
+	}
+
+	flusher, _ := w.(http.Flusher)
+	first := true
+
+	// The status is sent by now, so errors can only cut the export short.
+	for {
+		for _, joke := range jokes {
+			switch format {
+			case "json":
+				if !first {
+					io.WriteString(w, ",")
+				}
+
+				err = writeJSON(w, r, joke)
+			case "ndjson":
+				err = writeJSON(w, r, joke)
+			case "csv":
+				err = cw.Write(joke.CSVRecord())
+			}
+
+			if err != nil {
+				ji.l.Println(err.Error())
+				return
+			}
+
+			first = false
+		}
+
+		if cw != nil {
+			cw.Flush()
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if cursorNext == nil {
+			break
+		}
+
+		jokes, cursorNext, err = ji.repo.FetchAll(r.Context(), exportPageSize, *cursorNext, repositories.FetchNext, filter)
+
+		if err != nil {
+			ji.l.Println(err.Error())
+			return
+		}
+	}
+
+	if format == "json" {
+		io.WriteString(w, "]\n")
+	}
+}
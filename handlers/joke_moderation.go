@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+type JokeModeration struct {
+	l            *log.Logger
+	repo         repositories.JokeCRUD
+	vm           *middlewares.Validation
+	am           *middlewares.Auth
+	getQueue     http.HandlerFunc
+	moderateJoke http.HandlerFunc
+}
+
+func NewJokeModeration(l *log.Logger, repo repositories.JokeCRUD, v *middlewares.Validation, auth *middlewares.Auth) *JokeModeration {
+	jm := &JokeModeration{l: l, repo: repo, vm: v, am: auth}
+
+	jm.getQueue = jm.am.Auth(middlewares.FetchAllQueryURL(jm.queue), true)
+
+	jm.moderateJoke = middlewares.NewParam(jm.am.Auth(
+		jm.vm.PathIDValidation(
+			jm.vm.DataValidation(jm.moderate, middlewares.JokeModerationParamKey{}), middlewares.JokeModerationParamKey{}, "id"),
+		true), middlewares.JokeModerationParamKey{}, &data.JokeModeration{})
+
+	return jm
+}
+
+func (jm *JokeModeration) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodGet, "/jokes/moderation", jm.getQueue)
+	rt.HandleFunc(http.MethodPut, "/jokes/moderation/{id}", jm.moderateJoke)
+}
+
+func (jm *JokeModeration) queue(w http.ResponseWriter, r *http.Request) {
+	params, ok := r.Context().Value(middlewares.FetchQueryURLParamsKey{}).(*middlewares.FetchQueryURLParams)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	jokes, cursorNext, err := jm.repo.FetchAll(r.Context(), params.Limit, params.Offset, params.Direction, &repositories.JokeFilter{
+		Status:        data.JokeStatusPending,
+		IncludeHidden: true,
+	})
+
+	if err != nil {
+		if err == repositories.ErrInvalidOffset {
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		} else {
+			jm.l.Println(err.Error())
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+			return
+		}
+	}
+
+	var qar data.QueryAllResponse
+	qar.ResultCount = uint64(len(jokes))
+	qar.CursorNext = cursorNext
+	qar.Offset = params.Offset
+	qar.Limit = params.Limit
+	qar.Results = jokes
+
+	err = writeResponse(w, r, &qar)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (jm *JokeModeration) moderate(w http.ResponseWriter, r *http.Request) {
+	moderation, ok := r.Context().Value(middlewares.JokeModerationParamKey{}).(*data.JokeModeration)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	if moderation.Status == data.JokeStatusApproved {
+		moderation.Reason = ""
+	}
+
+	_, err := jm.repo.Moderate(r.Context(), moderation.JokeID, moderation)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+			return
+		}
+
+		jm.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	joke, err := jm.repo.FetchOne(r.Context(), moderation.JokeID)
+
+	if err != nil {
+		jm.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	err = writeResponse(w, r, joke)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
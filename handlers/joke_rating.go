@@ -1,13 +1,14 @@
 package handlers
 
 import (
-	"context"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/davq23/jokeapi/data"
 	"github.com/davq23/jokeapi/middlewares"
 	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
 )
 
 type JokeRating struct {
@@ -23,52 +24,89 @@ type JokeRating struct {
 func NewJokeRating(l *log.Logger, repo repositories.JokeCRUD, v *middlewares.Validation, auth *middlewares.Auth) *JokeRating {
 	jr := &JokeRating{l: l, repo: repo, vm: v, am: auth}
 
-	jr.getJokeRatings = middlewares.FetchAllQueryURL(jr.vm.OneIDURLValidation(jr.get, middlewares.JokeParamKey{}))
+	jr.getJokeRatings = middlewares.NewParam(
+		jr.am.OptionalAuth(middlewares.FetchAllQueryURL(jr.vm.PathIDValidation(jr.get, middlewares.JokeParamKey{}, "id"))),
+		middlewares.JokeParamKey{}, &data.Joke{})
 
-	jr.rateJoke = jr.vm.OneIDURLValidation(
-		jr.vm.DataValidation(jr.rate, middlewares.JokeRatingParamKey{}),
-		middlewares.JokeParamKey{})
+	jr.rateJoke = middlewares.NewParam(middlewares.NewParam(
+		jr.am.Auth(jr.vm.PathIDValidation(
+			jr.vm.DataValidation(jr.rate, middlewares.JokeRatingParamKey{}),
+			middlewares.JokeParamKey{}, "id"), false),
+		middlewares.JokeParamKey{}, &data.Joke{}), middlewares.JokeRatingParamKey{}, &data.JokeRating{})
 
-	jr.deleteJokeRating = jr.am.Auth(jr.vm.OneIDURLValidation(jr.delete,
-		middlewares.JokeParamKey{}), false)
+	jr.deleteJokeRating = middlewares.NewParam(middlewares.NewParam(
+		jr.am.Auth(jr.vm.PathIDValidation(
+			jr.vm.PathIDValidation(jr.delete, middlewares.JokeRatingParamKey{}, "ratingID"),
+			middlewares.JokeParamKey{}, "id"), false),
+		middlewares.JokeParamKey{}, &data.Joke{}), middlewares.JokeRatingParamKey{}, &data.JokeRating{})
 
 	return jr
 }
 
-func (jr *JokeRating) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		jkCtx := context.WithValue(r.Context(), middlewares.JokeRatingParamKey{}, &data.JokeRating{})
-		jkCtx = context.WithValue(jkCtx, middlewares.JokeParamKey{}, &data.Joke{})
-		jr.rateJoke(w, r.WithContext(jkCtx))
-	case http.MethodDelete:
-		jkCtx := context.WithValue(r.Context(), middlewares.JokeRatingParamKey{}, &data.JokeRating{})
-		jkCtx = context.WithValue(jkCtx, middlewares.JokeParamKey{}, &data.Joke{})
-		jr.deleteJokeRating(w, r.WithContext(jkCtx))
+// Routes registers the ratings under their joke, and under /jokes/ratings
+// where they used to live before routes could nest.
+func (jr *JokeRating) Routes(rt *router.Router) {
+	for _, prefix := range []string{"/jokes/{id}/ratings", "/jokes/ratings/{id}"} {
+		rt.HandleFunc(http.MethodGet, prefix, jr.getJokeRatings)
+		rt.HandleFunc(http.MethodPost, prefix, jr.rateJoke)
+		rt.HandleFunc(http.MethodPut, prefix, jr.rateJoke)
 	}
+
+	rt.HandleFunc(http.MethodDelete, "/jokes/{id}/ratings/{ratingID}", jr.deleteJokeRating)
+	rt.HandleFunc(http.MethodDelete, "/jokes/ratings/{id}/{ratingID}", jr.deleteJokeRating)
 }
 
 func (jr *JokeRating) get(w http.ResponseWriter, r *http.Request) {
-	jrating, okRating := r.Context().Value(middlewares.JokeRatingParamKey{}).(*data.JokeRating)
+	params, okParams := r.Context().Value(middlewares.FetchQueryURLParamsKey{}).(*middlewares.FetchQueryURLParams)
 	j, okID := r.Context().Value(middlewares.JokeParamKey{}).(*data.Joke)
 
-	if !okRating || !okID {
-		w.WriteHeader(http.StatusBadRequest)
+	if !okParams || !okID {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
 		return
 	}
 
-	_, _, err := jr.repo.FetchRatings(r.Context(), j.ID, 120, "", repositories.FetchNext)
+	visible, err := jokeVisible(r, jr.repo, j.ID)
 
 	if err != nil {
 		jr.l.Println(err.Error(), j.ID)
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if !visible {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
 		return
 	}
 
-	err = jrating.ToJSON(w)
+	ratings, cursorNext, err := jr.repo.FetchRatings(r.Context(), j.ID, params.Limit, params.Offset, params.Direction)
 
 	if err != nil {
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+			return
+		}
+
+		if err == repositories.ErrInvalidOffset {
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		jr.l.Println(err.Error(), j.ID)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	var qar data.QueryAllResponse
+	qar.ResultCount = uint64(len(ratings))
+	qar.CursorNext = cursorNext
+	qar.Offset = params.Offset
+	qar.Limit = params.Limit
+	qar.Results = ratings
+
+	err = writeResponse(w, r, &qar)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 	}
 }
 
@@ -77,35 +115,57 @@ func (jr *JokeRating) rate(w http.ResponseWriter, r *http.Request) {
 	j, okID := r.Context().Value(middlewares.JokeParamKey{}).(*data.Joke)
 	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
 
-	if !okRating || !okID {
-		w.WriteHeader(http.StatusBadRequest)
+	if !okRating || !okID || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
 		return
 	}
 
-	if okAuth {
-		jrating.UserID = &auth.ID
+	visible, err := jokeVisible(r, jr.repo, j.ID)
+
+	if err != nil {
+		jr.l.Println(err.Error(), j.ID)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
 	}
 
-	err := jrating.GenerateID()
+	if !visible {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+		return
+	}
+
+	jrating.UserID = &auth.ID
+	jrating.RatedAt = time.Now().UTC()
+
+	err = jrating.GenerateID()
 
 	if err != nil {
 		jr.l.Println(err.Error())
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 		return
 	}
 
 	_, err = jr.repo.RateJoke(r.Context(), j.ID, jrating)
 
 	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+			return
+		}
+
+		if err == repositories.ErrDuplicateRating {
+			middlewares.WriteProblem(w, r, http.StatusConflict, "Rating already exists")
+			return
+		}
+
 		jr.l.Println(err.Error(), j.ID)
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 		return
 	}
 
-	err = jrating.ToJSON(w)
+	err = writeResponse(w, r, jrating)
 
 	if err != nil {
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 	}
 }
 
@@ -115,9 +175,35 @@ func (jr *JokeRating) delete(w http.ResponseWriter, r *http.Request) {
 	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
 
 	if !okRating || !okJoke || !okAuth {
-		w.WriteHeader(http.StatusBadRequest)
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	objectID, err := jr.repo.DeleteRating(r.Context(), joke.ID, jrating.ID, auth.ID, auth.Admin)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Rating ID")
+			return
+		}
+
+		if err == repositories.ErrNotOwner {
+			middlewares.WriteProblem(w, r, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		jr.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 		return
 	}
 
-	jr.repo.DeleteRating(r.Context(), jrating.ID, joke.ID, auth.ID)
+	result := data.DeletedResponse{
+		DeletedID: objectID,
+	}
+
+	err = writeResponse(w, r, result)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
 }
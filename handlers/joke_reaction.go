@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+type JokeReaction struct {
+	l              *log.Logger
+	repo           repositories.JokeCRUD
+	vm             *middlewares.Validation
+	am             *middlewares.Auth
+	insertReaction http.HandlerFunc
+	deleteReaction http.HandlerFunc
+}
+
+func NewJokeReaction(l *log.Logger, repo repositories.JokeCRUD, vm *middlewares.Validation, am *middlewares.Auth) *JokeReaction {
+	jr := &JokeReaction{l: l, repo: repo, vm: vm, am: am}
+
+	jr.insertReaction = middlewares.NewParam(
+		jr.am.Auth(jr.vm.JokeReactionURLValidation(jr.insert), false),
+		middlewares.JokeReactionParamKey{}, &data.JokeReaction{})
+
+	jr.deleteReaction = middlewares.NewParam(
+		jr.am.Auth(jr.vm.JokeReactionURLValidation(jr.delete), false),
+		middlewares.JokeReactionParamKey{}, &data.JokeReaction{})
+
+	return jr
+}
+
+func (jr *JokeReaction) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodPost, "/jokes/{id}/reactions/{kind}", jr.insertReaction)
+	rt.HandleFunc(http.MethodDelete, "/jokes/{id}/reactions/{kind}", jr.deleteReaction)
+}
+
+func (jr *JokeReaction) insert(w http.ResponseWriter, r *http.Request) {
+	reaction, ok := r.Context().Value(middlewares.JokeReactionParamKey{}).(*data.JokeReaction)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	visible, err := jokeVisible(r, jr.repo, reaction.JokeID)
+
+	if err != nil {
+		jr.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if !visible {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+		return
+	}
+
+	reaction.UserID = auth.ID
+	reaction.CreatedAt = time.Now().UTC()
+
+	_, err = jr.repo.AddReaction(r.Context(), reaction)
+
+	if err != nil {
+		switch err {
+		case repositories.ErrUnknownID:
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+		case repositories.ErrDuplicateReaction:
+			middlewares.WriteProblem(w, r, http.StatusConflict, "Reaction already added")
+		default:
+			jr.l.Println(err.Error())
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		}
+
+		return
+	}
+
+	err = writeResponse(w, r, reaction)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (jr *JokeReaction) delete(w http.ResponseWriter, r *http.Request) {
+	reaction, ok := r.Context().Value(middlewares.JokeReactionParamKey{}).(*data.JokeReaction)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	kind, err := jr.repo.RemoveReaction(r.Context(), reaction.JokeID, auth.ID, reaction.Kind)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Reaction not found")
+			return
+		}
+
+		jr.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	result := data.DeletedResponse{
+		DeletedID: kind,
+	}
+
+	err = writeResponse(w, r, result)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+type JokeRevision struct {
+	l            *log.Logger
+	repo         repositories.JokeCRUD
+	vm           *middlewares.Validation
+	am           *middlewares.Auth
+	getRevisions http.HandlerFunc
+	revertJoke   http.HandlerFunc
+}
+
+func NewJokeRevision(l *log.Logger, repo repositories.JokeCRUD, v *middlewares.Validation, auth *middlewares.Auth) *JokeRevision {
+	jr := &JokeRevision{l: l, repo: repo, vm: v, am: auth}
+
+	jr.getRevisions = middlewares.NewParam(
+		jr.am.Auth(jr.vm.JokeRevisionURLValidation(jr.fetchAll), true),
+		middlewares.JokeRevisionParamKey{}, &data.JokeRevision{})
+
+	jr.revertJoke = middlewares.NewParam(
+		jr.am.Auth(jr.vm.JokeRevisionURLValidation(jr.revert), true),
+		middlewares.JokeRevisionParamKey{}, &data.JokeRevision{})
+
+	return jr
+}
+
+func (jr *JokeRevision) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodGet, "/jokes/{id}/revisions", jr.getRevisions)
+	rt.HandleFunc(http.MethodPost, "/jokes/{id}/revisions/{rev}/revert", jr.revertJoke)
+}
+
+func (jr *JokeRevision) fetchAll(w http.ResponseWriter, r *http.Request) {
+	revision, ok := r.Context().Value(middlewares.JokeRevisionParamKey{}).(*data.JokeRevision)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	if _, err := jr.repo.FetchOne(r.Context(), revision.JokeID); err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+			return
+		}
+
+		jr.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	revisions, err := jr.repo.FetchRevisions(r.Context(), revision.JokeID)
+
+	if err != nil {
+		jr.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	err = writeResponse(w, r, revisions)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (jr *JokeRevision) revert(w http.ResponseWriter, r *http.Request) {
+	revision, ok := r.Context().Value(middlewares.JokeRevisionParamKey{}).(*data.JokeRevision)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	joke, err := jr.repo.FetchOne(r.Context(), revision.JokeID)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+			return
+		}
+
+		jr.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if revision.Revision > joke.Revision {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown revision")
+		return
+	}
+
+	revisions, err := jr.repo.FetchRevisions(r.Context(), revision.JokeID)
+
+	if err != nil {
+		jr.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	joke.RevertTo(revisions, revision.Revision)
+
+	_, err = jr.repo.Update(r.Context(), joke.ID, joke, auth.ID)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+			return
+		}
+
+		if err == repositories.ErrStaleRevision {
+			middlewares.WriteProblem(w, r, http.StatusConflict, "The joke was edited meanwhile")
+			return
+		}
+
+		jr.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	err = writeResponse(w, r, joke)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/jobs"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/router"
+)
+
+type Leaderboard struct {
+	l      *log.Logger
+	boards *jobs.Leaderboards
+}
+
+func NewLeaderboard(l *log.Logger, boards *jobs.Leaderboards) *Leaderboard {
+	return &Leaderboard{l: l, boards: boards}
+}
+
+func (lh *Leaderboard) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodGet, "/jokes/top", lh.top)
+	rt.HandleFunc(http.MethodGet, "/jokes/trending", lh.trending)
+}
+
+// top serves /jokes/top?period=day|week|month|all&lang=
+func (lh *Leaderboard) top(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+
+	switch period {
+	case "":
+		period = data.LeaderboardAll
+	case data.LeaderboardDay, data.LeaderboardWeek, data.LeaderboardMonth, data.LeaderboardAll:
+	default:
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "Invalid period")
+		return
+	}
+
+	lh.serve(w, r, period)
+}
+
+// trending serves /jokes/trending?lang=
+func (lh *Leaderboard) trending(w http.ResponseWriter, r *http.Request) {
+	lh.serve(w, r, data.LeaderboardTrending)
+}
+
+func (lh *Leaderboard) serve(w http.ResponseWriter, r *http.Request, period string) {
+	board, ok := lh.boards.Get(period, r.URL.Query().Get("lang"))
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusServiceUnavailable, "Leaderboards are not ready yet")
+		return
+	}
+
+	err := writeResponse(w, r, board)
+
+	if err != nil {
+		lh.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
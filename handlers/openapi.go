@@ -0,0 +1,557 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/davq23/jokeapi/codec"
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/openapi"
+	"github.com/davq23/jokeapi/router"
+)
+
+var uuidParam = &openapi.Schema{Type: "string", Format: "uuid"}
+
+var pageQuery = []*openapi.Parameter{
+	{Name: "offset", In: "query", Description: "Cursor returned as cursor_next by the previous page", Schema: &openapi.Schema{Type: "string"}},
+	{Name: "limit", In: "query", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+	{Name: "direction", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []string{"next", "last"}}},
+}
+
+var ifMatchHeader = []*openapi.Parameter{
+	{Name: "If-Match", In: "header", Required: true, Description: "ETag of the joke when last fetched", Schema: &openapi.Schema{Type: "string"}},
+}
+
+var jokeFieldsQuery = []*openapi.Parameter{
+	{Name: "fields", In: "query", Description: "Comma separated fields to trim the jokes to", Schema: &openapi.Schema{Type: "string"}},
+	{Name: "expand", In: "query", Description: `Comma separated "author" and "ratings", the first 10`, Schema: &openapi.Schema{Type: "string"}},
+}
+
+var jokeFilterQuery = append([]*openapi.Parameter{
+	{Name: "type", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []string{data.JokeTypeSingle, data.JokeTypeTwoPart}}},
+	{Name: "safe", In: "query", Description: "Leave out jokes with any flag", Schema: &openapi.Schema{Type: "boolean"}},
+	{Name: "blacklistFlags", In: "query", Description: "Comma separated flags to leave out", Schema: &openapi.Schema{Type: "string"}},
+	{Name: "sort", In: "query", Description: `"score" or "reactions.<kind>"`, Schema: &openapi.Schema{Type: "string"}},
+}, append(pageQuery, jokeFieldsQuery...)...)
+
+var langQuery = &openapi.Parameter{Name: "lang", In: "query", Schema: &openapi.Schema{Type: "string"}}
+
+var ratingsRoute = &openapi.Route{
+	Summary:  "Rate a joke, replacing the rating of the user if any",
+	Tag:      "ratings",
+	Auth:     openapi.AuthUser,
+	Params:   map[string]*openapi.Schema{"id": uuidParam},
+	Request:  data.JokeRating{},
+	Response: data.JokeRating{},
+	Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity},
+}
+
+// routeDocs documents the routes registered by the handlers, keyed by
+// method and pattern.
+var routeDocs = map[string]*openapi.Route{
+	"POST /login": {
+		Summary:  "Log in with email and password",
+		Tag:      "auth",
+		Request:  data.User{},
+		Response: data.TokenResponse{},
+		Errors:   []int{http.StatusUnauthorized, http.StatusUnprocessableEntity},
+	},
+
+	"GET /jokes": {
+		Summary:  "List approved jokes",
+		Tag:      "jokes",
+		Query:    jokeFilterQuery,
+		Response: data.Joke{},
+		List:     true,
+		Errors:   []int{http.StatusBadRequest},
+	},
+	"POST /jokes": {
+		Summary:  "Submit a joke for moderation",
+		Tag:      "jokes",
+		Auth:     openapi.AuthUser,
+		Request:  data.Joke{},
+		Response: data.Joke{},
+		Errors:   []int{http.StatusUnprocessableEntity},
+	},
+	"GET /jokes/{id}": {
+		Summary:  "Fetch a joke",
+		Tag:      "jokes",
+		Auth:     openapi.AuthOptional,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Query:    jokeFieldsQuery,
+		Response: data.Joke{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	"PUT /jokes/{id}": {
+		Summary:  "Edit a joke",
+		Tag:      "jokes",
+		Auth:     openapi.AuthAdmin,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Headers:  ifMatchHeader,
+		Request:  data.Joke{},
+		Response: data.Joke{},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed,
+			http.StatusUnprocessableEntity, http.StatusPreconditionRequired},
+	},
+	"PATCH /jokes/{id}": {
+		Summary:  "Patch a joke",
+		Tag:      "jokes",
+		Auth:     openapi.AuthAdmin,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Headers:  ifMatchHeader,
+		Request:  data.Joke{},
+		Patch:    true,
+		Response: data.Joke{},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed,
+			http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity, http.StatusPreconditionRequired},
+	},
+	"DELETE /jokes/{id}": {
+		Summary:  "Move a joke to the trash",
+		Tag:      "jokes",
+		Auth:     openapi.AuthUser,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Headers:  ifMatchHeader,
+		Response: data.DeletedResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound,
+			http.StatusPreconditionFailed, http.StatusPreconditionRequired},
+	},
+	"GET /jokes/top": {
+		Summary: "Top rated jokes",
+		Tag:     "leaderboards",
+		Query: []*openapi.Parameter{
+			{Name: "period", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []string{
+				data.LeaderboardDay, data.LeaderboardWeek, data.LeaderboardMonth, data.LeaderboardAll}}},
+			langQuery,
+		},
+		Response: data.Leaderboard{},
+		Errors:   []int{http.StatusBadRequest, http.StatusServiceUnavailable},
+	},
+	"GET /jokes/trending": {
+		Summary:  "Trending jokes",
+		Tag:      "leaderboards",
+		Query:    []*openapi.Parameter{langQuery},
+		Response: data.Leaderboard{},
+		Errors:   []int{http.StatusServiceUnavailable},
+	},
+
+	"POST /jokes/import": {
+		Summary:  "Import jokes in bulk as a JSON array, NDJSON or CSV",
+		Tag:      "jokes",
+		Auth:     openapi.AuthAdmin,
+		Request:  data.Jokes{},
+		Response: data.JokeImportReport{},
+		Errors:   []int{http.StatusBadRequest, http.StatusUnsupportedMediaType},
+	},
+	"GET /jokes/export": {
+		Summary: "Export every joke as a JSON array, NDJSON or CSV",
+		Tag:     "jokes",
+		Auth:    openapi.AuthAdmin,
+		Query: []*openapi.Parameter{
+			{Name: "format", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []string{"json", "ndjson", "csv"}}},
+		},
+		Response: data.Jokes{},
+		Errors:   []int{http.StatusBadRequest},
+	},
+
+	"GET /jokes/{id}/revisions": {
+		Summary:  "List the revisions of a joke",
+		Tag:      "revisions",
+		Auth:     openapi.AuthAdmin,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Response: data.JokeRevisions{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	"POST /jokes/{id}/revisions/{rev}/revert": {
+		Summary:  "Revert a joke to a revision",
+		Tag:      "revisions",
+		Auth:     openapi.AuthAdmin,
+		Params:   map[string]*openapi.Schema{"id": uuidParam, "rev": {Type: "integer", Format: "int64"}},
+		Response: data.Joke{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+
+	"GET /jokes/{id}/comments": {
+		Summary:  "List the comments of a joke, threaded",
+		Tag:      "comments",
+		Auth:     openapi.AuthOptional,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Query:    pageQuery,
+		Response: data.JokeComment{},
+		List:     true,
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	"POST /jokes/{id}/comments": {
+		Summary:  "Comment on a joke or reply to a comment",
+		Tag:      "comments",
+		Auth:     openapi.AuthUser,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Request:  data.JokeComment{},
+		Response: data.JokeComment{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	"PUT /jokes/{id}/comments/{commentID}": {
+		Summary:  "Edit a comment",
+		Tag:      "comments",
+		Auth:     openapi.AuthUser,
+		Params:   map[string]*openapi.Schema{"id": uuidParam, "commentID": uuidParam},
+		Request:  data.JokeComment{},
+		Response: data.JokeComment{},
+		Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	"DELETE /jokes/{id}/comments/{commentID}": {
+		Summary:  "Delete a comment, or remove it as a moderator",
+		Tag:      "comments",
+		Auth:     openapi.AuthUser,
+		Params:   map[string]*openapi.Schema{"id": uuidParam, "commentID": uuidParam},
+		Response: data.DeletedResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+	},
+
+	"POST /jokes/{id}/reactions/{kind}": {
+		Summary: "React to a joke",
+		Tag:     "reactions",
+		Auth:    openapi.AuthUser,
+		Params: map[string]*openapi.Schema{
+			"id": uuidParam, "kind": {Type: "string", Enum: data.JokeReactionKinds}},
+		Response: data.JokeReaction{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	},
+	"DELETE /jokes/{id}/reactions/{kind}": {
+		Summary: "Take back a reaction",
+		Tag:     "reactions",
+		Auth:    openapi.AuthUser,
+		Params: map[string]*openapi.Schema{
+			"id": uuidParam, "kind": {Type: "string", Enum: data.JokeReactionKinds}},
+		Response: data.DeletedResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+
+	"POST /jokes/{id}/reports": {
+		Summary:  "Report a joke",
+		Tag:      "reports",
+		Auth:     openapi.AuthUser,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Request:  data.Report{},
+		Response: data.Report{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	"POST /jokes/{id}/comments/{commentID}/reports": {
+		Summary:  "Report a comment",
+		Tag:      "reports",
+		Auth:     openapi.AuthUser,
+		Params:   map[string]*openapi.Schema{"id": uuidParam, "commentID": uuidParam},
+		Request:  data.Report{},
+		Response: data.Report{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	"GET /reports": {
+		Summary: "List reports",
+		Tag:     "reports",
+		Auth:    openapi.AuthAdmin,
+		Query: append([]*openapi.Parameter{
+			{Name: "status", In: "query", Schema: &openapi.Schema{Type: "string"}},
+		}, pageQuery...),
+		Response: data.Report{},
+		List:     true,
+		Errors:   []int{http.StatusBadRequest},
+	},
+	"GET /reports/{id}": {
+		Summary:  "Fetch a report",
+		Tag:      "reports",
+		Auth:     openapi.AuthAdmin,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Response: data.Report{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	"PUT /reports/{id}": {
+		Summary:  "Resolve a report and every open report on the same target",
+		Tag:      "reports",
+		Auth:     openapi.AuthAdmin,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Request:  data.ReportResolution{},
+		Response: data.Report{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+
+	"GET /jokes/{id}/ratings": {
+		Summary:  "List the ratings of a joke",
+		Tag:      "ratings",
+		Auth:     openapi.AuthOptional,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Query:    pageQuery,
+		Response: data.JokeRating{},
+		List:     true,
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	"POST /jokes/{id}/ratings": ratingsRoute,
+	"PUT /jokes/{id}/ratings":  ratingsRoute,
+	"DELETE /jokes/{id}/ratings/{ratingID}": {
+		Summary:  "Delete a rating",
+		Tag:      "ratings",
+		Auth:     openapi.AuthUser,
+		Params:   map[string]*openapi.Schema{"id": uuidParam, "ratingID": uuidParam},
+		Response: data.DeletedResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+
+	"GET /jokes/moderation": {
+		Summary:  "List the jokes waiting for moderation",
+		Tag:      "moderation",
+		Auth:     openapi.AuthAdmin,
+		Query:    pageQuery,
+		Response: data.Joke{},
+		List:     true,
+		Errors:   []int{http.StatusBadRequest},
+	},
+	"PUT /jokes/moderation/{id}": {
+		Summary:  "Approve or reject a joke",
+		Tag:      "moderation",
+		Auth:     openapi.AuthAdmin,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Request:  data.JokeModeration{},
+		Response: data.Joke{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+
+	"GET /users": {
+		Summary:  "List users",
+		Tag:      "users",
+		Auth:     openapi.AuthAdmin,
+		Query:    pageQuery,
+		Response: data.User{},
+		List:     true,
+		Errors:   []int{http.StatusBadRequest},
+	},
+	"POST /users": {
+		Summary:  "Create a user",
+		Tag:      "users",
+		Auth:     openapi.AuthAdmin,
+		Request:  data.User{},
+		Response: data.User{},
+		Errors:   []int{http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	"GET /users/{id}": {
+		Summary:  `Fetch a user by ID, email or "me"`,
+		Tag:      "users",
+		Auth:     openapi.AuthUser,
+		Response: data.User{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	"PUT /users/{id}": {
+		Summary:  "Edit a user",
+		Tag:      "users",
+		Auth:     openapi.AuthUser,
+		Request:  data.User{},
+		Response: data.User{},
+		Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	"PATCH /users/{id}": {
+		Summary:  "Patch a user",
+		Tag:      "users",
+		Auth:     openapi.AuthUser,
+		Request:  data.User{},
+		Patch:    true,
+		Response: data.User{},
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict,
+			http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity},
+	},
+	"DELETE /users/{id}": {
+		Summary:  "Move a user to the trash",
+		Tag:      "users",
+		Auth:     openapi.AuthAdmin,
+		Response: data.DeletedResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+
+	"GET /users/{id}/favorites": {
+		Summary:  "List the favorite jokes of a user",
+		Tag:      "favorites",
+		Auth:     openapi.AuthUser,
+		Query:    pageQuery,
+		Response: data.Joke{},
+		List:     true,
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	"POST /users/{id}/favorites/{jokeID}": {
+		Summary:  "Add a joke to the favorites of a user",
+		Tag:      "favorites",
+		Auth:     openapi.AuthUser,
+		Params:   map[string]*openapi.Schema{"jokeID": uuidParam},
+		Response: data.JokeFavorite{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	},
+	"DELETE /users/{id}/favorites/{jokeID}": {
+		Summary:  "Remove a joke from the favorites of a user",
+		Tag:      "favorites",
+		Auth:     openapi.AuthUser,
+		Params:   map[string]*openapi.Schema{"jokeID": uuidParam},
+		Response: data.DeletedResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+
+	"GET /collections": {
+		Summary:  "List the collections of the user",
+		Tag:      "collections",
+		Auth:     openapi.AuthUser,
+		Query:    pageQuery,
+		Response: data.Collection{},
+		List:     true,
+		Errors:   []int{http.StatusBadRequest},
+	},
+	"POST /collections": {
+		Summary:  "Create a collection",
+		Tag:      "collections",
+		Auth:     openapi.AuthUser,
+		Request:  data.Collection{},
+		Response: data.Collection{},
+		Errors:   []int{http.StatusUnprocessableEntity},
+	},
+	"GET /collections/shared/{slug}": {
+		Summary:  "Fetch a public or unlisted collection by its share link",
+		Tag:      "collections",
+		Response: data.Collection{},
+		Errors:   []int{http.StatusNotFound},
+	},
+	"GET /collections/{id}": {
+		Summary:  "Fetch a collection",
+		Tag:      "collections",
+		Auth:     openapi.AuthOptional,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Response: data.Collection{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	"PUT /collections/{id}": {
+		Summary:  "Edit a collection",
+		Tag:      "collections",
+		Auth:     openapi.AuthUser,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Request:  data.Collection{},
+		Response: data.Collection{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	"DELETE /collections/{id}": {
+		Summary:  "Delete a collection",
+		Tag:      "collections",
+		Auth:     openapi.AuthUser,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Response: data.DeletedResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	"PUT /collections/{id}/order": {
+		Summary:  "Reorder the jokes of a collection",
+		Tag:      "collections",
+		Auth:     openapi.AuthUser,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Request:  data.CollectionOrder{},
+		Response: data.Collection{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+
+	"GET /trash/jokes": {
+		Summary:  "List the jokes in the trash",
+		Tag:      "trash",
+		Auth:     openapi.AuthAdmin,
+		Query:    pageQuery,
+		Response: data.Joke{},
+		List:     true,
+		Errors:   []int{http.StatusBadRequest},
+	},
+	"GET /trash/users": {
+		Summary:  "List the users in the trash",
+		Tag:      "trash",
+		Auth:     openapi.AuthAdmin,
+		Query:    pageQuery,
+		Response: data.User{},
+		List:     true,
+		Errors:   []int{http.StatusBadRequest},
+	},
+	"POST /trash/jokes/{id}/restore": {
+		Summary:  "Restore a joke from the trash",
+		Tag:      "trash",
+		Auth:     openapi.AuthAdmin,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Response: data.RestoredResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	"POST /trash/users/{id}/restore": {
+		Summary:  "Restore a user from the trash",
+		Tag:      "trash",
+		Auth:     openapi.AuthAdmin,
+		Params:   map[string]*openapi.Schema{"id": uuidParam},
+		Response: data.RestoredResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+
+	"POST /batch": {
+		Summary:  "Run a list of requests, optionally atomically",
+		Tag:      "batch",
+		Auth:     openapi.AuthUser,
+		Request:  data.Batch{},
+		Response: data.BatchResponse{},
+		Errors:   []int{http.StatusUnprocessableEntity},
+	},
+}
+
+// routeAliases maps the routes kept for compatibility to the routes they
+// alias, which document them.
+var routeAliases = map[string]string{
+	"GET /jokes/ratings/{id}":               "GET /jokes/{id}/ratings",
+	"POST /jokes/ratings/{id}":              "POST /jokes/{id}/ratings",
+	"PUT /jokes/ratings/{id}":               "PUT /jokes/{id}/ratings",
+	"DELETE /jokes/ratings/{id}/{ratingID}": "DELETE /jokes/{id}/ratings/{ratingID}",
+}
+
+type OpenAPI struct {
+	l   *log.Logger
+	doc *openapi.Document
+}
+
+// NewOpenAPI describes the routes registered on api so far, served under
+// every version in versions.
+func NewOpenAPI(l *log.Logger, api *router.Router, versions ...string) *OpenAPI {
+	builder := openapi.NewBuilder(openapi.Info{Title: "Joke API", Version: "1.0.0"}, data.QueryAllResponse{}, data.Problem{})
+
+	builder.SetMediaTypes(codec.JSON.MediaType(), codec.XML.MediaType(), codec.YAML.MediaType())
+
+	for _, version := range versions {
+		builder.AddServer(version, "")
+	}
+
+	for _, route := range api.Routes() {
+		key := route.Method + " " + route.Pattern
+
+		if alias, ok := routeAliases[key]; ok {
+			key = alias
+		}
+
+		doc, ok := routeDocs[key]
+
+		if !ok {
+			l.Println("undocumented route", route.Method, route.Pattern)
+			continue
+		}
+
+		builder.AddRoute(route.Method, route.Pattern, doc)
+	}
+
+	return &OpenAPI{l: l, doc: builder.Document()}
+}
+
+func (o *OpenAPI) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodGet, "/openapi.json", o.serve)
+}
+
+// Spec returns the OpenAPI document.
+func (o *OpenAPI) Spec() *openapi.Document {
+	return o.doc
+}
+
+func (o *OpenAPI) serve(w http.ResponseWriter, r *http.Request) {
+	err := o.doc.ToJSON(w)
+
+	if err != nil {
+		o.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+type Report struct {
+	l             *log.Logger
+	repo          repositories.JokeCRUD
+	vm            *middlewares.Validation
+	am            *middlewares.Auth
+	hideThreshold uint64
+	getReport     http.HandlerFunc
+	getReports    http.HandlerFunc
+	insertReport  http.HandlerFunc
+	resolveReport http.HandlerFunc
+}
+
+// NewReport builds the reports handler. Jokes reported by hideThreshold
+// distinct users are hidden until a moderator resolves the reports; zero
+// disables automatic hiding.
+func NewReport(l *log.Logger, repo repositories.JokeCRUD, vm *middlewares.Validation, am *middlewares.Auth, hideThreshold uint64) *Report {
+	rh := &Report{l: l, repo: repo, vm: vm, am: am, hideThreshold: hideThreshold}
+
+	rh.insertReport = middlewares.NewParam(
+		rh.am.Auth(rh.vm.DataValidation(rh.vm.ReportURLValidation(rh.insert), middlewares.ReportParamKey{}), false),
+		middlewares.ReportParamKey{}, &data.Report{})
+
+	rh.getReports = rh.am.Auth(middlewares.FetchAllQueryURL(rh.fetchAll), true)
+
+	rh.getReport = middlewares.NewParam(
+		rh.am.Auth(rh.vm.PathIDValidation(rh.fetchOne, middlewares.ReportParamKey{}, "id"), true),
+		middlewares.ReportParamKey{}, &data.Report{})
+
+	rh.resolveReport = middlewares.NewParam(rh.am.Auth(
+		rh.vm.PathIDValidation(
+			rh.vm.DataValidation(rh.resolve, middlewares.ReportResolutionParamKey{}), middlewares.ReportResolutionParamKey{}, "id"),
+		true), middlewares.ReportResolutionParamKey{}, &data.ReportResolution{})
+
+	return rh
+}
+
+func (rh *Report) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodPost, "/jokes/{id}/reports", rh.insertReport)
+	rt.HandleFunc(http.MethodPost, "/jokes/{id}/comments/{commentID}/reports", rh.insertReport)
+	rt.HandleFunc(http.MethodGet, "/reports", rh.getReports)
+	rt.HandleFunc(http.MethodGet, "/reports/{id}", rh.getReport)
+	rt.HandleFunc(http.MethodPut, "/reports/{id}", rh.resolveReport)
+}
+
+func (rh *Report) insert(w http.ResponseWriter, r *http.Request) {
+	report, ok := r.Context().Value(middlewares.ReportParamKey{}).(*data.Report)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	visible, err := jokeVisible(r, rh.repo, report.JokeID)
+
+	if err != nil {
+		rh.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if !visible {
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke ID")
+		return
+	}
+
+	if err = report.GenerateID(); err != nil {
+		rh.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	report.ReporterID = auth.ID
+	report.Status = data.ReportStatusOpen
+	report.CreatedAt = time.Now().UTC()
+	report.ResolvedAt = nil
+	report.ResolvedBy = nil
+	report.ResolutionNote = ""
+
+	_, err = rh.repo.InsertReport(r.Context(), report, rh.hideThreshold)
+
+	if err != nil {
+		switch err {
+		case repositories.ErrUnknownID:
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Joke or Comment ID")
+		case repositories.ErrDuplicateReport:
+			middlewares.WriteProblem(w, r, http.StatusConflict, "Already reported")
+		default:
+			rh.l.Println(err.Error())
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		}
+
+		return
+	}
+
+	err = writeResponse(w, r, report)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (rh *Report) fetchAll(w http.ResponseWriter, r *http.Request) {
+	params, ok := r.Context().Value(middlewares.FetchQueryURLParamsKey{}).(*middlewares.FetchQueryURLParams)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+
+	switch status {
+	case "":
+		status = data.ReportStatusOpen
+	case "all":
+		status = ""
+	case data.ReportStatusOpen, data.ReportStatusUpheld, data.ReportStatusDismissed:
+	default:
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	reports, cursorNext, err := rh.repo.FetchReports(r.Context(), status, params.Limit, params.Offset, params.Direction)
+
+	if err != nil {
+		if err == repositories.ErrInvalidOffset {
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		rh.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	var qar data.QueryAllResponse
+	qar.ResultCount = uint64(len(reports))
+	qar.CursorNext = cursorNext
+	qar.Offset = params.Offset
+	qar.Limit = params.Limit
+	qar.Results = reports
+
+	err = writeResponse(w, r, &qar)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (rh *Report) fetchOne(w http.ResponseWriter, r *http.Request) {
+	report, ok := r.Context().Value(middlewares.ReportParamKey{}).(*data.Report)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	report, err := rh.repo.FetchReport(r.Context(), report.ID)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Report ID")
+			return
+		}
+
+		rh.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	err = writeResponse(w, r, report)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (rh *Report) resolve(w http.ResponseWriter, r *http.Request) {
+	resolution, ok := r.Context().Value(middlewares.ReportResolutionParamKey{}).(*data.ReportResolution)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	report, err := rh.repo.FetchReport(r.Context(), resolution.ReportID)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown Report ID")
+			return
+		}
+
+		rh.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	if report.Status != data.ReportStatusOpen {
+		middlewares.WriteProblem(w, r, http.StatusConflict, "Report already resolved")
+		return
+	}
+
+	// Upheld reports take the reported content down before closing them.
+	if resolution.Status == data.ReportStatusUpheld {
+		if report.TargetType == data.ReportTargetComment {
+			_, err = rh.repo.RemoveComment(r.Context(), report.JokeID, *report.CommentID, auth.ID)
+		} else {
+			_, err = rh.repo.Moderate(r.Context(), report.JokeID, &data.JokeModeration{
+				JokeID: report.JokeID,
+				Status: data.JokeStatusRejected,
+				Reason: "Reported as " + report.Reason,
+			})
+		}
+
+		if err != nil && err != repositories.ErrUnknownID {
+			rh.l.Println(err.Error())
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+			return
+		}
+	}
+
+	_, err = rh.repo.ResolveReports(r.Context(), report, resolution, auth.ID)
+
+	if err != nil {
+		rh.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	report, err = rh.repo.FetchReport(r.Context(), report.ID)
+
+	if err != nil {
+		rh.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	err = writeResponse(w, r, report)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
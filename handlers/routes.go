@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/davq23/jokeapi/jobs"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+// Backends are what the handlers of the API are backed by. They may be left
+// nil to only register the routes, e.g. to document them.
+type Backends struct {
+	Jokes               repositories.JokeCRUD
+	Users               repositories.UserCRUD
+	Collections         repositories.CollectionCRUD
+	Transactor          repositories.Transactor
+	Leaderboards        *jobs.Leaderboards
+	ReportHideThreshold uint64
+}
+
+// RegisterRoutes registers the routes of every handler of the API on api,
+// POST /batch last as it runs its operations through the others.
+func RegisterRoutes(api *router.Router, l *log.Logger, b Backends, vm *middlewares.Validation, am *middlewares.Auth) {
+	NewJoke(l, b.Jokes, b.Users, vm, am).Routes(api)
+	NewJokeRating(l, b.Jokes, vm, am).Routes(api)
+	NewJokeModeration(l, b.Jokes, vm, am).Routes(api)
+	NewLeaderboard(l, b.Leaderboards).Routes(api)
+	NewUser(l, b.Users, b.Jokes, vm, am).Routes(api)
+	NewCollection(l, b.Collections, b.Jokes, vm, am).Routes(api)
+	NewReport(l, b.Jokes, vm, am, b.ReportHideThreshold).Routes(api)
+	NewTrash(l, b.Jokes, b.Users, vm, am).Routes(api)
+	NewAuth(am, l, b.Users, vm).Routes(api)
+	NewBatch(l, api, b.Transactor, vm, am).Routes(api)
+}
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/handlers"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/router"
+	"github.com/go-playground/validator/v10"
+)
+
+func TestBatchAPIVersion(t *testing.T) {
+	api, auth := jokeRoutes(t, newFakeJokes(), nil)
+
+	l := log.New(ioutil.Discard, "", 0)
+	handlers.NewBatch(l, api, nil, middlewares.NewValidation(l, validator.New()), middlewares.NewAuth(l, []byte("secret"))).Routes(api)
+
+	rt := router.New()
+
+	for _, version := range []int{data.APIVersion1, data.APIVersion2} {
+		version := version
+
+		rt.Mount("/v"+strconv.Itoa(version), api, func(next http.Handler) http.Handler {
+			return middlewares.APIVersion(next, version)
+		})
+	}
+
+	for version, idField := range map[string]string{"v1": "joke_id", "v2": "id"} {
+		r := httptest.NewRequest(http.MethodPost, "/"+version+"/batch",
+			strings.NewReader(`{"operations": [{"method": "GET", "path": "/jokes/`+jokeID+`"}]}`))
+		r.Header.Set("Authorization", auth)
+
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+
+		var response struct {
+			Results []struct {
+				Status int                    `json:"status"`
+				Body   map[string]interface{} `json:"body"`
+			} `json:"results"`
+		}
+
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || len(response.Results) != 1 {
+			t.Fatalf("%s: got %d: %s", version, w.Code, w.Body.String())
+		}
+
+		if result := response.Results[0]; result.Status != http.StatusOK || result.Body[idField] != jokeID {
+			t.Errorf("%s: got %d %v, want the joke identified by %s", version, result.Status, result.Body, idField)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/handlers"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+func (f *fakeJokes) AddFavorite(ctx context.Context, favorite *data.JokeFavorite) (string, error) {
+	f.favorites = append(f.favorites, favorite.JokeID)
+
+	return favorite.ID, nil
+}
+
+func (f *fakeJokes) FetchFavorites(ctx context.Context, userID string, limit uint64, offset string, direction repositories.FetchDirection) (data.Jokes, *string, error) {
+	joke := *f.joke
+
+	return data.Jokes{&joke}, nil, nil
+}
+
+func favoriteRoutes(repo *fakeJokes) *router.Router {
+	l, vm, am := middlewaresForTest()
+
+	api := router.New()
+	handlers.NewFavorite(l, repo, vm, am).Routes(api)
+
+	return api
+}
+
+func TestFavoriteVisibility(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		userID string
+		admin  bool
+		want   int
+	}{
+		{"approved joke", data.JokeStatusApproved, otherID, false, http.StatusOK},
+		{"pending joke", data.JokeStatusPending, otherID, false, http.StatusNotFound},
+		{"rejected joke", data.JokeStatusRejected, otherID, false, http.StatusNotFound},
+		{"own pending joke", data.JokeStatusPending, authorID, false, http.StatusOK},
+		{"pending joke by an admin", data.JokeStatusPending, otherID, true, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		repo := newFakeJokes()
+		repo.joke.Status = test.status
+		api := favoriteRoutes(repo)
+		auth := bearer(t, test.userID, test.admin)
+
+		w := serve(api, http.MethodPost, "/users/"+test.userID+"/favorites/"+jokeID, auth, "")
+
+		if w.Code != test.want {
+			t.Errorf("%s: got %d, want %d: %s", test.name, w.Code, test.want, w.Body.String())
+		}
+
+		if test.want != http.StatusOK && len(repo.favorites) != 0 {
+			t.Errorf("%s: added the joke to the favorites", test.name)
+		}
+
+		// The joke may have been added before it went back to moderation.
+		w = serve(api, http.MethodGet, "/users/"+test.userID+"/favorites", auth, "")
+
+		qar := &struct {
+			Results []map[string]interface{} `json:"results"`
+		}{}
+
+		if err := json.Unmarshal(w.Body.Bytes(), qar); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", test.name, w.Code, w.Body.String())
+		}
+
+		if listed := len(qar.Results) == 1; listed != (test.want == http.StatusOK) {
+			t.Errorf("%s: got %d favorites", test.name, len(qar.Results))
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/handlers"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+const commentID = "e1f2a3b4-c5d6-4e7f-8a9b-0c1d2e3f4a5b"
+
+func (f *fakeJokes) FetchComments(ctx context.Context, jokeID string, limit uint64, offset string, direction repositories.FetchDirection) (data.JokeComments, *string, error) {
+	return data.JokeComments{f.comment}, nil, nil
+}
+
+func (f *fakeJokes) FetchComment(ctx context.Context, jokeID string, id string) (*data.JokeComment, error) {
+	if f.comment == nil || id != f.comment.ID || jokeID != f.comment.JokeID {
+		return nil, repositories.ErrUnknownID
+	}
+
+	comment := *f.comment
+
+	return &comment, nil
+}
+
+func (f *fakeJokes) InsertComment(ctx context.Context, comment *data.JokeComment) (string, error) {
+	f.comment = comment
+
+	return comment.ID, nil
+}
+
+func (f *fakeJokes) UpdateComment(ctx context.Context, comment *data.JokeComment) (string, error) {
+	f.comment = comment
+
+	return comment.ID, nil
+}
+
+func (f *fakeJokes) DeleteComment(ctx context.Context, jokeID string, id string) (string, error) {
+	f.calls = append(f.calls, "DeleteComment")
+
+	return id, nil
+}
+
+func (f *fakeJokes) RemoveComment(ctx context.Context, jokeID string, id string, removedBy string) (string, error) {
+	f.calls = append(f.calls, "RemoveComment")
+
+	return id, nil
+}
+
+func commentRoutes(repo *fakeJokes) *router.Router {
+	l, vm, am := middlewaresForTest()
+
+	api := router.New()
+	handlers.NewJokeComment(l, repo, vm, am).Routes(api)
+
+	return api
+}
+
+// newCommentedJokes returns a joke with a comment by otherID.
+func newCommentedJokes() *fakeJokes {
+	repo := newFakeJokes()
+	repo.comment = &data.JokeComment{ID: commentID, JokeID: jokeID, AuthorID: otherID, Text: "Good one"}
+
+	return repo
+}
+
+func TestJokeCommentsVisibility(t *testing.T) {
+	for _, test := range visibilityTests {
+		auth := ""
+
+		if test.userID != "" {
+			auth = bearer(t, test.userID, test.admin)
+		}
+
+		repo := newCommentedJokes()
+		repo.joke.Status, repo.joke.Hidden = test.status, test.hidden
+
+		w := serve(commentRoutes(repo), http.MethodGet, "/jokes/"+jokeID+"/comments", auth, "")
+
+		if visible := w.Code == http.StatusOK; visible != test.visible || !visible && w.Code != http.StatusNotFound {
+			t.Errorf("%s: listing got %d: %s", test.name, w.Code, w.Body.String())
+		}
+
+		if auth == "" {
+			continue
+		}
+
+		repo = newFakeJokes()
+		repo.joke.Status, repo.joke.Hidden = test.status, test.hidden
+
+		w = serve(commentRoutes(repo), http.MethodPost, "/jokes/"+jokeID+"/comments", auth, `{"text": "Good one"}`)
+
+		if visible := w.Code == http.StatusOK; visible != test.visible || !visible && w.Code != http.StatusNotFound {
+			t.Errorf("%s: commenting got %d: %s", test.name, w.Code, w.Body.String())
+		}
+
+		if commented := repo.comment != nil; commented != test.visible {
+			t.Errorf("%s: commented %v", test.name, commented)
+		}
+	}
+}
+
+func TestJokeCommentInsertIgnoresServerFields(t *testing.T) {
+	repo := newFakeJokes()
+
+	w := serve(commentRoutes(repo), http.MethodPost, "/jokes/"+jokeID+"/comments", bearer(t, otherID, false),
+		`{"text": "Good one", "author_id": "`+authorID+`", "removed_at": "2000-01-01T00:00:00Z"}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body.String())
+	}
+
+	if repo.comment.AuthorID != otherID || repo.comment.RemovedAt != nil || repo.comment.JokeID != jokeID {
+		t.Errorf("inserted %+v", repo.comment)
+	}
+}
+
+func TestJokeCommentOwnership(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		userID string
+		admin  bool
+		want   int
+		calls  []string
+	}{
+		{"edit by the author", http.MethodPut, otherID, false, http.StatusOK, nil},
+		{"edit by another user", http.MethodPut, thirdID, false, http.StatusForbidden, nil},
+		{"edit by an admin", http.MethodPut, authorID, true, http.StatusForbidden, nil},
+		{"deletion by the author", http.MethodDelete, otherID, false, http.StatusOK, []string{"DeleteComment"}},
+		{"deletion by another user", http.MethodDelete, thirdID, false, http.StatusForbidden, nil},
+		{"removal by an admin", http.MethodDelete, authorID, true, http.StatusOK, []string{"RemoveComment"}},
+	}
+
+	for _, test := range tests {
+		repo := newCommentedJokes()
+
+		w := serve(commentRoutes(repo), test.method, "/jokes/"+jokeID+"/comments/"+commentID,
+			bearer(t, test.userID, test.admin), `{"text": "Better one"}`)
+
+		if w.Code != test.want {
+			t.Errorf("%s: got %d, want %d: %s", test.name, w.Code, test.want, w.Body.String())
+		}
+
+		if !reflect.DeepEqual(repo.calls, test.calls) {
+			t.Errorf("%s: called %v, want %v", test.name, repo.calls, test.calls)
+		}
+
+		if edited := repo.comment.Text != "Good one"; edited != (test.method == http.MethodPut && test.want == http.StatusOK) {
+			t.Errorf("%s: got text %q", test.name, repo.comment.Text)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/repositories"
+)
+
+// fakeUsers has a single user and counts the calls to FetchMany.
+type fakeUsers struct {
+	repositories.UserCRUD
+	fetches [][]string
+}
+
+func (f *fakeUsers) FetchMany(ctx context.Context, ids []string) (data.Users, error) {
+	f.fetches = append(f.fetches, ids)
+	users := data.Users{}
+
+	for _, id := range ids {
+		if id == authorID {
+			users = append(users, &data.User{ID: authorID, Email: "author@example.com", Admin: true})
+		}
+	}
+
+	return users, nil
+}
+
+func TestJokeFields(t *testing.T) {
+	tests := []struct {
+		target string
+		status int
+		want   string
+	}{
+		{"/jokes/" + jokeID + "?fields=joke_id,text", http.StatusOK,
+			`{"joke_id": "` + jokeID + `", "text": "A joke"}`},
+		{"/jokes/" + jokeID + "?fields=text,author&expand=author", http.StatusOK,
+			`{"text": "A joke", "author": {"user_id": "` + authorID + `", "admin": true}}`},
+		{"/jokes/" + jokeID + "?fields=ratings&expand=ratings", http.StatusOK,
+			`{"ratings": [{"rating_id": "r1", "user_id": null, "rating": 4, "rated_at": "0001-01-01T00:00:00Z"}]}`},
+		{"/jokes?fields=text", http.StatusOK,
+			`{"result_count": 2, "cursor_next": null, "offset": "", "limit": 0, "results": [{"text": "A joke"}, {"text": "A joke"}]}`},
+		{"/jokes/" + jokeID + "?expand=author,comments", http.StatusBadRequest, ""},
+		{"/jokes?expand=email", http.StatusBadRequest, ""},
+	}
+
+	for _, test := range tests {
+		repo := newFakeJokes()
+		repo.ratings = data.JokeRatings{{ID: "r1", Rating: 4}}
+
+		api, _ := jokeRoutes(t, repo, &fakeUsers{})
+
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.target, nil))
+
+		if w.Code != test.status {
+			t.Errorf("%s: got %d, want %d: %s", test.target, w.Code, test.status, w.Body.String())
+			continue
+		}
+
+		if test.want == "" {
+			continue
+		}
+
+		var got, want interface{}
+
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+
+		json.Unmarshal([]byte(test.want), &want)
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %s, want %s", test.target, w.Body.String(), test.want)
+		}
+	}
+}
+
+func TestJokeExpandBatches(t *testing.T) {
+	repo := newFakeJokes()
+	users := &fakeUsers{}
+
+	api, _ := jokeRoutes(t, repo, users)
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jokes?expand=author,ratings", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Both jokes are by the same author.
+	if !reflect.DeepEqual(users.fetches, [][]string{{authorID}}) {
+		t.Errorf("got author fetches %v, want one of the author", users.fetches)
+	}
+
+	if repo.ratingFetches != 1 {
+		t.Errorf("got %d rating fetches, want 1", repo.ratingFetches)
+	}
+}
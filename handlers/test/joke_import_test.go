@@ -0,0 +1,98 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+// slowJokes takes until the request is canceled to do anything but import.
+type slowJokes struct {
+	repositories.JokeCRUD
+	inserted int64
+}
+
+func (s *slowJokes) FetchOne(ctx context.Context, id string) (*data.Joke, error) {
+	<-ctx.Done()
+
+	return nil, ctx.Err()
+}
+
+func (s *slowJokes) InsertMany(ctx context.Context, jokes data.Jokes) (int64, error) {
+	s.inserted += int64(len(jokes))
+
+	return int64(len(jokes)), nil
+}
+
+// timedRoutes mounts the joke routes with a timeout like main does.
+func timedRoutes(t *testing.T, repo repositories.JokeCRUD, timeout time.Duration) (*router.Router, string) {
+	api, auth := jokeRoutes(t, repo, nil)
+
+	rt := router.New()
+	rt.Mount("/v1", api, func(next http.Handler) http.Handler {
+		return middlewares.Timeout(next, timeout)
+	})
+
+	return rt, auth
+}
+
+func TestImportOutlastsTimeout(t *testing.T) {
+	const jokes = 20000
+	const timeout = 50 * time.Millisecond
+
+	repo := &slowJokes{}
+	rt, auth := timedRoutes(t, repo, timeout)
+
+	body, pw := io.Pipe()
+
+	// Stream the jokes for several times the timeout.
+	go func() {
+		for i := 0; i < jokes; i++ {
+			fmt.Fprintf(pw, `{"type": "single", "text": "Joke number %d, which is long enough to add up", "lang": "en"}`+"\n", i)
+
+			if i%(jokes/10) == 0 {
+				time.Sleep(timeout / 2)
+			}
+		}
+
+		pw.Close()
+	}()
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/jokes/import", body)
+	r.Header.Set("Authorization", auth)
+	r.Header.Set("Content-Type", "application/x-ndjson")
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+
+	report := &data.JokeImportReport{}
+
+	if err := json.Unmarshal(w.Body.Bytes(), report); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body.String())
+	}
+
+	if report.Imported != jokes || repo.inserted != jokes {
+		t.Errorf("imported %d, inserted %d, want %d", report.Imported, repo.inserted, jokes)
+	}
+}
+
+func TestRouteTimeout(t *testing.T) {
+	rt, _ := timedRoutes(t, &slowJokes{}, 50*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/jokes/"+jokeID, nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d, want 503", w.Code)
+	}
+}
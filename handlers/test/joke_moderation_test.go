@@ -0,0 +1,110 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/handlers"
+	"github.com/davq23/jokeapi/router"
+)
+
+func (f *fakeJokes) Moderate(ctx context.Context, id string, moderation *data.JokeModeration) (string, error) {
+	f.joke.Status = moderation.Status
+	f.joke.RejectionReason = moderation.Reason
+
+	return id, nil
+}
+
+func (f *fakeJokes) Restore(ctx context.Context, id string) (string, error) {
+	f.deleted = false
+
+	return id, nil
+}
+
+// moderationRoutes serves the joke routes along with moderation and the trash.
+func moderationRoutes(t *testing.T, repo *fakeJokes) *router.Router {
+	l, vm, am := middlewaresForTest()
+
+	api, _ := jokeRoutes(t, repo, nil)
+	handlers.NewJokeModeration(l, repo, vm, am).Routes(api)
+	handlers.NewTrash(l, repo, nil, vm, am).Routes(api)
+
+	return api
+}
+
+func TestJokeModeration(t *testing.T) {
+	tests := []struct {
+		name   string
+		admin  bool
+		body   string
+		want   int
+		status string
+		reason string
+	}{
+		{"approval by a user", false, `{"status": "approved"}`, http.StatusUnauthorized, data.JokeStatusPending, ""},
+		{"approval", true, `{"status": "approved", "reason": "Funny"}`, http.StatusOK, data.JokeStatusApproved, ""},
+		{"rejection", true, `{"status": "rejected", "reason": "Not a joke"}`, http.StatusOK, data.JokeStatusRejected, "Not a joke"},
+		{"rejection without a reason", true, `{"status": "rejected"}`, http.StatusUnprocessableEntity, data.JokeStatusPending, ""},
+		{"back to pending", true, `{"status": "pending"}`, http.StatusUnprocessableEntity, data.JokeStatusPending, ""},
+	}
+
+	for _, test := range tests {
+		repo := newFakeJokes()
+		repo.joke.Status = data.JokeStatusPending
+		api := moderationRoutes(t, repo)
+
+		w := serve(api, http.MethodPut, "/jokes/moderation/"+jokeID, bearer(t, otherID, test.admin), test.body)
+
+		if w.Code != test.want || repo.joke.Status != test.status || repo.joke.RejectionReason != test.reason {
+			t.Errorf("%s: got %d, status %s and reason %q: %s", test.name, w.Code, repo.joke.Status, repo.joke.RejectionReason, w.Body.String())
+		}
+
+		// Only approved jokes are public.
+		w = serve(api, http.MethodGet, "/jokes/"+jokeID, "", "")
+
+		if public := w.Code == http.StatusOK; public != (test.status == data.JokeStatusApproved) {
+			t.Errorf("%s: fetching the joke got %d", test.name, w.Code)
+		}
+	}
+}
+
+func TestJokeTrash(t *testing.T) {
+	repo := newFakeJokes()
+	api := moderationRoutes(t, repo)
+
+	if w := serve(api, http.MethodGet, "/jokes/"+jokeID, "", ""); w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, step := range []struct {
+		name   string
+		method string
+		target string
+		userID string
+		admin  bool
+		want   int
+	}{
+		{"trashing", http.MethodDelete, "/jokes/" + jokeID, authorID, false, http.StatusOK},
+		{"fetching the trashed joke", http.MethodGet, "/jokes/" + jokeID, authorID, false, http.StatusNotFound},
+		{"restoring by the author", http.MethodPost, "/trash/jokes/" + jokeID + "/restore", authorID, false, http.StatusUnauthorized},
+		{"restoring by an admin", http.MethodPost, "/trash/jokes/" + jokeID + "/restore", otherID, true, http.StatusOK},
+		{"fetching the restored joke", http.MethodGet, "/jokes/" + jokeID, "", false, http.StatusOK},
+	} {
+		auth := ""
+
+		if step.userID != "" {
+			auth = bearer(t, step.userID, step.admin)
+		}
+
+		req := newRequest(step.method, step.target, auth, "")
+		req.Header.Set("If-Match", `"`+jokeID+`-2"`)
+
+		w := record(api, req)
+
+		if w.Code != step.want {
+			t.Fatalf("%s: got %d, want %d: %s", step.name, w.Code, step.want, w.Body.String())
+		}
+	}
+}
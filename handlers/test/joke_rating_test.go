@@ -0,0 +1,170 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/handlers"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+func (f *fakeJokes) FetchRatings(ctx context.Context, jokeID string, limit uint64, offset string, direction repositories.FetchDirection) (data.JokeRatings, *string, error) {
+	return f.ratings, nil, nil
+}
+
+// RateJoke keeps a rating per user, replacing the one they gave before.
+func (f *fakeJokes) RateJoke(ctx context.Context, jokeID string, rating *data.JokeRating) (string, error) {
+	for i, rated := range f.ratings {
+		if *rated.UserID == *rating.UserID {
+			f.ratings[i] = rating
+
+			return rating.ID, nil
+		}
+	}
+
+	f.ratings = append(f.ratings, rating)
+
+	return rating.ID, nil
+}
+
+// DeleteRating deletes a rating only for the user who gave it, unless admin.
+func (f *fakeJokes) DeleteRating(ctx context.Context, jokeID string, ratingID string, authID string, admin bool) (string, error) {
+	for i, rating := range f.ratings {
+		if rating.ID != ratingID {
+			continue
+		}
+
+		if !admin && *rating.UserID != authID {
+			return ratingID, repositories.ErrNotOwner
+		}
+
+		f.ratings = append(f.ratings[:i], f.ratings[i+1:]...)
+
+		return ratingID, nil
+	}
+
+	return "", repositories.ErrUnknownID
+}
+
+func ratingRoutes(repo *fakeJokes) *router.Router {
+	l, vm, am := middlewaresForTest()
+
+	api := router.New()
+	handlers.NewJokeRating(l, repo, vm, am).Routes(api)
+
+	return api
+}
+
+// visibilityTests are the requesters that may or may not see a joke and what
+// hangs off it, by the joke's status.
+var visibilityTests = []struct {
+	name    string
+	status  string
+	hidden  bool
+	userID  string
+	admin   bool
+	visible bool
+}{
+	{"approved joke, anonymously", data.JokeStatusApproved, false, "", false, true},
+	{"approved joke", data.JokeStatusApproved, false, otherID, false, true},
+	{"pending joke, anonymously", data.JokeStatusPending, false, "", false, false},
+	{"pending joke", data.JokeStatusPending, false, otherID, false, false},
+	{"rejected joke", data.JokeStatusRejected, false, otherID, false, false},
+	{"hidden joke", data.JokeStatusApproved, true, otherID, false, false},
+	{"own pending joke", data.JokeStatusPending, false, authorID, false, true},
+	{"pending joke, as an admin", data.JokeStatusPending, false, otherID, true, true},
+}
+
+func TestJokeRatingsVisibility(t *testing.T) {
+	for _, test := range visibilityTests {
+		repo := newFakeJokes()
+		repo.joke.Status, repo.joke.Hidden = test.status, test.hidden
+		auth := ""
+
+		if test.userID != "" {
+			auth = bearer(t, test.userID, test.admin)
+		}
+
+		w := serve(ratingRoutes(repo), http.MethodGet, "/jokes/"+jokeID+"/ratings", auth, "")
+
+		if visible := w.Code == http.StatusOK; visible != test.visible || !visible && w.Code != http.StatusNotFound {
+			t.Errorf("%s: got %d: %s", test.name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRateJokeVisibility(t *testing.T) {
+	for _, test := range visibilityTests {
+		if test.userID == "" {
+			continue
+		}
+
+		repo := newFakeJokes()
+		repo.joke.Status, repo.joke.Hidden = test.status, test.hidden
+
+		w := serve(ratingRoutes(repo), http.MethodPut, "/jokes/"+jokeID+"/ratings", bearer(t, test.userID, test.admin), `{"rating": 4}`)
+
+		if visible := w.Code == http.StatusOK; visible != test.visible || !visible && w.Code != http.StatusNotFound {
+			t.Errorf("%s: got %d: %s", test.name, w.Code, w.Body.String())
+		}
+
+		if rated := len(repo.ratings) == 1; rated != test.visible {
+			t.Errorf("%s: got %d ratings", test.name, len(repo.ratings))
+		}
+	}
+}
+
+func TestRateJokeOncePerUser(t *testing.T) {
+	repo := newFakeJokes()
+	api := ratingRoutes(repo)
+
+	// The rater is the authenticated user, whoever the body names.
+	for _, rating := range []struct {
+		userID string
+		body   string
+	}{
+		{otherID, `{"rating": 2}`},
+		{otherID, `{"rating": 5, "user_id": "` + thirdID + `"}`},
+		{thirdID, `{"rating": 1}`},
+	} {
+		w := serve(api, http.MethodPut, "/jokes/"+jokeID+"/ratings", bearer(t, rating.userID, false), rating.body)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	if len(repo.ratings) != 2 || *repo.ratings[0].UserID != otherID || repo.ratings[0].Rating != 5 || *repo.ratings[1].UserID != thirdID {
+		t.Errorf("got ratings %+v, %+v", repo.ratings[0], repo.ratings[len(repo.ratings)-1])
+	}
+}
+
+func TestDeleteRatingOwnership(t *testing.T) {
+	const ratingID = "6b2c9d4e-1f3a-4b5c-8d7e-9f0a1b2c3d4e"
+
+	tests := []struct {
+		name   string
+		userID string
+		admin  bool
+		want   int
+	}{
+		{"own rating", otherID, false, http.StatusOK},
+		{"rating by another user", thirdID, false, http.StatusForbidden},
+		{"rating by another user, as an admin", thirdID, true, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		rater := otherID
+		repo := newFakeJokes()
+		repo.ratings = data.JokeRatings{{ID: ratingID, UserID: &rater, Rating: 4}}
+
+		w := serve(ratingRoutes(repo), http.MethodDelete, "/jokes/"+jokeID+"/ratings/"+ratingID, bearer(t, test.userID, test.admin), "")
+
+		if w.Code != test.want || len(repo.ratings) == 0 != (test.want == http.StatusOK) {
+			t.Errorf("%s: got %d with %d ratings left: %s", test.name, w.Code, len(repo.ratings), w.Body.String())
+		}
+	}
+}
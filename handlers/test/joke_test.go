@@ -0,0 +1,386 @@
+package test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/handlers"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-playground/validator/v10"
+)
+
+const jokeID = "b8e0c7a4-3f27-4c59-8f38-2f0a7c1e9b6d"
+
+// fakeJokes stores a single joke. Its edits fail with ErrStaleRevision unless
+// they name its revision, or with err if set, standing in for a concurrent
+// edit.
+type fakeJokes struct {
+	repositories.JokeCRUD
+	joke    *data.Joke
+	ratings data.JokeRatings
+	err     error
+	deleted bool
+
+	// favorites has the IDs of the jokes added to the favorites.
+	favorites []string
+
+	comment *data.JokeComment
+	reports []*data.Report
+
+	// calls has the names of the methods called that change the joke or
+	// what hangs off it but leave no other trace.
+	calls []string
+
+	// ratingFetches counts the calls to FetchFirstRatings.
+	ratingFetches int
+}
+
+func (f *fakeJokes) FetchOne(ctx context.Context, id string) (*data.Joke, error) {
+	if id != f.joke.ID || f.deleted {
+		return nil, repositories.ErrUnknownID
+	}
+
+	joke := *f.joke
+
+	return &joke, nil
+}
+
+// FetchAll lists the joke twice, like two jokes by the same author.
+func (f *fakeJokes) FetchAll(ctx context.Context, limit uint64, offset string, direction repositories.FetchDirection, filter *repositories.JokeFilter) (data.Jokes, *string, error) {
+	first, second := *f.joke, *f.joke
+	second.ID = "0c6b5e0e-9d1f-4f55-8d8e-3c2d7c6f1a2b"
+
+	return data.Jokes{&first, &second}, nil, nil
+}
+
+func (f *fakeJokes) FetchFirstRatings(ctx context.Context, jokeIDs []string, limit uint64) (map[string]data.JokeRatings, error) {
+	f.ratingFetches++
+	ratings := make(map[string]data.JokeRatings)
+
+	for _, id := range jokeIDs {
+		if uint64(len(f.ratings)) > limit {
+			ratings[id] = f.ratings[:limit]
+		} else {
+			ratings[id] = f.ratings
+		}
+	}
+
+	return ratings, nil
+}
+
+func (f *fakeJokes) Insert(ctx context.Context, joke *data.Joke) (string, error) {
+	f.joke = joke
+
+	return joke.ID, nil
+}
+
+func (f *fakeJokes) Patch(ctx context.Context, id string, joke *data.Joke, fields []string, editorID string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+
+	if joke.Revision != f.joke.Revision {
+		return "", repositories.ErrStaleRevision
+	}
+
+	joke.Revision++
+	f.joke = joke
+
+	return id, nil
+}
+
+func (f *fakeJokes) Delete(ctx context.Context, id string, revision uint64, deletedBy string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+
+	if revision != f.joke.Revision {
+		return "", repositories.ErrStaleRevision
+	}
+
+	f.deleted = true
+
+	return id, nil
+}
+
+// bearer returns an Authorization header for the user.
+func bearer(t *testing.T, userID string, admin bool) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID, "admin": admin, "authorized": true,
+	}).SignedString([]byte("secret"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "Bearer " + token
+}
+
+func middlewaresForTest() (*log.Logger, *middlewares.Validation, *middlewares.Auth) {
+	l := log.New(ioutil.Discard, "", 0)
+
+	return l, middlewares.NewValidation(l, validator.New()), middlewares.NewAuth(l, []byte("secret"))
+}
+
+func jokeRoutes(t *testing.T, repo repositories.JokeCRUD, users repositories.UserCRUD) (*router.Router, string) {
+	l, vm, am := middlewaresForTest()
+
+	api := router.New()
+	handlers.NewJoke(l, repo, users, vm, am).Routes(api)
+
+	return api, bearer(t, authorID, true)
+}
+
+// newRequest returns a request with the Authorization header auth, if any.
+func newRequest(method string, target string, auth string, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+
+	if auth != "" {
+		r.Header.Set("Authorization", auth)
+	}
+
+	return r
+}
+
+func record(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+// serve sends a request with the Authorization header auth, if any.
+func serve(h http.Handler, method string, target string, auth string, body string) *httptest.ResponseRecorder {
+	return record(h, newRequest(method, target, auth, body))
+}
+
+const authorID = "3c1b0a3e-7e0e-4b3a-9d43-5c4b7f1f0d2e"
+
+// otherID is a user who isn't the author of the joke.
+const otherID = "9a4e2f6c-1d3b-4c8e-b2a7-6f0e5d4c3b21"
+
+// thirdID is another user who isn't the author of the joke.
+const thirdID = "5d7c1e2a-8b4f-4a6e-9c3d-0f1e2d3c4b5a"
+
+func newFakeJokes() *fakeJokes {
+	author := authorID
+
+	return &fakeJokes{joke: &data.Joke{
+		ID:       jokeID,
+		AuthorID: &author,
+		Type:     data.JokeTypeSingle,
+		Text:     "A joke",
+		Language: "en",
+		Status:   data.JokeStatusApproved,
+		Revision: 2,
+	}}
+}
+
+func TestJokeETag(t *testing.T) {
+	api, _ := jokeRoutes(t, newFakeJokes(), nil)
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jokes/"+jokeID, nil))
+
+	tag := w.Header().Get("ETag")
+
+	if w.Code != http.StatusOK || tag != `"`+jokeID+`-2"` {
+		t.Fatalf("got %d with ETag %s", w.Code, tag)
+	}
+
+	// The tag is that of the revision, whatever the representation.
+	for _, target := range []string{"/jokes/" + jokeID, "/jokes/" + jokeID + "?format=yaml&fields=text"} {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("If-None-Match", "W/"+tag)
+
+		w = httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("%s: got %d, want 304", target, w.Code)
+		}
+	}
+}
+
+func TestJokeIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		ifMatch string
+		err     error
+		want    int
+	}{
+		{"patch without If-Match", http.MethodPatch, "", nil, http.StatusPreconditionRequired},
+		{"delete without If-Match", http.MethodDelete, "", nil, http.StatusPreconditionRequired},
+		{"patch of an old revision", http.MethodPatch, `"` + jokeID + `-1"`, nil, http.StatusPreconditionFailed},
+		{"delete of an old revision", http.MethodDelete, `"` + jokeID + `-1"`, nil, http.StatusPreconditionFailed},
+		{"patch with another joke's tag", http.MethodPatch, `"b-2"`, nil, http.StatusPreconditionFailed},
+		{"patch raced by another edit", http.MethodPatch, `"` + jokeID + `-2"`, repositories.ErrStaleRevision, http.StatusPreconditionFailed},
+		{"delete raced by another edit", http.MethodDelete, `"` + jokeID + `-2"`, repositories.ErrStaleRevision, http.StatusPreconditionFailed},
+		{"patch", http.MethodPatch, `"` + jokeID + `-2"`, nil, http.StatusOK},
+		{"patch of any revision", http.MethodPatch, "*", nil, http.StatusOK},
+		{"patch with a list of tags", http.MethodPatch, `"` + jokeID + `-1", "` + jokeID + `-2"`, nil, http.StatusOK},
+		{"delete", http.MethodDelete, `"` + jokeID + `-2"`, nil, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		repo := newFakeJokes()
+		repo.err = test.err
+
+		api, auth := jokeRoutes(t, repo, nil)
+
+		r := httptest.NewRequest(test.method, "/jokes/"+jokeID, strings.NewReader(`{"text": "A better joke"}`))
+		r.Header.Set("Authorization", auth)
+		r.Header.Set("Content-Type", "application/merge-patch+json")
+
+		if test.ifMatch != "" {
+			r.Header.Set("If-Match", test.ifMatch)
+		}
+
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+
+		if w.Code != test.want {
+			t.Errorf("%s: got %d, want %d: %s", test.name, w.Code, test.want, w.Body.String())
+			continue
+		}
+
+		if test.method == http.MethodPatch && test.want == http.StatusOK && w.Header().Get("ETag") != `"`+jokeID+`-3"` {
+			t.Errorf("%s: got ETag %s, want that of the new revision", test.name, w.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestJokeBodyType(t *testing.T) {
+	api, auth := jokeRoutes(t, newFakeJokes(), nil)
+
+	for contentType, want := range map[string]int{
+		"application/x-www-form-urlencoded": http.StatusUnsupportedMediaType,
+		"text/plain":                        http.StatusUnsupportedMediaType,
+		"application/yaml":                  http.StatusUnprocessableEntity,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/jokes", strings.NewReader("type=single"))
+		r.Header.Set("Authorization", auth)
+		r.Header.Set("Content-Type", contentType)
+
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+
+		if w.Code != want {
+			t.Errorf("%s: got %d, want %d", contentType, w.Code, want)
+		}
+	}
+}
+
+func TestJokeTypeDefault(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"joke of no type", `{"text": "A joke", "lang": "en"}`, http.StatusOK},
+		{"two-part joke", `{"type": "twopart", "setup": "A", "delivery": "joke", "lang": "en"}`, http.StatusOK},
+		{"joke of no type with a setup", `{"setup": "A", "delivery": "joke", "lang": "en"}`, http.StatusUnprocessableEntity},
+		{"joke of an unknown type", `{"type": "knock-knock", "text": "A joke", "lang": "en"}`, http.StatusUnprocessableEntity},
+	}
+
+	for _, test := range tests {
+		repo := newFakeJokes()
+		api, auth := jokeRoutes(t, repo, nil)
+
+		r := httptest.NewRequest(http.MethodPost, "/jokes", strings.NewReader(test.body))
+		r.Header.Set("Authorization", auth)
+
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+
+		if w.Code != test.want {
+			t.Errorf("%s: got %d, want %d: %s", test.name, w.Code, test.want, w.Body.String())
+			continue
+		}
+
+		if test.want == http.StatusOK && repo.joke.Type == "" {
+			t.Errorf("%s: inserted a joke of no type", test.name)
+		}
+	}
+
+	repo := newFakeJokes()
+	api, auth := jokeRoutes(t, repo, nil)
+
+	r := httptest.NewRequest(http.MethodPatch, "/jokes/"+jokeID, strings.NewReader(`{"type": null}`))
+	r.Header.Set("Authorization", auth)
+	r.Header.Set("Content-Type", "application/merge-patch+json")
+	r.Header.Set("If-Match", `"`+jokeID+`-2"`)
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || repo.joke.Type != data.JokeTypeSingle {
+		t.Errorf("patch removing the type: got %d with type %q: %s", w.Code, repo.joke.Type, w.Body.String())
+	}
+}
+
+func TestJokeInsertResetsServerFields(t *testing.T) {
+	repo := newFakeJokes()
+	api, _ := jokeRoutes(t, repo, nil)
+
+	w := serve(api, http.MethodPost, "/jokes", bearer(t, otherID, false), `{
+		"text": "A joke", "lang": "en", "status": "approved", "hidden": true,
+		"report_count": 999, "revision": 7, "rating_count": 3, "favorite_count": 5,
+		"deleted_at": "2000-01-01T00:00:00Z", "deleted_by": "`+authorID+`"
+	}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", w.Code, w.Body.String())
+	}
+
+	joke := repo.joke
+
+	if joke.Status != data.JokeStatusPending || joke.Hidden || joke.ReportCount != 0 || joke.Revision != 0 ||
+		joke.RatingCount != 0 || joke.FavoriteCount != 0 || *joke.AuthorID != otherID ||
+		joke.DeletedAt != nil || joke.DeletedBy != nil {
+		t.Errorf("inserted %+v", joke)
+	}
+}
+
+func TestJokeDeleteOwnership(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		userID string
+		admin  bool
+		want   int
+	}{
+		{"own joke", data.JokeStatusApproved, authorID, false, http.StatusOK},
+		{"own pending joke", data.JokeStatusPending, authorID, false, http.StatusOK},
+		{"joke by another user", data.JokeStatusApproved, otherID, false, http.StatusForbidden},
+		{"pending joke by another user", data.JokeStatusPending, otherID, false, http.StatusNotFound},
+		{"joke by another user, as an admin", data.JokeStatusApproved, otherID, true, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		repo := newFakeJokes()
+		repo.joke.Status = test.status
+		api, _ := jokeRoutes(t, repo, nil)
+
+		r := httptest.NewRequest(http.MethodDelete, "/jokes/"+jokeID, nil)
+		r.Header.Set("Authorization", bearer(t, test.userID, test.admin))
+		r.Header.Set("If-Match", `"`+jokeID+`-2"`)
+
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+
+		if w.Code != test.want || repo.deleted != (test.want == http.StatusOK) {
+			t.Errorf("%s: got %d, deleted %v: %s", test.name, w.Code, repo.deleted, w.Body.String())
+		}
+	}
+}
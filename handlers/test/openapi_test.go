@@ -0,0 +1,66 @@
+package test
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/davq23/jokeapi/handlers"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/router"
+	"github.com/go-playground/validator/v10"
+)
+
+// apiRoutes registers the routes of every handler like main does. The
+// backends aren't needed to register routes.
+func apiRoutes() *router.Router {
+	l := log.New(ioutil.Discard, "", 0)
+	vm := middlewares.NewValidation(l, validator.New())
+	am := middlewares.NewAuth(l, nil)
+
+	api := router.New()
+	handlers.RegisterRoutes(api, l, handlers.Backends{ReportHideThreshold: 5}, vm, am)
+
+	return api
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	api := apiRoutes()
+	spec := handlers.NewOpenAPI(log.New(ioutil.Discard, "", 0), api, "/v1").Spec()
+
+	for _, route := range api.Routes() {
+		item, ok := spec.Paths[route.Pattern]
+
+		if !ok {
+			t.Errorf("%s %s is missing from the OpenAPI spec", route.Method, route.Pattern)
+			continue
+		}
+
+		if _, ok := item[strings.ToLower(route.Method)]; !ok {
+			t.Errorf("%s %s is missing from the OpenAPI spec", route.Method, route.Pattern)
+		}
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	spec := handlers.NewOpenAPI(log.New(ioutil.Discard, "", 0), apiRoutes(), "/v1").Spec()
+
+	for _, name := range []string{"Joke", "User", "JokeRating"} {
+		if _, ok := spec.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s is missing from the OpenAPI spec", name)
+		}
+	}
+
+	joke := spec.Components.Schemas["Joke"]
+
+	if joke.Properties["type"] == nil || len(joke.Properties["type"].Enum) != 2 {
+		t.Errorf("Joke type should be an enum of the joke types")
+	}
+
+	rating := spec.Components.Schemas["JokeRating"].Properties["rating"]
+
+	if rating == nil || rating.Minimum == nil || !rating.ExclusiveMinimum || rating.Maximum == nil || *rating.Maximum != 5 {
+		t.Errorf("JokeRating rating should be in (0, 5]")
+	}
+}
@@ -0,0 +1,95 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/handlers"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+// InsertReport hides the joke once hideThreshold users have reported it, if
+// not zero.
+func (f *fakeJokes) InsertReport(ctx context.Context, report *data.Report, hideThreshold uint64) (string, error) {
+	for _, reported := range f.reports {
+		if reported.ReporterID == report.ReporterID {
+			return "", repositories.ErrDuplicateReport
+		}
+	}
+
+	f.reports = append(f.reports, report)
+	f.joke.ReportCount++
+
+	if hideThreshold > 0 && f.joke.ReportCount >= hideThreshold {
+		f.joke.Hidden = true
+	}
+
+	return report.ID, nil
+}
+
+func reportRoutes(t *testing.T, repo *fakeJokes, hideThreshold uint64) *router.Router {
+	l, vm, am := middlewaresForTest()
+
+	api, _ := jokeRoutes(t, repo, nil)
+	handlers.NewReport(l, repo, vm, am, hideThreshold).Routes(api)
+
+	return api
+}
+
+func TestReportVisibility(t *testing.T) {
+	for _, test := range visibilityTests {
+		if test.userID == "" {
+			continue
+		}
+
+		repo := newFakeJokes()
+		repo.joke.Status, repo.joke.Hidden = test.status, test.hidden
+
+		w := serve(reportRoutes(t, repo, 0), http.MethodPost, "/jokes/"+jokeID+"/reports", bearer(t, test.userID, test.admin), `{"reason": "spam"}`)
+
+		if visible := w.Code == http.StatusOK; visible != test.visible || !visible && w.Code != http.StatusNotFound {
+			t.Errorf("%s: got %d: %s", test.name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestReportHidesJoke(t *testing.T) {
+	repo := newFakeJokes()
+	api := reportRoutes(t, repo, 2)
+
+	for _, step := range []struct {
+		name     string
+		reporter string
+		want     int
+		hidden   bool
+	}{
+		{"first report", otherID, http.StatusOK, false},
+		{"same report again", otherID, http.StatusConflict, false},
+		{"report by a second user", thirdID, http.StatusOK, true},
+	} {
+		w := serve(api, http.MethodPost, "/jokes/"+jokeID+"/reports", bearer(t, step.reporter, false), `{"reason": "offensive"}`)
+
+		if w.Code != step.want || repo.joke.Hidden != step.hidden {
+			t.Fatalf("%s: got %d with hidden %v: %s", step.name, w.Code, repo.joke.Hidden, w.Body.String())
+		}
+
+		// Hidden jokes are only shown to their author and admins.
+		for auth, visible := range map[string]bool{
+			"":                         !step.hidden,
+			bearer(t, otherID, false):  !step.hidden,
+			bearer(t, authorID, false): true,
+			bearer(t, thirdID, true):   true,
+		} {
+			if w = serve(api, http.MethodGet, "/jokes/"+jokeID, auth, ""); (w.Code == http.StatusOK) != visible {
+				t.Errorf("%s: fetching the joke got %d", step.name, w.Code)
+			}
+		}
+	}
+
+	if repo.reports[0].ReporterID != otherID || repo.reports[0].Status != data.ReportStatusOpen {
+		t.Errorf("got report %+v", repo.reports[0])
+	}
+}
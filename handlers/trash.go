@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/middlewares"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
+)
+
+type Trash struct {
+	l           *log.Logger
+	jokeRepo    repositories.JokeCRUD
+	userRepo    repositories.UserCRUD
+	vm          *middlewares.Validation
+	am          *middlewares.Auth
+	getJokes    http.HandlerFunc
+	getUsers    http.HandlerFunc
+	restoreJoke http.HandlerFunc
+	restoreUser http.HandlerFunc
+}
+
+func NewTrash(l *log.Logger, jokeRepo repositories.JokeCRUD, userRepo repositories.UserCRUD, vm *middlewares.Validation, am *middlewares.Auth) *Trash {
+	t := &Trash{l: l, jokeRepo: jokeRepo, userRepo: userRepo, vm: vm, am: am}
+
+	t.getJokes = t.am.Auth(middlewares.FetchAllQueryURL(t.fetchJokes), true)
+	t.getUsers = t.am.Auth(middlewares.FetchAllQueryURL(t.fetchUsers), true)
+
+	t.restoreJoke = middlewares.NewParam(
+		t.am.Auth(t.vm.PathIDValidation(t.restore, middlewares.JokeParamKey{}, "id"), true),
+		middlewares.JokeParamKey{}, &data.Joke{})
+
+	t.restoreUser = middlewares.NewParam(
+		t.am.Auth(t.vm.PathIDValidation(t.restore, middlewares.UserParamKey{}, "id"), true),
+		middlewares.UserParamKey{}, &data.User{})
+
+	return t
+}
+
+func (t *Trash) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodGet, "/trash/jokes", t.getJokes)
+	rt.HandleFunc(http.MethodGet, "/trash/users", t.getUsers)
+	rt.HandleFunc(http.MethodPost, "/trash/jokes/{id}/restore", t.restoreJoke)
+	rt.HandleFunc(http.MethodPost, "/trash/users/{id}/restore", t.restoreUser)
+}
+
+func (t *Trash) fetchJokes(w http.ResponseWriter, r *http.Request) {
+	params, ok := r.Context().Value(middlewares.FetchQueryURLParamsKey{}).(*middlewares.FetchQueryURLParams)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	jokes, cursorNext, err := t.jokeRepo.FetchTrash(r.Context(), params.Limit, params.Offset, params.Direction)
+
+	if err != nil {
+		if err == repositories.ErrInvalidOffset {
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		} else {
+			t.l.Println(err.Error())
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+			return
+		}
+	}
+
+	var qar data.QueryAllResponse
+	qar.ResultCount = uint64(len(jokes))
+	qar.CursorNext = cursorNext
+	qar.Offset = params.Offset
+	qar.Limit = params.Limit
+	qar.Results = jokes
+
+	err = writeResponse(w, r, &qar)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (t *Trash) fetchUsers(w http.ResponseWriter, r *http.Request) {
+	params, ok := r.Context().Value(middlewares.FetchQueryURLParamsKey{}).(*middlewares.FetchQueryURLParams)
+
+	if !ok {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	users, cursorNext, err := t.userRepo.FetchTrash(r.Context(), params.Limit, params.Offset, params.Direction)
+
+	if err != nil {
+		if err == repositories.ErrInvalidOffset {
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		} else {
+			t.l.Println(err.Error())
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+			return
+		}
+	}
+
+	for _, user := range users {
+		user.Password = ""
+	}
+
+	var qar data.QueryAllResponse
+	qar.ResultCount = uint64(len(users))
+	qar.CursorNext = cursorNext
+	qar.Offset = params.Offset
+	qar.Limit = params.Limit
+	qar.Results = users
+
+	err = writeResponse(w, r, &qar)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+func (t *Trash) restore(w http.ResponseWriter, r *http.Request) {
+	var objectID string
+	var err error
+
+	if joke, ok := r.Context().Value(middlewares.JokeParamKey{}).(*data.Joke); ok {
+		objectID, err = t.jokeRepo.Restore(r.Context(), joke.ID)
+	} else if user, ok := r.Context().Value(middlewares.UserParamKey{}).(*data.User); ok {
+		objectID, err = t.userRepo.Restore(r.Context(), user.ID)
+	} else {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown ID")
+			return
+		}
+
+		t.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	result := data.RestoredResponse{
+		RestoredID: objectID,
+	}
+
+	err = writeResponse(w, r, result)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/davq23/jokeapi/data"
 	"github.com/davq23/jokeapi/middlewares"
 	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
 )
 
 type User struct {
@@ -19,76 +20,75 @@ type User struct {
 	getUsers   http.HandlerFunc
 	insertUser http.HandlerFunc
 	updateUser http.HandlerFunc
+	patchUser  http.HandlerFunc
 	deleteUser http.HandlerFunc
+	favorites  *Favorite
 }
 
-func NewUser(l *log.Logger, repo repositories.UserCRUD, vm *middlewares.Validation, am *middlewares.Auth) *User {
+func NewUser(l *log.Logger, repo repositories.UserCRUD, jokeRepo repositories.JokeCRUD, vm *middlewares.Validation, am *middlewares.Auth) *User {
 	u := &User{l: l, repo: repo, vm: vm, am: am}
 
-	u.getUser = u.am.Auth(u.vm.IDOrEmailUserValidation(u.fetchOne), false)
+	u.favorites = NewFavorite(l, jokeRepo, vm, am)
+
+	u.getUser = middlewares.NewParam(
+		u.am.Auth(u.vm.IDOrEmailUserValidation(u.fetchOne, "id"), false),
+		middlewares.UserParamKey{}, &data.User{})
 
 	u.getUsers = u.am.Auth(middlewares.FetchAllQueryURL(u.fetchAll), true)
 
-	u.insertUser = u.am.Auth(
+	u.insertUser = middlewares.NewParam(u.am.Auth(
 		u.vm.DataValidation(
 			middlewares.BCryptPassword(u.insert, u.l),
 			middlewares.UserParamKey{}),
-		true)
+		true), middlewares.UserParamKey{}, &data.User{})
 
-	u.updateUser = u.am.Auth(u.vm.OneIDURLValidation(u.vm.DataValidation(
+	u.updateUser = middlewares.NewParam(u.am.Auth(u.vm.PathIDValidation(u.vm.DataValidation(
 		middlewares.BCryptPassword(u.update, u.l),
-		middlewares.UserParamKey{}), middlewares.UserParamKey{}), false)
+		middlewares.UserParamKey{}), middlewares.UserParamKey{}, "id"), false),
+		middlewares.UserParamKey{}, &data.User{})
+
+	u.patchUser = middlewares.NewParam(u.am.Auth(u.vm.PathIDValidation(u.ownerOrAdmin(u.vm.PatchValidation(
+		middlewares.BCryptPassword(u.patch, u.l),
+		middlewares.UserParamKey{}, u.current, "Password")), middlewares.UserParamKey{}, "id"), false),
+		middlewares.UserParamKey{}, &data.User{})
 
-	u.deleteUser = u.am.Auth(u.vm.OneIDURLValidation(u.delete, middlewares.UserParamKey{}), true)
+	u.deleteUser = middlewares.NewParam(
+		u.am.Auth(u.vm.PathIDValidation(u.delete, middlewares.UserParamKey{}, "id"), true),
+		middlewares.UserParamKey{}, &data.User{})
 
 	return u
 }
 
-func (u User) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+func (u *User) Routes(rt *router.Router) {
+	rt.HandleFunc(http.MethodGet, "/users", u.getUsers)
+	rt.HandleFunc(http.MethodPost, "/users", u.insertUser)
+	rt.HandleFunc(http.MethodGet, "/users/{id}", u.getUser)
+	rt.HandleFunc(http.MethodPut, "/users/{id}", u.updateUser)
+	rt.HandleFunc(http.MethodPatch, "/users/{id}", u.patchUser)
+	rt.HandleFunc(http.MethodDelete, "/users/{id}", u.deleteUser)
 
-	switch r.Method {
-	case http.MethodGet:
-		if r.URL.Path == "/users" || r.URL.Path == "/users/" {
-			u.getUsers(w, r)
-		} else {
-			uCtx := context.WithValue(r.Context(), middlewares.UserParamKey{}, &data.User{})
-			u.getUser(w, r.WithContext(uCtx))
-		}
-	case http.MethodPost:
-		uCtx := context.WithValue(r.Context(), middlewares.UserParamKey{}, &data.User{})
-		u.insertUser(w, r.WithContext(uCtx))
-
-	case http.MethodPut:
-		uCtx := context.WithValue(r.Context(), middlewares.UserParamKey{}, &data.User{})
-		u.updateUser(w, r.WithContext(uCtx))
-
-	case http.MethodDelete:
-		uCtx := context.WithValue(r.Context(), middlewares.UserParamKey{}, &data.User{})
-		u.deleteUser(w, r.WithContext(uCtx))
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-	}
+	u.favorites.Routes(rt)
 }
 
 func (u *User) delete(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middlewares.UserParamKey{}).(*data.User)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
 
-	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
 		return
 	}
 
-	objectID, err := u.repo.Delete(r.Context(), user.ID)
+	objectID, err := u.repo.Delete(r.Context(), user.ID, auth.ID)
 
 	if err != nil {
 		if err == repositories.ErrUnknownID {
-			http.Error(w, "Unknown User ID", http.StatusNotFound)
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown User ID")
 			return
 		}
 
 		u.l.Println(err.Error())
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 		return
 	}
 
@@ -96,10 +96,10 @@ func (u *User) delete(w http.ResponseWriter, r *http.Request) {
 		DeletedID: objectID,
 	}
 
-	err = result.ToJSON(w)
+	err = writeResponse(w, r, result)
 
 	if err != nil {
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 	}
 }
 
@@ -107,7 +107,7 @@ func (u *User) fetchAll(w http.ResponseWriter, r *http.Request) {
 	params, ok := r.Context().Value(middlewares.FetchQueryURLParamsKey{}).(*middlewares.FetchQueryURLParams)
 
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
 		return
 	}
 
@@ -115,11 +115,11 @@ func (u *User) fetchAll(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		if err == repositories.ErrInvalidOffset {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, err.Error())
 			return
 		} else {
 			u.l.Println(err.Error())
-			http.Error(w, "Unexpected error", http.StatusInternalServerError)
+			middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 			return
 		}
 	}
@@ -131,10 +131,10 @@ func (u *User) fetchAll(w http.ResponseWriter, r *http.Request) {
 	qar.Limit = params.Limit
 	qar.Results = users
 
-	err = qar.ToJSON(w)
+	err = writeResponse(w, r, &qar)
 
 	if err != nil {
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 	}
 }
 
@@ -143,7 +143,7 @@ func (u *User) fetchOne(w http.ResponseWriter, r *http.Request) {
 	auth, ok2 := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
 
 	if !ok || !ok2 {
-		w.WriteHeader(http.StatusBadRequest)
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
 		return
 	}
 
@@ -157,24 +157,24 @@ func (u *User) fetchOne(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		if err == repositories.ErrUnknownID {
-			http.Error(w, "Unknown User ID", http.StatusNotFound)
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown User ID")
 			return
 		}
 
 		u.l.Println(err.Error())
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 		return
 	}
 
 	if !auth.Admin && user.ID != auth.ID {
-		http.Error(w, "Unknown User ID", http.StatusNotFound)
+		middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown User ID")
 		return
 	}
 
-	err = user.ToJSON(w)
+	err = writeResponse(w, r, user)
 
 	if err != nil {
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 	}
 }
 
@@ -182,7 +182,7 @@ func (u *User) insert(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middlewares.UserParamKey{}).(*data.User)
 
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
 		return
 	}
 
@@ -190,7 +190,7 @@ func (u *User) insert(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		u.l.Println(err.Error())
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 		return
 	}
 
@@ -198,14 +198,14 @@ func (u *User) insert(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		u.l.Println(err.Error())
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 		return
 	}
 
-	err = user.ToJSON(w)
+	err = writeResponse(w, r, user)
 
 	if err != nil {
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 	}
 }
 
@@ -213,7 +213,7 @@ func (u *User) update(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middlewares.UserParamKey{}).(*data.User)
 
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
 		return
 	}
 
@@ -221,18 +221,96 @@ func (u *User) update(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		if err == repositories.ErrUnknownID {
-			http.Error(w, "Unknown User ID", http.StatusNotFound)
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown User ID")
 			return
 		}
 
 		u.l.Println(err.Error())
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 		return
 	}
 
-	err = user.ToJSON(w)
+	err = writeResponse(w, r, user)
+
+	if err != nil {
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+	}
+}
+
+// ownerOrAdmin lets only the user in the path or an admin through.
+func (u *User) ownerOrAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(middlewares.UserParamKey{}).(*data.User)
+		auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+		if !ok || !okAuth {
+			middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+			return
+		}
+
+		if !auth.Admin && auth.ID != user.ID {
+			middlewares.WriteProblem(w, r, http.StatusForbidden, "")
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// current returns the stored user patches apply to, without the password so
+// that patches only set it to replace it.
+func (u *User) current(ctx context.Context, id string) (data.Data, error) {
+	user, err := u.repo.FetchOne(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user.Password = ""
+
+	return user, nil
+}
+
+func (u *User) patch(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.UserParamKey{}).(*data.User)
+	auth, okAuth := r.Context().Value(middlewares.AuthParamsKey{}).(middlewares.AuthParams)
+
+	if !ok || !okAuth {
+		middlewares.WriteProblem(w, r, http.StatusBadRequest, "")
+		return
+	}
+
+	writable := repositories.UserPatchFields
+
+	// Only admins may grant or revoke admin rights.
+	if !auth.Admin {
+		writable = []string{"email", "password"}
+	}
+
+	fields, ok := patchedFields(w, r, writable)
+
+	if !ok {
+		return
+	}
+
+	_, err := u.repo.Patch(r.Context(), user.ID, user, fields)
+
+	if err != nil {
+		if err == repositories.ErrUnknownID {
+			middlewares.WriteProblem(w, r, http.StatusNotFound, "Unknown User ID")
+			return
+		}
+
+		u.l.Println(err.Error())
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+		return
+	}
+
+	user.Password = ""
+
+	err = writeResponse(w, r, user)
 
 	if err != nil {
-		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		middlewares.WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
 	}
 }
@@ -0,0 +1,249 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/repositories"
+)
+
+const leaderboardSize = 50
+
+const trendingWindow = 7 * 24 * time.Hour
+const trendingHalfLife = 24 * time.Hour
+
+var leaderboardWindows = map[string]time.Duration{
+	data.LeaderboardDay:   24 * time.Hour,
+	data.LeaderboardWeek:  7 * 24 * time.Hour,
+	data.LeaderboardMonth: 30 * 24 * time.Hour,
+}
+
+// Leaderboards keeps the top-rated and trending joke lists, rebuilt from the
+// repository every refresh so requests never rank jokes themselves.
+type Leaderboards struct {
+	l      *log.Logger
+	repo   repositories.JokeCRUD
+	mu     sync.RWMutex
+	boards map[string]*data.Leaderboard
+}
+
+func NewLeaderboards(l *log.Logger, repo repositories.JokeCRUD) *Leaderboards {
+	return &Leaderboards{l: l, repo: repo}
+}
+
+func leaderboardKey(period string, language string) string {
+	return period + "/" + language
+}
+
+// Get returns the cached leaderboard for period and language, and false if
+// no leaderboard has been computed yet.
+func (lb *Leaderboards) Get(period string, language string) (*data.Leaderboard, bool) {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+
+	if lb.boards == nil {
+		return nil, false
+	}
+
+	if board, ok := lb.boards[leaderboardKey(period, language)]; ok {
+		return board, true
+	}
+
+	return &data.Leaderboard{
+		Period:    period,
+		Language:  language,
+		UpdatedAt: lb.boards[leaderboardKey(period, "")].UpdatedAt,
+		Results:   data.Jokes{},
+	}, true
+}
+
+func (lb *Leaderboards) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	defer ticker.Stop()
+
+	for {
+		if err := lb.Refresh(ctx); err != nil {
+			lb.l.Println(err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (lb *Leaderboards) Refresh(ctx context.Context) error {
+	now := time.Now().UTC()
+	boards := make(map[string]*data.Leaderboard)
+
+	languages, err := lb.repo.FetchLanguages(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	for _, language := range append([]string{""}, languages...) {
+		jokes, _, err := lb.repo.FetchAll(ctx, leaderboardSize, "", repositories.FetchNext, &repositories.JokeFilter{
+			Status:   data.JokeStatusApproved,
+			Sort:     repositories.SortScore,
+			Language: language,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		boards[leaderboardKey(data.LeaderboardAll, language)] = &data.Leaderboard{
+			Period:    data.LeaderboardAll,
+			Language:  language,
+			UpdatedAt: now,
+			Results:   jokes,
+		}
+	}
+
+	events, err := lb.repo.FetchRatingEvents(ctx, now.Add(-leaderboardWindows[data.LeaderboardMonth]))
+
+	if err != nil {
+		return err
+	}
+
+	rankings := make(map[string][]string)
+
+	for period, window := range leaderboardWindows {
+		tallies := make(map[string]map[string]*data.Joke)
+
+		for _, event := range events {
+			if now.Sub(event.RatedAt) > window {
+				continue
+			}
+
+			for _, language := range []string{"", event.Language} {
+				if tallies[language] == nil {
+					tallies[language] = make(map[string]*data.Joke)
+				}
+
+				tally, ok := tallies[language][event.JokeID]
+
+				if !ok {
+					tally = &data.Joke{ID: event.JokeID}
+					tallies[language][event.JokeID] = tally
+				}
+
+				tally.RatingCount++
+				tally.RatingSum += event.Rating
+			}
+		}
+
+		for language, jokes := range tallies {
+			scores := make(map[string]float64, len(jokes))
+
+			for id, tally := range jokes {
+				tally.UpdateScore()
+				scores[id] = tally.Score
+			}
+
+			rankings[leaderboardKey(period, language)] = rank(scores)
+		}
+	}
+
+	trending := make(map[string]map[string]float64)
+
+	for _, event := range events {
+		age := now.Sub(event.RatedAt)
+
+		if age > trendingWindow {
+			continue
+		}
+
+		// Each rating counts for less the older it gets, halving every
+		// trendingHalfLife, so recent bursts of good ratings rise fastest.
+		weight := event.Rating / 5 * math.Pow(0.5, float64(age)/float64(trendingHalfLife))
+
+		for _, language := range []string{"", event.Language} {
+			if trending[language] == nil {
+				trending[language] = make(map[string]float64)
+			}
+
+			trending[language][event.JokeID] += weight
+		}
+	}
+
+	for language, scores := range trending {
+		rankings[leaderboardKey(data.LeaderboardTrending, language)] = rank(scores)
+	}
+
+	var ids []string
+
+	for _, ranked := range rankings {
+		ids = append(ids, ranked...)
+	}
+
+	jokes, err := lb.repo.FetchMany(ctx, ids)
+
+	if err != nil {
+		return err
+	}
+
+	for key, ranked := range rankings {
+		board := &data.Leaderboard{
+			UpdatedAt: now,
+			Results:   jokes.InOrder(ranked),
+		}
+
+		board.Period, board.Language = splitLeaderboardKey(key)
+		boards[key] = board
+	}
+
+	for _, period := range []string{data.LeaderboardDay, data.LeaderboardWeek, data.LeaderboardMonth, data.LeaderboardTrending} {
+		if _, ok := boards[leaderboardKey(period, "")]; !ok {
+			boards[leaderboardKey(period, "")] = &data.Leaderboard{Period: period, UpdatedAt: now, Results: data.Jokes{}}
+		}
+	}
+
+	lb.mu.Lock()
+	lb.boards = boards
+	lb.mu.Unlock()
+
+	return nil
+}
+
+func splitLeaderboardKey(key string) (string, string) {
+	for i := 0; i < len(key); i++ {
+		if key[i] == '/' {
+			return key[:i], key[i+1:]
+		}
+	}
+
+	return key, ""
+}
+
+// rank returns the IDs of the leaderboardSize highest scores, ties broken by ID.
+func rank(scores map[string]float64) []string {
+	ids := make([]string, 0, len(scores))
+
+	for id := range scores {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+
+		return ids[i] < ids[j]
+	})
+
+	if len(ids) > leaderboardSize {
+		ids = ids[:leaderboardSize]
+	}
+
+	return ids
+}
@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type Purger interface {
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// PurgeTrash permanently removes records soft-deleted more than retention ago,
+// checking every interval until ctx is cancelled.
+func PurgeTrash(ctx context.Context, l *log.Logger, interval time.Duration, retention time.Duration, purgers ...Purger) {
+	ticker := time.NewTicker(interval)
+
+	defer ticker.Stop()
+
+	for {
+		deletedBefore := time.Now().UTC().Add(-retention)
+
+		for _, purger := range purgers {
+			purged, err := purger.Purge(ctx, deletedBefore)
+
+			if err != nil {
+				l.Println(err.Error())
+				continue
+			}
+
+			if purged > 0 {
+				l.Printf("Purged %d records deleted before %s\n", purged, deletedBefore.Format(time.RFC3339))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -7,13 +7,17 @@ import (
 	"os"
 	"os/signal"
 	"regexp"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/davq23/jokeapi/config"
+	"github.com/davq23/jokeapi/data"
 	"github.com/davq23/jokeapi/handlers"
+	"github.com/davq23/jokeapi/jobs"
 	"github.com/davq23/jokeapi/middlewares"
 	"github.com/davq23/jokeapi/repositories/mongodb"
+	"github.com/davq23/jokeapi/router"
 	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -27,7 +31,43 @@ func main() {
 
 	l := log.New(os.Stdout, "joke api - ", log.LstdFlags)
 	cfg := config.Config{
-		DBConnectionURI: os.Getenv("MONGODB_URI"),
+		DBConnectionURI:     os.Getenv("MONGODB_URI"),
+		TrashRetention:      30 * 24 * time.Hour,
+		PurgeInterval:       time.Hour,
+		LeaderboardInterval: 10 * time.Minute,
+		ReportHideThreshold: 5,
+		RequestTimeout:      5 * time.Second,
+		StreamTimeout:       30 * time.Minute,
+	}
+
+	if retention := os.Getenv("TRASH_RETENTION"); retention != "" {
+		d, err := time.ParseDuration(retention)
+
+		if err != nil {
+			l.Fatal(err.Error())
+		}
+
+		cfg.TrashRetention = d
+	}
+
+	if threshold := os.Getenv("REPORT_HIDE_THRESHOLD"); threshold != "" {
+		n, err := strconv.ParseUint(threshold, 10, 64)
+
+		if err != nil {
+			l.Fatal(err.Error())
+		}
+
+		cfg.ReportHideThreshold = n
+	}
+
+	if sunset := os.Getenv("LEGACY_API_SUNSET"); sunset != "" {
+		t, err := time.Parse("2006-01-02", sunset)
+
+		if err != nil {
+			l.Fatal(err.Error())
+		}
+
+		cfg.LegacySunset = t
 	}
 
 	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(cfg.DBConnectionURI))
@@ -42,10 +82,11 @@ func main() {
 
 	db := client.Database("jokeapi")
 
-	jc, uc := config.MongoDBMigration(context.Background(), db)
+	jc, uc, cc := config.MongoDBMigration(context.Background(), db)
 
 	jr := mongodb.NewJoke(jc)
 	ur := mongodb.NewUser(uc)
+	cr := mongodb.NewCollection(cc)
 
 	if err != nil {
 		l.Fatal(err.Error())
@@ -66,29 +107,58 @@ func main() {
 
 	am := middlewares.NewAuth(l, []byte(os.Getenv("API_KEY")))
 
-	jh := handlers.NewJoke(l, jr, vm, am)
-	jrh := handlers.NewJokeRating(l, jr, vm, am)
-	uh := handlers.NewUser(l, ur, vm, am)
-	ah := handlers.NewAuth(am, l, ur, vm)
+	boards := jobs.NewLeaderboards(l, jr)
+
+	api := router.New()
+	api.NotFound = middlewares.NotFound
+	api.MethodNotAllowed = middlewares.MethodNotAllowed
+
+	handlers.RegisterRoutes(api, l, handlers.Backends{
+		Jokes:               jr,
+		Users:               ur,
+		Collections:         cr,
+		Transactor:          mongodb.NewTransactor(client),
+		Leaderboards:        boards,
+		ReportHideThreshold: cfg.ReportHideThreshold,
+	}, vm, am)
+
+	oh := handlers.NewOpenAPI(l, api, "/v1")
+
+	rt := router.New()
+	rt.NotFound = middlewares.NotFound
+	rt.MethodNotAllowed = middlewares.MethodNotAllowed
 
-	serveMux := http.NewServeMux()
+	oh.Routes(rt)
 
-	serveMux.Handle("/jokes/ratings", jrh)
-	serveMux.Handle("/jokes/ratings/", jrh)
-	serveMux.Handle("/jokes", jh)
-	serveMux.Handle("/jokes/", jh)
-	serveMux.Handle("/users", uh)
-	serveMux.Handle("/users/", uh)
-	serveMux.Handle("/login", ah)
+	for version := data.APIVersion1; version <= data.APIVersion2; version++ {
+		version := version
 
+		rt.Mount("/v"+strconv.Itoa(version), api, func(next http.Handler) http.Handler {
+			return middlewares.APIVersion(middlewares.Timeout(next, cfg.RequestTimeout), version)
+		})
+	}
+
+	// Unversioned paths are v1 for the clients from before versioning.
+	rt.Mount("/", api, func(next http.Handler) http.Handler {
+		return middlewares.Deprecated(middlewares.APIVersion(middlewares.Timeout(next, cfg.RequestTimeout), data.APIVersion1), cfg.LegacySunset, "/v1")
+	})
+
+	// Routes time out after cfg.RequestTimeout, except for the bulk imports
+	// and exports, which the server lets stream for up to cfg.StreamTimeout.
 	server := &http.Server{
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 5 * time.Second,
-		IdleTimeout:  time.Second,
-		Handler:      serveMux,
-		Addr:         ":8080",
+		ReadHeaderTimeout: cfg.RequestTimeout,
+		ReadTimeout:       cfg.StreamTimeout,
+		WriteTimeout:      cfg.StreamTimeout,
+		IdleTimeout:       time.Second,
+		Handler:           middlewares.TagRequestID(middlewares.JSONContent(middlewares.Negotiate(rt))),
+		Addr:              ":8080",
 	}
 
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+
+	go jobs.PurgeTrash(jobsCtx, l, cfg.PurgeInterval, cfg.TrashRetention, jr, ur)
+	go boards.Run(jobsCtx, cfg.LeaderboardInterval)
+
 	go func() {
 		err := server.ListenAndServe()
 
@@ -107,6 +177,8 @@ func main() {
 
 	l.Println("Received terminate, graceful shutdown", sig)
 
+	cancelJobs()
+
 	tc, cancelFunc := context.WithTimeout(context.Background(), 30*time.Second)
 
 	defer cancelFunc()
@@ -36,7 +36,7 @@ func (au *Auth) Auth(next http.HandlerFunc, admin bool) http.HandlerFunc {
 		authParts := strings.Split(authorization, " ")
 
 		if len(authParts) != 2 || authParts[0] != "Bearer" {
-			http.Error(w, "Invalid authorization", http.StatusUnauthorized)
+			WriteProblem(w, r, http.StatusUnauthorized, "Invalid authorization")
 			return
 		}
 
@@ -50,7 +50,7 @@ func (au *Auth) Auth(next http.HandlerFunc, admin bool) http.HandlerFunc {
 
 		if err != nil {
 			au.l.Println(err.Error())
-			http.Error(w, "Unauthorized access", http.StatusUnauthorized)
+			WriteProblem(w, r, http.StatusUnauthorized, "Unauthorized access")
 			return
 		}
 
@@ -58,7 +58,7 @@ func (au *Auth) Auth(next http.HandlerFunc, admin bool) http.HandlerFunc {
 
 		if err = claims.Valid(); err != nil {
 			au.l.Println(err.Error())
-			http.Error(w, "Invalid claims", http.StatusUnauthorized)
+			WriteProblem(w, r, http.StatusUnauthorized, "Invalid claims")
 			return
 		}
 
@@ -66,12 +66,12 @@ func (au *Auth) Auth(next http.HandlerFunc, admin bool) http.HandlerFunc {
 		uid, okUid := claims["user_id"].(string)
 
 		if !okUid || !okAdmin {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			WriteProblem(w, r, http.StatusUnauthorized, "Invalid token")
 			return
 		}
 
 		if admin && !adminClaims {
-			http.Error(w, "Unauthorized access", http.StatusUnauthorized)
+			WriteProblem(w, r, http.StatusUnauthorized, "Unauthorized access")
 			return
 		}
 
@@ -83,3 +83,16 @@ func (au *Auth) Auth(next http.HandlerFunc, admin bool) http.HandlerFunc {
 		next(w, r.WithContext(ctx))
 	})
 }
+
+func (au *Auth) OptionalAuth(next http.HandlerFunc) http.HandlerFunc {
+	authNext := au.Auth(next, false)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next(w, r)
+			return
+		}
+
+		authNext(w, r)
+	})
+}
@@ -27,7 +27,7 @@ func FetchAllQueryURL(next http.HandlerFunc) http.HandlerFunc {
 		limit, err := strconv.ParseUint(limitString, 10, 64)
 
 		if err != nil && offset != "" {
-			http.Error(w, "Invalid limit type", http.StatusBadRequest)
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid limit type")
 			return
 		}
 
@@ -0,0 +1,76 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type FieldsQueryURLParamsKey struct{}
+
+// FieldsQueryURLParams are the fields a response is trimmed to, all if none,
+// and the related resources to embed in it.
+type FieldsQueryURLParams struct {
+	Fields []string
+	Expand []string
+}
+
+// Expands reports whether the related resource was asked to be embedded.
+func (p *FieldsQueryURLParams) Expands(name string) bool {
+	for _, expand := range p.Expand {
+		if expand == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FieldsQueryURL reads the comma separated fields and expand query params,
+// replying 400 if asked to expand anything but expandable.
+func FieldsQueryURL(next http.HandlerFunc, expandable ...string) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := &FieldsQueryURLParams{
+			Fields: splitList(r.URL.Query().Get("fields")),
+			Expand: splitList(r.URL.Query().Get("expand")),
+		}
+
+	expands:
+		for _, expand := range params.Expand {
+			for _, name := range expandable {
+				if expand == name {
+					continue expands
+				}
+			}
+
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid expand "+expand)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), FieldsQueryURLParamsKey{}, params)
+
+		next(w, r.WithContext(ctx))
+	})
+}
+
+// RequestFields returns the fields the response to the request is trimmed
+// to, none if it isn't.
+func RequestFields(r *http.Request) []string {
+	if params, ok := r.Context().Value(FieldsQueryURLParamsKey{}).(*FieldsQueryURLParams); ok {
+		return params.Fields
+	}
+
+	return nil
+}
+
+func splitList(list string) []string {
+	var items []string
+
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
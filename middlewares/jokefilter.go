@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/repositories"
+)
+
+type JokeFilterParamsKey struct{}
+
+func JokeFilterQueryURL(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jokeType := r.URL.Query().Get("type")
+		safe := r.URL.Query().Get("safe")
+		blacklistString := r.URL.Query().Get("blacklistFlags")
+		sort := r.URL.Query().Get("sort")
+
+		switch jokeType {
+		case "", data.JokeTypeSingle, data.JokeTypeTwoPart:
+		default:
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid joke type")
+			return
+		}
+
+		if _, ok := repositories.SortReactionKind(sort); !ok && sort != "" && sort != repositories.SortScore {
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid sort")
+			return
+		}
+
+		var blacklist []string
+
+		if safe == "true" {
+			blacklist = data.JokeFlagNames
+		} else if blacklistString != "" {
+			for _, flag := range strings.Split(blacklistString, ",") {
+				if !isJokeFlag(flag) {
+					WriteProblem(w, r, http.StatusBadRequest, "Invalid flag "+flag)
+					return
+				}
+
+				blacklist = append(blacklist, flag)
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), JokeFilterParamsKey{}, &repositories.JokeFilter{
+			Type:           jokeType,
+			BlacklistFlags: blacklist,
+			Sort:           sort,
+		})
+
+		next(w, r.WithContext(ctx))
+	})
+}
+
+func isJokeFlag(flag string) bool {
+	for _, name := range data.JokeFlagNames {
+		if name == flag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -13,7 +13,13 @@ func BCryptPassword(next http.HandlerFunc, l *log.Logger) http.HandlerFunc {
 		user, ok := r.Context().Value(UserParamKey{}).(*data.User)
 
 		if !ok {
-			http.Error(w, "Invalid payload", http.StatusUnprocessableEntity)
+			WriteProblem(w, r, http.StatusUnprocessableEntity, "Invalid payload")
+			return
+		}
+
+		// Patches leave the password empty unless they change it.
+		if user.Password == "" {
+			next(w, r)
 			return
 		}
 
@@ -21,7 +27,7 @@ func BCryptPassword(next http.HandlerFunc, l *log.Logger) http.HandlerFunc {
 
 		if err != nil {
 			l.Println(err.Error())
-			http.Error(w, "Unknown error", http.StatusInternalServerError)
+			WriteProblem(w, r, http.StatusInternalServerError, "Unknown error")
 			return
 		}
 
@@ -30,3 +36,12 @@ func BCryptPassword(next http.HandlerFunc, l *log.Logger) http.HandlerFunc {
 		next(w, r)
 	})
 }
+
+// JSONContent marks every response as JSON unless a handler says otherwise.
+func JSONContent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/davq23/jokeapi/codec"
+)
+
+// Negotiate replies 406 to requests that accept none of the formats of the
+// API, JSON, XML, YAML and plain text, unless they pick one with ?format=.
+func Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		if _, ok := codec.ByFormat(r.URL.Query().Get("format")); !ok {
+			if _, ok := codec.Negotiate(r.Header.Get("Accept")); !ok {
+				WriteProblem(w, r, http.StatusNotAcceptable, "Supported formats are JSON, XML, YAML and plain text")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ResponseCodec returns the codec to write the response to the request in,
+// the one of its ?format= if any, or else the one its Accept header prefers.
+// Formats that aren't codecs, e.g. the csv of /jokes/export, are left to the
+// handlers.
+func ResponseCodec(r *http.Request) codec.Codec {
+	if c, ok := codec.ByFormat(r.URL.Query().Get("format")); ok {
+		return c
+	}
+
+	if c, ok := codec.Negotiate(r.Header.Get("Accept")); ok {
+		return c
+	}
+
+	return codec.JSON
+}
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/davq23/jokeapi/data"
+)
+
+// WriteProblem replies with an application/problem+json error of the given
+// status. detail explains this occurrence of the problem, if not empty.
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	writeProblem(w, r, &data.Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	})
+}
+
+// WriteValidationProblem replies 422 listing the fields of the payload that
+// failed validation.
+func WriteValidationProblem(w http.ResponseWriter, r *http.Request, errs ...*data.ProblemFieldError) {
+	writeProblem(w, r, &data.Problem{
+		Type:   data.ProblemTypeValidation,
+		Title:  "Invalid payload",
+		Status: http.StatusUnprocessableEntity,
+		Detail: "Some fields failed validation",
+		Errors: errs,
+	})
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, problem *data.Problem) {
+	problem.RequestID = RequestID(r)
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+
+	problem.ToJSON(w)
+}
+
+// NotFound replies with a 404 problem.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, http.StatusNotFound, "")
+}
+
+// MethodNotAllowed replies with a 405 problem.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, http.StatusMethodNotAllowed, "")
+}
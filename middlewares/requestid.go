@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+type RequestIDKey struct{}
+
+// RequestIDHeader carries the ID of a request, echoed back in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// TagRequestID puts the ID of the request in its context and in the
+// response headers, taking the one sent by the client if any.
+func TagRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+
+		if id == "" || len(id) > 128 {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), RequestIDKey{}, id)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestID returns the ID of the request, "" if it wasn't tagged.
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(RequestIDKey{}).(string)
+
+	return id
+}
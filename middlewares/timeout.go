@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+)
+
+// Streaming marks the handlers of routes that stream their request or
+// response bodies, e.g. bulk imports and exports, which Timeout leaves to the
+// longer limits of the server.
+type Streaming http.HandlerFunc
+
+func (s Streaming) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s(w, r)
+}
+
+const timeoutProblem = `{"type":"about:blank","title":"Service Unavailable","status":503,"detail":"The request took too long"}`
+
+// Timeout replies 503 to requests next takes longer than d to handle, and
+// cancels their context, unless next is Streaming. It's meant to wrap the
+// handlers of single routes, e.g. when mounting them.
+func Timeout(next http.Handler, d time.Duration) http.Handler {
+	if _, ok := next.(Streaming); ok {
+		return next
+	}
+
+	return http.TimeoutHandler(next, d, timeoutProblem)
+}
@@ -2,11 +2,21 @@ package middlewares
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"log"
+	"mime"
 	"net/http"
+	"reflect"
+	"strconv"
 	"strings"
 
+	"github.com/davq23/jokeapi/codec"
 	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/patch"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/davq23/jokeapi/router"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -19,106 +29,251 @@ type IDParamKey struct{}
 type MultipleIDParamKey struct{}
 type JokeParamKey struct{}
 type JokeRatingParamKey struct{}
+type JokeModerationParamKey struct{}
+type JokeRevisionParamKey struct{}
+type JokeCommentParamKey struct{}
+type JokeReactionParamKey struct{}
+type ReportParamKey struct{}
+type ReportResolutionParamKey struct{}
 type UserParamKey struct{}
+type CollectionParamKey struct{}
+type CollectionOrderParamKey struct{}
+type BatchParamKey struct{}
+
+// MeAlias can be used in place of a user ID in paths to refer to the
+// authenticated user.
+const MeAlias = "me"
+
+func resolveMeAlias(r *http.Request, d data.Data, id string) string {
+	if _, ok := d.(*data.User); !ok || id != MeAlias {
+		return id
+	}
+
+	if auth, ok := r.Context().Value(AuthParamsKey{}).(AuthParams); ok {
+		return auth.ID
+	}
+
+	return id
+}
+
+// NewParam puts a new zero value of param's type in the request context
+// under ctxKey, for the middlewares down the chain to fill in. param must be
+// a pointer, e.g. &data.Joke{}.
+func NewParam(next http.HandlerFunc, ctxKey interface{}, param interface{}) http.HandlerFunc {
+	t := reflect.TypeOf(param).Elem()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), ctxKey, reflect.New(t).Interface())
+
+		next(w, r.WithContext(ctx))
+	})
+}
 
 func NewValidation(l *log.Logger, v *validator.Validate) *Validation {
+	// Report the fields that fail validation by their JSON names.
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
+
 	return &Validation{l, v}
 }
 
-func (vln *Validation) MultipleIDURLValidation(next http.HandlerFunc, ctxKey []interface{}, ds []data.Data) http.HandlerFunc {
-	if len(ctxKey) != len(ds) {
-		panic("Context Type must agree with context type")
-	}
+func (vln *Validation) IDOrEmailUserValidation(next http.HandlerFunc, name string) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, ok := r.Context().Value(UserParamKey{}).(*data.User)
+
+		if !ok {
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid ID or email")
+			return
+		}
+
+		id := resolveMeAlias(r, u, router.Param(r, name))
+
+		if err := u.CheckValidID(id); err != nil {
+			if err = vln.v.Var(id, "email"); err != nil {
+				WriteProblem(w, r, http.StatusBadRequest, "Invalid ID or email")
+				return
+			}
+			u.Email = id
+		} else {
+			u.ID = id
+		}
+
+		next(w, r)
+	})
+}
+
+// PathIDValidation checks the named path param is a valid ID for the data
+// under ctxKey, and sets it as its ID.
+func (vln *Validation) PathIDValidation(next http.HandlerFunc, ctxKey interface{}, name string) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, ok := r.Context().Value(ctxKey).(data.Data)
+
+		if !ok {
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+
+		id := resolveMeAlias(r, d, router.Param(r, name))
+
+		if err := d.CheckValidID(id); err != nil {
+			vln.l.Println(err.Error())
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+
+		d.SetID(id)
+
+		next(w, r)
+	})
+}
 
+func (vln *Validation) JokeRevisionURLValidation(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ids := strings.Split(r.URL.Path, "/")
+		jr, ok := r.Context().Value(JokeRevisionParamKey{}).(*data.JokeRevision)
 
-		if len(ids) != len(ds) {
-			vln.l.Println("IDs don't match validate funcs")
-			http.Error(w, "Invalid ID", http.StatusBadRequest)
+		if !ok {
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+
+		// /jokes/{id}/revisions[/{rev}/revert]
+		joke := data.Joke{}
+		jokeID := router.Param(r, "id")
+
+		if err := joke.CheckValidID(jokeID); err != nil {
+			vln.l.Println(err.Error())
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
-		var newContext context.Context
+		jr.JokeID = jokeID
 
-		for i := 0; i < len(ds); i++ {
-			if err := ds[i].CheckValidID(ids[i]); err != nil {
+		if revision := router.Param(r, "rev"); revision != "" {
+			rev, err := strconv.ParseUint(revision, 10, 64)
+
+			if err != nil {
 				vln.l.Println(err.Error())
-				http.Error(w, "Invalid ID", http.StatusBadRequest)
+				WriteProblem(w, r, http.StatusBadRequest, "Invalid revision")
 				return
 			}
 
-			newContext = context.WithValue(r.Context(), ctxKey[i], ds)
+			jr.Revision = rev
 		}
 
-		next(w, r.WithContext(newContext))
+		next(w, r)
 	})
-
 }
 
-func (vln *Validation) IDOrEmailUserValidation(next http.HandlerFunc) http.HandlerFunc {
+func (vln *Validation) JokeCommentURLValidation(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
-
-		u, ok := r.Context().Value(UserParamKey{}).(*data.User)
+		jc, ok := r.Context().Value(JokeCommentParamKey{}).(*data.JokeComment)
 
 		if !ok {
-			http.Error(w, "Invalid ID or email", http.StatusBadRequest)
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
-		begin := strings.LastIndex(path, "/")
+		// /jokes/{id}/comments[/{commentID}]
+		joke := data.Joke{}
+		jokeID := router.Param(r, "id")
 
-		if begin == -1 || begin+1 >= len(path) {
-			vln.l.Println(path)
-			http.Error(w, "Invalid ID or email", http.StatusBadRequest)
+		if err := joke.CheckValidID(jokeID); err != nil {
+			vln.l.Println(err.Error())
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
-		id := path[begin+1:]
+		jc.JokeID = jokeID
 
-		if err := u.CheckValidID(id); err != nil {
-			if err = vln.v.Var(id, "email"); err != nil {
-				http.Error(w, "Invalid ID or email", http.StatusBadRequest)
+		if commentID := router.Param(r, "commentID"); commentID != "" {
+			if err := jc.CheckValidID(commentID); err != nil {
+				vln.l.Println(err.Error())
+				WriteProblem(w, r, http.StatusBadRequest, "Invalid ID")
 				return
 			}
-			u.Email = id
-		} else {
-			u.ID = id
+
+			jc.ID = commentID
 		}
 
 		next(w, r)
 	})
 }
 
-func (vln *Validation) OneIDURLValidation(next http.HandlerFunc, ctxKey interface{}) http.HandlerFunc {
+func (vln *Validation) JokeReactionURLValidation(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
-
-		d, ok := r.Context().Value(ctxKey).(data.Data)
+		jr, ok := r.Context().Value(JokeReactionParamKey{}).(*data.JokeReaction)
 
 		if !ok {
-			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
-		begin := strings.LastIndex(path, "/")
+		// /jokes/{id}/reactions/{kind}
+		joke := data.Joke{}
+		jokeID := router.Param(r, "id")
 
-		if begin == -1 || begin+1 >= len(path) {
-			vln.l.Println(path)
-			http.Error(w, "Invalid ID", http.StatusBadRequest)
+		if err := joke.CheckValidID(jokeID); err != nil {
+			vln.l.Println(err.Error())
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
-		id := path[begin+1:]
+		kind := router.Param(r, "kind")
 
-		if err := d.CheckValidID(id); err != nil {
+		if !data.IsJokeReactionKind(kind) {
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid reaction")
+			return
+		}
+
+		jr.JokeID = jokeID
+		jr.Kind = kind
+
+		next(w, r)
+	})
+}
+
+func (vln *Validation) ReportURLValidation(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rp, ok := r.Context().Value(ReportParamKey{}).(*data.Report)
+
+		if !ok {
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+
+		// /jokes/{id}[/comments/{commentID}]/reports
+		joke := data.Joke{}
+		jokeID := router.Param(r, "id")
+
+		if err := joke.CheckValidID(jokeID); err != nil {
 			vln.l.Println(err.Error())
-			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
-		d.SetID(id)
+		rp.JokeID = jokeID
+		rp.TargetType = data.ReportTargetJoke
+		rp.CommentID = nil
+
+		if commentID := router.Param(r, "commentID"); commentID != "" {
+			comment := data.JokeComment{}
+
+			if err := comment.CheckValidID(commentID); err != nil {
+				vln.l.Println(err.Error())
+				WriteProblem(w, r, http.StatusBadRequest, "Invalid ID")
+				return
+			}
+
+			rp.TargetType = data.ReportTargetComment
+			rp.CommentID = &commentID
+		}
 
 		next(w, r)
 	})
@@ -129,32 +284,218 @@ func (vln *Validation) DataValidation(next http.HandlerFunc, ctxKey interface{})
 		d, ok := r.Context().Value(ctxKey).(data.Data)
 
 		if !ok {
-			http.Error(w, "Invalid payload", http.StatusUnprocessableEntity)
+			WriteProblem(w, r, http.StatusUnprocessableEntity, "Invalid payload")
 			return
 		}
 
-		err := d.FromJSON(r.Body)
+		err := decodeBody(r, d)
+
+		if err == codec.ErrNoText {
+			WriteProblem(w, r, http.StatusUnsupportedMediaType, "Plain text isn't accepted here")
+			return
+		}
+
+		if err == errUnsupportedBody {
+			WriteProblem(w, r, http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
 
 		if err != nil {
 			vln.l.Println(err.Error())
-			http.Error(w, "Invalid payload", http.StatusUnprocessableEntity)
+			WriteProblem(w, r, http.StatusUnprocessableEntity, "Invalid payload")
 			return
 		}
 
 		if _, err = d.GetID(); err != nil && err != data.ErrNoID {
 			vln.l.Println(err.Error())
-			http.Error(w, "Invalid payload", http.StatusUnprocessableEntity)
+			WriteProblem(w, r, http.StatusUnprocessableEntity, "Invalid payload")
 			return
 		}
 
+		setDefaults(d)
 		err = vln.v.StructCtx(r.Context(), d)
 
+		if validationErrs, ok := err.(validator.ValidationErrors); ok {
+			writeValidationErrors(w, r, validationErrs)
+			return
+		}
+
 		if err != nil {
 			vln.l.Println(err.Error())
-			http.Error(w, "Invalid payload", http.StatusUnprocessableEntity)
+			WriteProblem(w, r, http.StatusUnprocessableEntity, "Invalid payload")
 			return
 		}
 
 		next(w, r)
 	})
 }
+
+var errUnsupportedBody = errors.New("request bodies can be JSON, XML, YAML or plain text")
+
+// decodeBody decodes the request body into d in the format of its
+// Content-Type, as JSON if there's none, failing with errUnsupportedBody if
+// it's none the API speaks.
+func decodeBody(r *http.Request, d data.Data) error {
+	c, ok := codec.ByContentType(r.Header.Get("Content-Type"))
+
+	if !ok {
+		return errUnsupportedBody
+	}
+
+	if c == codec.JSON {
+		return d.FromJSON(r.Body)
+	}
+
+	return c.Decode(r.Body, d)
+}
+
+// PatchFieldsKey is the context key under which PatchValidation puts the
+// names of the JSON fields a patch changed.
+type PatchFieldsKey struct{}
+
+// PatchValidation applies the JSON Merge Patch or JSON Patch in the request
+// body to the resource current returns for the ID of the data under ctxKey,
+// and decodes the result into it. Only the result is validated, skipping the
+// optional fields (by Go name) it leaves empty.
+func (vln *Validation) PatchValidation(next http.HandlerFunc, ctxKey interface{}, current func(ctx context.Context, id string) (data.Data, error), optional ...string) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, ok := r.Context().Value(ctxKey).(data.Data)
+
+		if !ok {
+			WriteProblem(w, r, http.StatusUnprocessableEntity, "Invalid payload")
+			return
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		if mediaType != patch.MergePatchType && mediaType != patch.JSONPatchType {
+			w.Header().Set("Accept-Patch", patch.MergePatchType+", "+patch.JSONPatchType)
+			WriteProblem(w, r, http.StatusUnsupportedMediaType, "Unsupported patch document type")
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+
+		if err != nil {
+			vln.l.Println(err.Error())
+			WriteProblem(w, r, http.StatusBadRequest, "Invalid patch document")
+			return
+		}
+
+		id, _ := d.GetID()
+		stored, err := current(r.Context(), id)
+
+		if err != nil {
+			if err == repositories.ErrUnknownID {
+				WriteProblem(w, r, http.StatusNotFound, "Unknown ID")
+				return
+			}
+
+			vln.l.Println(err.Error())
+			WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+			return
+		}
+
+		before, err := json.Marshal(stored)
+
+		if err != nil {
+			vln.l.Println(err.Error())
+			WriteProblem(w, r, http.StatusInternalServerError, "Unexpected error")
+			return
+		}
+
+		after, err := patch.Apply(before, mediaType, body)
+
+		if err != nil {
+			if _, ok := err.(*patch.Error); ok {
+				WriteProblem(w, r, http.StatusUnprocessableEntity, err.Error())
+			} else if err == patch.ErrTestFailed {
+				WriteProblem(w, r, http.StatusConflict, err.Error())
+			} else {
+				WriteProblem(w, r, http.StatusBadRequest, "Invalid patch document")
+			}
+
+			return
+		}
+
+		fields, err := patch.ChangedFields(before, after)
+
+		if err != nil {
+			WriteProblem(w, r, http.StatusUnprocessableEntity, "Invalid payload")
+			return
+		}
+
+		if err = json.Unmarshal(after, d); err != nil {
+			vln.l.Println(err.Error())
+			WriteProblem(w, r, http.StatusUnprocessableEntity, "Invalid payload")
+			return
+		}
+
+		setDefaults(d)
+
+		var except []string
+		value := reflect.Indirect(reflect.ValueOf(d))
+
+		for _, name := range optional {
+			if field := value.FieldByName(name); field.IsValid() && field.IsZero() {
+				except = append(except, name)
+			}
+		}
+
+		err = vln.v.StructExceptCtx(r.Context(), d, except...)
+
+		if validationErrs, ok := err.(validator.ValidationErrors); ok {
+			writeValidationErrors(w, r, validationErrs)
+			return
+		}
+
+		if err != nil {
+			vln.l.Println(err.Error())
+			WriteProblem(w, r, http.StatusUnprocessableEntity, "Invalid payload")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), PatchFieldsKey{}, fields)
+
+		next(w, r.WithContext(ctx))
+	})
+}
+
+// setDefaults fills in the fields d defaults when they're left out, before it
+// is validated.
+func setDefaults(d data.Data) {
+	if defaulter, ok := d.(data.Defaulter); ok {
+		defaulter.SetDefaults()
+	}
+}
+
+// ValidateData fills in the defaults of d and validates it, returning the fields that failed validation if
+// any, or an error if it couldn't be validated.
+func (vln *Validation) ValidateData(ctx context.Context, d data.Data) ([]*data.ProblemFieldError, error) {
+	setDefaults(d)
+	err := vln.v.StructCtx(ctx, d)
+
+	if validationErrs, ok := err.(validator.ValidationErrors); ok {
+		return fieldErrors(validationErrs), nil
+	}
+
+	return nil, err
+}
+
+func writeValidationErrors(w http.ResponseWriter, r *http.Request, validationErrs validator.ValidationErrors) {
+	WriteValidationProblem(w, r, fieldErrors(validationErrs)...)
+}
+
+func fieldErrors(validationErrs validator.ValidationErrors) []*data.ProblemFieldError {
+	errs := make([]*data.ProblemFieldError, 0, len(validationErrs))
+
+	for _, fieldErr := range validationErrs {
+		errs = append(errs, &data.ProblemFieldError{
+			Field: fieldErr.Field(),
+			Rule:  fieldErr.Tag(),
+			Param: fieldErr.Param(),
+		})
+	}
+
+	return errs
+}
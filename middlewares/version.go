@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/davq23/jokeapi/data"
+)
+
+type APIVersionKey struct{}
+
+// APIVersion tags the requests with the API version they came through.
+func APIVersion(next http.Handler, version int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), APIVersionKey{}, version)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestAPIVersion returns the API version of the request, v1 if untagged.
+func RequestAPIVersion(r *http.Request) int {
+	if version, ok := r.Context().Value(APIVersionKey{}).(int); ok {
+		return version
+	}
+
+	return data.APIVersion1
+}
+
+// Deprecated marks the responses as coming from deprecated routes, with the
+// date they stop being served if sunset isn't zero, and a link to the path
+// successorPrefix puts them under.
+func Deprecated(next http.Handler, sunset time.Time, successorPrefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successorPrefix+r.URL.Path+`>; rel="successor-version"`)
+
+		if !sunset.IsZero() {
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
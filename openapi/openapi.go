@@ -0,0 +1,314 @@
+package openapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/davq23/jokeapi/patch"
+)
+
+const Version = "3.0.3"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []*Server           `json:"servers,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+	Tags       []*Tag              `json:"tags,omitempty"`
+}
+
+func (d *Document) ToJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(d)
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+type Scopes []string
+
+// PathItem maps the lowercase methods of a path to their operations.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	Security    []map[string]Scopes  `json:"security"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	Responses       map[string]*Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// Auth is the authentication an operation requires.
+type Auth int
+
+const (
+	AuthNone Auth = iota
+	AuthOptional
+	AuthUser
+	AuthAdmin
+)
+
+// BearerAuth is the name of the security scheme of authenticated operations.
+const BearerAuth = "bearerAuth"
+
+// Route documents the operation served by a route.
+type Route struct {
+	Summary string
+	Tag     string
+	Auth    Auth
+
+	// Params overrides the schema of path params, which are strings by
+	// default, Query lists the query params and Headers the request headers.
+	Params  map[string]*Schema
+	Query   []*Parameter
+	Headers []*Parameter
+
+	// Request and Response are zero values of the body types, nil if there's
+	// no body. A List response is a data.QueryAllResponse of Response items.
+	Request  interface{}
+	Response interface{}
+	List     bool
+
+	// Patch makes the request a JSON Merge Patch of Request or a JSON Patch.
+	Patch bool
+
+	// Errors lists the error statuses besides the ones implied by Auth and
+	// 500.
+	Errors []int
+}
+
+// Builder builds a Document from routes, deriving the schemas from the Go
+// types of their bodies.
+type Builder struct {
+	doc        *Document
+	list       interface{}
+	mediaTypes []string
+}
+
+// NewBuilder returns a Builder for the API described by info. list is the
+// envelope of List responses, whose "results" property holds the items, and
+// problem the body of error responses.
+func NewBuilder(info Info, list interface{}, problem interface{}) *Builder {
+	b := &Builder{
+		doc: &Document{
+			OpenAPI: Version,
+			Info:    info,
+			Paths:   map[string]PathItem{},
+			Components: Components{
+				Schemas: map[string]*Schema{},
+				SecuritySchemes: map[string]*SecurityScheme{
+					BearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				},
+			},
+		},
+		list:       list,
+		mediaTypes: []string{"application/json"},
+	}
+
+	b.doc.Components.Responses = map[string]*Response{
+		"Error": {
+			Description: "Error",
+			Content: map[string]*MediaType{
+				"application/problem+json": {Schema: b.SchemaOf(problem)},
+			},
+		},
+	}
+
+	return b
+}
+
+// SetMediaTypes sets the media types request and response bodies are
+// documented in, JSON by default.
+func (b *Builder) SetMediaTypes(mediaTypes ...string) {
+	b.mediaTypes = mediaTypes
+}
+
+func (b *Builder) content(schema *Schema) map[string]*MediaType {
+	content := make(map[string]*MediaType, len(b.mediaTypes))
+
+	for _, mediaType := range b.mediaTypes {
+		content[mediaType] = &MediaType{Schema: schema}
+	}
+
+	return content
+}
+
+func (b *Builder) AddServer(url string, description string) {
+	b.doc.Servers = append(b.doc.Servers, &Server{URL: url, Description: description})
+}
+
+// AddRoute documents the operation served by method on pattern.
+func (b *Builder) AddRoute(method string, pattern string, route *Route) {
+	op := &Operation{
+		OperationID: operationID(method, pattern),
+		Summary:     route.Summary,
+		Responses:   map[string]*Response{},
+		Security:    []map[string]Scopes{},
+	}
+
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+		b.addTag(route.Tag)
+	}
+
+	for _, segment := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+
+		name := segment[1 : len(segment)-1]
+		schema, ok := route.Params[name]
+
+		if !ok {
+			schema = &Schema{Type: "string"}
+		}
+
+		op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+
+	op.Parameters = append(op.Parameters, route.Query...)
+	op.Parameters = append(op.Parameters, route.Headers...)
+
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  b.content(b.SchemaOf(route.Request)),
+		}
+
+		if route.Patch {
+			op.RequestBody.Content = map[string]*MediaType{
+				patch.MergePatchType: {Schema: b.SchemaOf(route.Request)},
+				patch.JSONPatchType:  {Schema: b.SchemaOf([]patch.Operation{})},
+			}
+		}
+	}
+
+	success := &Response{Description: http.StatusText(http.StatusOK)}
+
+	if route.Response != nil {
+		schema := b.SchemaOf(route.Response)
+
+		if route.List {
+			schema = &Schema{AllOf: []*Schema{
+				b.SchemaOf(b.list),
+				{Type: "object", Properties: map[string]*Schema{"results": {Type: "array", Items: schema}}},
+			}}
+		}
+
+		success.Content = b.content(schema)
+	}
+
+	op.Responses[strconv.Itoa(http.StatusOK)] = success
+
+	errors := append([]int{http.StatusInternalServerError}, route.Errors...)
+
+	switch route.Auth {
+	case AuthOptional:
+		op.Security = []map[string]Scopes{{}, {BearerAuth: {}}}
+	case AuthUser:
+		op.Security = []map[string]Scopes{{BearerAuth: {}}}
+		errors = append(errors, http.StatusUnauthorized)
+	case AuthAdmin:
+		op.Security = []map[string]Scopes{{BearerAuth: {}}}
+		errors = append(errors, http.StatusUnauthorized, http.StatusForbidden)
+	}
+
+	for _, status := range errors {
+		op.Responses[strconv.Itoa(status)] = &Response{Ref: "#/components/responses/Error"}
+	}
+
+	item, ok := b.doc.Paths[pattern]
+
+	if !ok {
+		item = PathItem{}
+		b.doc.Paths[pattern] = item
+	}
+
+	item[strings.ToLower(method)] = op
+}
+
+func (b *Builder) addTag(name string) {
+	for _, tag := range b.doc.Tags {
+		if tag.Name == name {
+			return
+		}
+	}
+
+	b.doc.Tags = append(b.doc.Tags, &Tag{Name: name})
+
+	sort.Slice(b.doc.Tags, func(i, j int) bool {
+		return b.doc.Tags[i].Name < b.doc.Tags[j].Name
+	})
+}
+
+func (b *Builder) Document() *Document {
+	return b.doc
+}
+
+// operationID turns GET /jokes/{id}/comments into getJokesIdComments.
+func operationID(method string, pattern string) string {
+	id := strings.ToLower(method)
+
+	for _, segment := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		segment = strings.Trim(segment, "{}")
+
+		if segment != "" {
+			id += strings.ToUpper(segment[:1]) + segment[1:]
+		}
+	}
+
+	return id
+}
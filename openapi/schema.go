@@ -0,0 +1,244 @@
+package openapi
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *uint64            `json:"minLength,omitempty"`
+	MaxLength            *uint64            `json:"maxLength,omitempty"`
+	MaxItems             *uint64            `json:"maxItems,omitempty"`
+	UniqueItems          bool               `json:"uniqueItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf returns the schema of v's type. Named structs are added to the
+// components and referenced.
+func (b *Builder) SchemaOf(v interface{}) *Schema {
+	return b.schemaOf(reflect.TypeOf(v))
+}
+
+func (b *Builder) schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := b.schemaOf(t.Elem())
+
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int" + strconv.Itoa(t.Bits())}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Format: "int" + strconv.Itoa(t.Bits()), Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+
+		ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+
+		if _, ok := b.doc.Components.Schemas[t.Name()]; !ok {
+			// Registered before being built, for recursive types.
+			b.doc.Components.Schemas[t.Name()] = &Schema{}
+			*b.doc.Components.Schemas[t.Name()] = *b.structSchema(t)
+		}
+
+		return ref
+	}
+
+	return &Schema{}
+}
+
+func (b *Builder) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name, _ := parseJSONTag(field)
+
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				inner := b.structSchema(embedded)
+
+				for property, propertySchema := range inner.Properties {
+					if _, ok := schema.Properties[property]; !ok {
+						schema.Properties[property] = propertySchema
+					}
+				}
+
+				schema.Required = append(schema.Required, inner.Required...)
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property := b.schemaOf(field.Type)
+
+		if applyValidateTag(property, field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+
+		schema.Properties[name] = property
+	}
+
+	sort.Strings(schema.Required)
+
+	return schema
+}
+
+func parseJSONTag(field reflect.StructField) (string, bool) {
+	parts := strings.Split(field.Tag.Get("json"), ",")
+
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			return parts[0], true
+		}
+	}
+
+	return parts[0], false
+}
+
+// applyValidateTag adds the constraints of the validate tag to the schema,
+// reporting whether it makes the field required. Tags after dive apply to the
+// items.
+func applyValidateTag(schema *Schema, tag string) bool {
+	if tag == "" {
+		return false
+	}
+
+	required := false
+	target := schema
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param := rule, ""
+
+		if i := strings.Index(rule, "="); i != -1 {
+			name, param = rule[:i], rule[i+1:]
+		}
+
+		// Constraints on refs can't be expressed alongside them.
+		if target.Ref != "" {
+			if name == "required" && target == schema {
+				required = true
+			}
+
+			continue
+		}
+
+		switch name {
+		case "required":
+			required = required || target == schema
+		case "dive":
+			if target.Items != nil {
+				target = target.Items
+			}
+		case "oneof":
+			target.Enum = strings.Fields(param)
+		case "email", "uuid":
+			target.Format = name
+		case "unique":
+			target.UniqueItems = true
+		case "min", "max", "gt", "gte", "lt", "lte":
+			n, err := strconv.ParseFloat(param, 64)
+
+			if err != nil {
+				continue
+			}
+
+			applyBound(target, name, n)
+		}
+	}
+
+	return required
+}
+
+func applyBound(schema *Schema, name string, n float64) {
+	switch schema.Type {
+	case "string":
+		length := uint64(n)
+
+		if name == "min" {
+			schema.MinLength = &length
+		} else if name == "max" {
+			schema.MaxLength = &length
+		}
+	case "array":
+		if name == "max" {
+			items := uint64(n)
+			schema.MaxItems = &items
+		}
+	case "integer", "number":
+		switch name {
+		case "min", "gte":
+			schema.Minimum = &n
+		case "gt":
+			schema.Minimum = &n
+			schema.ExclusiveMinimum = true
+		case "max", "lte":
+			schema.Maximum = &n
+		case "lt":
+			schema.Maximum = &n
+			schema.ExclusiveMaximum = true
+		}
+	}
+}
@@ -0,0 +1,529 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902)
+// documents to JSON documents.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const MergePatchType = "application/merge-patch+json"
+const JSONPatchType = "application/json-patch+json"
+
+// ErrUnsupportedType is returned for patch documents of a media type other
+// than MergePatchType and JSONPatchType.
+var ErrUnsupportedType = errors.New("unsupported patch media type")
+
+// ErrInvalidPatch is returned for malformed patch documents.
+var ErrInvalidPatch = errors.New("invalid patch document")
+
+// ErrTestFailed is returned when a JSON Patch test operation fails.
+var ErrTestFailed = errors.New("patch test operation failed")
+
+// Error is a patch operation that can't be applied to the document.
+type Error struct {
+	Op   string
+	Path string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %q: %s", e.Op, e.Path, e.Msg)
+}
+
+// Operation is an operation of a JSON Patch document.
+type Operation struct {
+	Op    string      `json:"op" validate:"required,oneof=add remove replace move copy test"`
+	Path  string      `json:"path" validate:"required"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Apply applies a patch document of the given media type to doc.
+func Apply(doc []byte, mediaType string, patch []byte) ([]byte, error) {
+	switch mediaType {
+	case MergePatchType:
+		return MergePatch(doc, patch)
+	case JSONPatchType:
+		return JSONPatch(doc, patch)
+	}
+
+	return nil, ErrUnsupportedType
+}
+
+// MergePatch applies a JSON Merge Patch to doc.
+func MergePatch(doc []byte, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decode(patch)
+
+	if err != nil {
+		return nil, ErrInvalidPatch
+	}
+
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+
+	if !ok {
+		t = map[string]interface{}{}
+	}
+
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+		} else {
+			t[name] = mergePatch(t[name], value)
+		}
+	}
+
+	return t
+}
+
+// JSONPatch applies the operations of a JSON Patch to doc, in order. It fails
+// as a whole if any of them fails.
+func JSONPatch(doc []byte, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Decoded twice to tell a missing value from a null one.
+	var ops []Operation
+	var raw []map[string]json.RawMessage
+
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, ErrInvalidPatch
+	}
+
+	if err := json.Unmarshal(patch, &raw); err != nil {
+		return nil, ErrInvalidPatch
+	}
+
+	for i, op := range ops {
+		if _, ok := raw[i]["value"]; ok {
+			// Numbers are kept as json.Number, like in the document.
+			if op.Value, err = decode(raw[i]["value"]); err != nil {
+				return nil, ErrInvalidPatch
+			}
+		} else if op.Op == "add" || op.Op == "replace" || op.Op == "test" {
+			return nil, ErrInvalidPatch
+		}
+
+		if target, err = apply(target, &op); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+func apply(doc interface{}, op *Operation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+
+	if err != nil {
+		return nil, &Error{op.Op, op.Path, err.Error()}
+	}
+
+	var from []string
+
+	if op.Op == "move" || op.Op == "copy" {
+		if from, err = parsePointer(op.From); err != nil {
+			return nil, &Error{op.Op, op.From, err.Error()}
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		doc, err = add(doc, path, op.Value, false)
+	case "remove":
+		doc, _, err = remove(doc, path)
+	case "replace":
+		doc, err = add(doc, path, op.Value, true)
+	case "move":
+		if len(from) < len(path) && isPrefix(from, path) {
+			return nil, &Error{op.Op, op.Path, "can't move a value into itself"}
+		}
+
+		var value interface{}
+
+		if doc, value, err = remove(doc, from); err == nil {
+			doc, err = add(doc, path, value, false)
+		}
+	case "copy":
+		var value interface{}
+
+		if value, err = get(doc, from); err == nil {
+			doc, err = add(doc, path, deepCopy(value), false)
+		}
+	case "test":
+		var value interface{}
+
+		if value, err = get(doc, path); err == nil && !equal(value, op.Value) {
+			return nil, ErrTestFailed
+		}
+	default:
+		return nil, ErrInvalidPatch
+	}
+
+	if err != nil {
+		return nil, &Error{op.Op, op.Path, err.Error()}
+	}
+
+	return doc, nil
+}
+
+// add sets the value at path, inserting it into arrays unless replace is
+// set, in which case the value must already exist.
+func add(doc interface{}, path []string, value interface{}, replace bool) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token := path[0]
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+
+		if len(path) == 1 {
+			if replace && !ok {
+				return nil, errors.New("no such member")
+			}
+
+			node[token] = value
+
+			return node, nil
+		}
+
+		if !ok {
+			return nil, errors.New("no such member")
+		}
+
+		child, err := add(child, path[1:], value, replace)
+
+		if err != nil {
+			return nil, err
+		}
+
+		node[token] = child
+
+		return node, nil
+	case []interface{}:
+		if len(path) == 1 && !replace {
+			i := len(node)
+
+			if token != "-" {
+				var err error
+
+				if i, err = parseIndex(token, len(node)+1); err != nil {
+					return nil, err
+				}
+			}
+
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+
+			return node, nil
+		}
+
+		i, err := parseIndex(token, len(node))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(path) == 1 {
+			node[i] = value
+
+			return node, nil
+		}
+
+		if node[i], err = add(node[i], path[1:], value, replace); err != nil {
+			return nil, err
+		}
+
+		return node, nil
+	}
+
+	return nil, errors.New("not a container")
+}
+
+// remove removes the value at path, returning it.
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("can't remove the whole document")
+	}
+
+	token := path[0]
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+
+		if !ok {
+			return nil, nil, errors.New("no such member")
+		}
+
+		if len(path) == 1 {
+			delete(node, token)
+
+			return node, child, nil
+		}
+
+		child, value, err := remove(child, path[1:])
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		node[token] = child
+
+		return node, value, nil
+	case []interface{}:
+		i, err := parseIndex(token, len(node))
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(path) == 1 {
+			value := node[i]
+
+			return append(node[:i], node[i+1:]...), value, nil
+		}
+
+		child, value, err := remove(node[i], path[1:])
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		node[i] = child
+
+		return node, value, nil
+	}
+
+	return nil, nil, errors.New("not a container")
+}
+
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			child, ok := node[token]
+
+			if !ok {
+				return nil, errors.New("no such member")
+			}
+
+			doc = child
+		case []interface{}:
+			i, err := parseIndex(token, len(node))
+
+			if err != nil {
+				return nil, err
+			}
+
+			doc = node[i]
+		default:
+			return nil, errors.New("not a container")
+		}
+	}
+
+	return doc, nil
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.New("invalid JSON pointer")
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+
+	return tokens, nil
+}
+
+// parseIndex parses an array index lower than length.
+func parseIndex(token string, length int) (int, error) {
+	if token == "" || len(token) > 1 && token[0] == '0' || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, errors.New("invalid array index")
+	}
+
+	i, err := strconv.Atoi(token)
+
+	if err != nil || i >= length {
+		return 0, errors.New("array index out of bounds")
+	}
+
+	return i, nil
+}
+
+func isPrefix(prefix []string, path []string) bool {
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// equal reports whether two decoded JSON values are equal, comparing numbers
+// by value so that e.g. 1, 1.0 and 1e0 are the same.
+func equal(a interface{}, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+
+		if !ok {
+			return false
+		}
+
+		x, okX := new(big.Rat).SetString(a.String())
+		y, okY := new(big.Rat).SetString(b.String())
+
+		if !okX || !okY {
+			return a == b
+		}
+
+		return x.Cmp(y) == 0
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+
+		if !ok || len(a) != len(b) {
+			return false
+		}
+
+		for name, child := range a {
+			other, ok := b[name]
+
+			if !ok || !equal(child, other) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+
+		if !ok || len(a) != len(b) {
+			return false
+		}
+
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+
+		for name, child := range v {
+			c[name] = deepCopy(child)
+		}
+
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+
+		for i, child := range v {
+			c[i] = deepCopy(child)
+		}
+
+		return c
+	}
+
+	return value
+}
+
+func decode(doc []byte) (interface{}, error) {
+	var v interface{}
+
+	d := json.NewDecoder(bytes.NewReader(doc))
+	d.UseNumber()
+
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// ChangedFields returns the sorted names of the members of the JSON objects
+// before and after whose values differ, including added and removed ones.
+func ChangedFields(before []byte, after []byte) ([]string, error) {
+	var b, a map[string]interface{}
+
+	if err := decodeObject(before, &b); err != nil {
+		return nil, err
+	}
+
+	if err := decodeObject(after, &a); err != nil {
+		return nil, err
+	}
+
+	fields := []string{}
+
+	for name, value := range b {
+		if other, ok := a[name]; !ok || !reflect.DeepEqual(value, other) {
+			fields = append(fields, name)
+		}
+	}
+
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			fields = append(fields, name)
+		}
+	}
+
+	sort.Strings(fields)
+
+	return fields, nil
+}
+
+func decodeObject(doc []byte, v *map[string]interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(doc))
+	d.UseNumber()
+
+	return d.Decode(v)
+}
@@ -0,0 +1,124 @@
+package test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/davq23/jokeapi/patch"
+)
+
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var g, w interface{}
+
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	doc := `{"type": "single", "text": "A joke", "flags": {"nsfw": false, "political": true}}`
+	p := `{"text": null, "setup": "Setup", "flags": {"nsfw": true}}`
+
+	result, err := patch.MergePatch([]byte(doc), []byte(p))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertJSON(t, result, `{"type": "single", "setup": "Setup", "flags": {"nsfw": true, "political": true}}`)
+}
+
+func TestJSONPatch(t *testing.T) {
+	doc := `{"a/b": 1, "list": ["x", "y"], "nested": {"n": 2}}`
+	p := `[
+		{"op": "test", "path": "/a~1b", "value": 1},
+		{"op": "add", "path": "/list/1", "value": "z"},
+		{"op": "add", "path": "/list/-", "value": null},
+		{"op": "remove", "path": "/list/0"},
+		{"op": "replace", "path": "/nested/n", "value": 3},
+		{"op": "copy", "from": "/nested", "path": "/copied"},
+		{"op": "move", "from": "/a~1b", "path": "/moved"}
+	]`
+
+	result, err := patch.JSONPatch([]byte(doc), []byte(p))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertJSON(t, result, `{"list": ["z", "y", null], "nested": {"n": 3}, "copied": {"n": 3}, "moved": 1}`)
+}
+
+func TestJSONPatchErrors(t *testing.T) {
+	doc := []byte(`{"a": {"b": 1}, "list": [1]}`)
+
+	// A nil err stands for any *patch.Error.
+	tests := []struct {
+		patch string
+		err   error
+	}{
+		{`[{"op": "test", "path": "/a/b", "value": 2}]`, patch.ErrTestFailed},
+		{`[{"op": "replace", "path": "/missing", "value": 2}]`, nil},
+		{`[{"op": "remove", "path": "/list/1"}]`, nil},
+		{`[{"op": "add", "path": "/list/01", "value": 2}]`, nil},
+		{`[{"op": "move", "from": "/a", "path": "/a/c"}]`, nil},
+		{`[{"op": "add", "path": "/a"}]`, patch.ErrInvalidPatch},
+		{`[{"op": "frobnicate", "path": "/a"}]`, patch.ErrInvalidPatch},
+		{`{"op": "add"}`, patch.ErrInvalidPatch},
+	}
+
+	for _, test := range tests {
+		_, err := patch.JSONPatch(doc, []byte(test.patch))
+
+		if _, ok := err.(*patch.Error); test.err == nil && !ok || test.err != nil && err != test.err {
+			t.Errorf("%s: got error %v, want %v", test.patch, err, test.err)
+		}
+	}
+}
+
+func TestJSONPatchTestNumbers(t *testing.T) {
+	doc := []byte(`{"a": {"b": 1}, "list": [1.5]}`)
+
+	tests := []struct {
+		patch string
+		err   error
+	}{
+		{`[{"op": "test", "path": "/a/b", "value": 1.0}]`, nil},
+		{`[{"op": "test", "path": "/a/b", "value": 1e0}]`, nil},
+		{`[{"op": "test", "path": "/a", "value": {"b": 10e-1}}]`, nil},
+		{`[{"op": "test", "path": "/list", "value": [15e-1]}]`, nil},
+		{`[{"op": "test", "path": "/a/b", "value": "1"}]`, patch.ErrTestFailed},
+		{`[{"op": "test", "path": "/list", "value": [1.5, 1.5]}]`, patch.ErrTestFailed},
+	}
+
+	for _, test := range tests {
+		if _, err := patch.JSONPatch(doc, []byte(test.patch)); err != test.err {
+			t.Errorf("%s: got error %v, want %v", test.patch, err, test.err)
+		}
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	fields, err := patch.ChangedFields(
+		[]byte(`{"a": 1, "b": {"c": 2}, "d": 3}`),
+		[]byte(`{"a": 1, "b": {"c": 4}, "e": 5}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fields, []string{"b", "d", "e"}) {
+		t.Errorf("got %v", fields)
+	}
+}
@@ -0,0 +1,16 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/davq23/jokeapi/data"
+)
+
+type CollectionCRUD interface {
+	FetchAll(ctx context.Context, ownerID string, limit uint64, offset string, direction FetchDirection) (data.Collections, *string, error)
+	FetchOne(ctx context.Context, id string) (*data.Collection, error)
+	FetchBySlug(ctx context.Context, slug string) (*data.Collection, error)
+	Insert(ctx context.Context, collection *data.Collection) (string, error)
+	Update(ctx context.Context, id string, collection *data.Collection) (string, error)
+	Delete(ctx context.Context, id string) (string, error)
+}
@@ -2,17 +2,77 @@ package repositories
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/davq23/jokeapi/data"
 )
 
+const SortScore = "score"
+
+// SortReactionsPrefix followed by a reaction kind sorts jokes by how many
+// reactions of that kind they got, e.g. "reactions.laugh".
+const SortReactionsPrefix = "reactions."
+
+// SortReactionKind returns the reaction kind sort orders by, if any.
+func SortReactionKind(sort string) (string, bool) {
+	if !strings.HasPrefix(sort, SortReactionsPrefix) {
+		return "", false
+	}
+
+	kind := strings.TrimPrefix(sort, SortReactionsPrefix)
+
+	return kind, data.IsJokeReactionKind(kind)
+}
+
+// JokePatchFields are the JSON fields of a joke Patch writes, and Update
+// writes all of. Both edit the revision of the joke they're given, failing
+// with ErrStaleRevision if it isn't the current one, and bump it if the
+// content changed. Delete likewise only trashes the revision it's given.
+var JokePatchFields = []string{"author_id", "type", "text", "setup", "delivery", "explanation", "lang", "flags"}
+
+type JokeFilter struct {
+	Type           string
+	Status         string
+	BlacklistFlags []string
+	Sort           string
+	Language       string
+	IncludeHidden  bool
+}
+
 type JokeCRUD interface {
-	Delete(ctx context.Context, id string) (string, error)
-	FetchAll(ctx context.Context, limit uint64, offset string, direction FetchDirection) (data.Jokes, *string, error)
+	Delete(ctx context.Context, id string, revision uint64, deletedBy string) (string, error)
+	FetchTrash(ctx context.Context, limit uint64, offset string, direction FetchDirection) (data.Jokes, *string, error)
+	Restore(ctx context.Context, id string) (string, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	FetchAll(ctx context.Context, limit uint64, offset string, direction FetchDirection, filter *JokeFilter) (data.Jokes, *string, error)
 	FetchRatings(ctx context.Context, jokeID string, limit uint64, offset string, direction FetchDirection) (data.JokeRatings, *string, error)
+	FetchFirstRatings(ctx context.Context, jokeIDs []string, limit uint64) (map[string]data.JokeRatings, error)
 	FetchOne(ctx context.Context, id string) (*data.Joke, error)
+	FetchMany(ctx context.Context, ids []string) (data.Jokes, error)
+	FetchLanguages(ctx context.Context) ([]string, error)
+	FetchRatingEvents(ctx context.Context, since time.Time) (data.JokeRatingEvents, error)
 	Insert(ctx context.Context, joke *data.Joke) (string, error)
-	Update(ctx context.Context, id string, joke *data.Joke) (string, error)
+	InsertMany(ctx context.Context, jokes data.Jokes) (int64, error)
+	Update(ctx context.Context, id string, joke *data.Joke, editorID string) (string, error)
+	Patch(ctx context.Context, id string, joke *data.Joke, fields []string, editorID string) (string, error)
+	FetchRevisions(ctx context.Context, jokeID string) (data.JokeRevisions, error)
 	RateJoke(ctx context.Context, jokeID string, jokeRating *data.JokeRating) (string, error)
-	DeleteRating(ctx context.Context, jokeID string, ratingID string, authID string) (string, error)
+	DeleteRating(ctx context.Context, jokeID string, ratingID string, authID string, admin bool) (string, error)
+	Moderate(ctx context.Context, id string, moderation *data.JokeModeration) (string, error)
+	AddFavorite(ctx context.Context, favorite *data.JokeFavorite) (string, error)
+	RemoveFavorite(ctx context.Context, jokeID string, userID string) (string, error)
+	FetchFavorites(ctx context.Context, userID string, limit uint64, offset string, direction FetchDirection) (data.Jokes, *string, error)
+	FetchComments(ctx context.Context, jokeID string, limit uint64, offset string, direction FetchDirection) (data.JokeComments, *string, error)
+	FetchComment(ctx context.Context, jokeID string, commentID string) (*data.JokeComment, error)
+	InsertComment(ctx context.Context, comment *data.JokeComment) (string, error)
+	UpdateComment(ctx context.Context, comment *data.JokeComment) (string, error)
+	DeleteComment(ctx context.Context, jokeID string, commentID string) (string, error)
+	RemoveComment(ctx context.Context, jokeID string, commentID string, removedBy string) (string, error)
+	InsertReport(ctx context.Context, report *data.Report, hideThreshold uint64) (string, error)
+	FetchReports(ctx context.Context, status string, limit uint64, offset string, direction FetchDirection) (data.Reports, *string, error)
+	FetchReport(ctx context.Context, id string) (*data.Report, error)
+	ResolveReports(ctx context.Context, report *data.Report, resolution *data.ReportResolution, resolvedBy string) (int64, error)
+	AddReaction(ctx context.Context, reaction *data.JokeReaction) (string, error)
+	RemoveReaction(ctx context.Context, jokeID string, userID string, kind string) (string, error)
 }
@@ -0,0 +1,114 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/repositories"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type CollectionCRUD struct {
+	c *mongo.Collection
+}
+
+func NewCollection(c *mongo.Collection) *CollectionCRUD {
+	return &CollectionCRUD{
+		c: c,
+	}
+}
+
+func (cr *CollectionCRUD) FetchAll(ctx context.Context, ownerID string, limit uint64, offset string, direction repositories.FetchDirection) (data.Collections, *string, error) {
+	var collections data.Collections
+
+	condition, options := paginate(offset, "id", limit+1, direction)
+	condition["owner_id"] = ownerID
+
+	cursor, err := cr.c.Find(ctx, condition, options)
+
+	if err != nil {
+		return collections, nil, repositories.ErrInvalidOffset
+	}
+
+	defer cursor.Close(ctx)
+
+	collections = make(data.Collections, 0, limit+1)
+
+	if err = cursor.All(ctx, &collections); err != nil {
+		return nil, nil, err
+	}
+
+	var nextID *string
+
+	if uint64(len(collections)) > limit {
+		nextID = &collections[limit].ID
+		collections = collections[:limit]
+	}
+
+	return collections, nextID, nil
+}
+
+func (cr *CollectionCRUD) FetchOne(ctx context.Context, id string) (*data.Collection, error) {
+	return cr.fetchOne(ctx, bson.M{"id": id})
+}
+
+func (cr *CollectionCRUD) FetchBySlug(ctx context.Context, slug string) (*data.Collection, error) {
+	return cr.fetchOne(ctx, bson.M{"slug": slug})
+}
+
+func (cr *CollectionCRUD) fetchOne(ctx context.Context, filter bson.M) (*data.Collection, error) {
+	collection := new(data.Collection)
+
+	if err := cr.c.FindOne(ctx, filter).Decode(collection); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, repositories.ErrUnknownID
+		}
+
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+func (cr *CollectionCRUD) Insert(ctx context.Context, collection *data.Collection) (string, error) {
+	if _, err := cr.c.InsertOne(ctx, collection); err != nil {
+		return "", err
+	}
+
+	return collection.ID, nil
+}
+
+func (cr *CollectionCRUD) Update(ctx context.Context, id string, collection *data.Collection) (string, error) {
+	result, err := cr.c.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": bson.M{
+		"name":        collection.Name,
+		"description": collection.Description,
+		"visibility":  collection.Visibility,
+		"joke_ids":    collection.JokeIDs,
+		"updated_at":  collection.UpdatedAt,
+	}})
+
+	if err != nil {
+		return "", err
+	}
+
+	if result.MatchedCount == 0 {
+		return id, repositories.ErrUnknownID
+	}
+
+	return id, nil
+}
+
+func (cr *CollectionCRUD) Delete(ctx context.Context, id string) (string, error) {
+	result, err := cr.c.DeleteOne(ctx, bson.M{"id": id})
+
+	if err != nil {
+		return "", err
+	}
+
+	if result.DeletedCount == 0 {
+		return id, repositories.ErrUnknownID
+	}
+
+	return id, nil
+}
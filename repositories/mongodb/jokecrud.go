@@ -2,6 +2,8 @@ package mongodb
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/davq23/jokeapi/data"
 	"github.com/davq23/jokeapi/repositories"
@@ -9,15 +11,26 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type JokeCRUD struct {
-	c *mongo.Collection
+	c         *mongo.Collection
+	revisions *mongo.Collection
+	favorites *mongo.Collection
+	comments  *mongo.Collection
+	reports   *mongo.Collection
+	reactions *mongo.Collection
 }
 
 func NewJoke(c *mongo.Collection) *JokeCRUD {
 	return &JokeCRUD{
-		c: c,
+		c:         c,
+		revisions: c.Database().Collection("joke_revisions"),
+		favorites: c.Database().Collection("joke_favorites"),
+		comments:  c.Database().Collection("joke_comments"),
+		reports:   c.Database().Collection("reports"),
+		reactions: c.Database().Collection("joke_reactions"),
 	}
 }
 
@@ -26,40 +39,59 @@ func (jr *JokeCRUD) CheckValidID(fl validator.FieldLevel) bool {
 	return primitive.IsValidObjectID(id)
 }
 
-func (jr *JokeCRUD) FetchAll(ctx context.Context, limit uint64, offset string, direction repositories.FetchDirection) (data.Jokes, *string, error) {
+func (jr *JokeCRUD) FetchAll(ctx context.Context, limit uint64, offset string, direction repositories.FetchDirection, filter *repositories.JokeFilter) (data.Jokes, *string, error) {
 	var jokes data.Jokes
 
-	condition, _ := paginate(offset, "id", limit+1, direction)
-
-	cursor, err := jr.c.Aggregate(ctx,
-		bson.A{bson.M{"$match": condition},
-
-			bson.M{"$unwind": bson.M{"path": "$ratings", "preserveNullAndEmptyArrays": true}},
-			bson.M{
-				"$group": bson.M{
-					"_id":         "$id",
-					"id":          bson.M{"$first": "$id"},
-					"text":        bson.M{"$first": "$text"},
-					"explanation": bson.M{"$first": "$explanation"},
-					"language":    bson.M{"$first": "$language"},
-					"avgRating": bson.M{
-						"$avg": bson.M{"$cond": bson.A{
-							bson.M{
-								"$eq": bson.A{"$ratings", nil},
-							}, 0, "$ratings.rating",
-						}},
-					},
-				},
-			},
-			bson.M{
-				"$sort": bson.M{
-					"id": direction,
-				},
-			},
-			bson.M{
-				"$limit": limit + 1,
-			},
-		})
+	var sortField string
+
+	if filter != nil && filter.Sort == repositories.SortScore {
+		sortField = "score"
+	} else if filter != nil {
+		if kind, ok := repositories.SortReactionKind(filter.Sort); ok {
+			sortField = "reactions." + kind
+		}
+	}
+
+	var condition bson.M
+	var findOptions *options.FindOptions
+
+	if sortField != "" {
+		var err error
+
+		if condition, findOptions, err = paginateSorted(offset, sortField, limit+1, direction); err != nil {
+			return jokes, nil, err
+		}
+	} else {
+		condition, findOptions = paginate(offset, "id", limit+1, direction)
+	}
+
+	condition["deleted_at"] = nil
+
+	if filter != nil && filter.Type != "" {
+		condition["type"] = filter.Type
+	}
+
+	if filter != nil && filter.Status != "" {
+		condition["status"] = filter.Status
+	}
+
+	if filter != nil && filter.Language != "" {
+		condition["language"] = filter.Language
+	}
+
+	if filter == nil || !filter.IncludeHidden {
+		condition["hidden"] = bson.M{"$ne": true}
+	}
+
+	if filter != nil {
+		for _, flag := range filter.BlacklistFlags {
+			condition["flags."+flag] = bson.M{"$ne": true}
+		}
+	}
+
+	findOptions.SetProjection(bson.M{"ratings": 0, "rating_histogram": 0})
+
+	cursor, err := jr.c.Find(ctx, condition, findOptions)
 
 	if err != nil {
 		return jokes, nil, err
@@ -93,19 +125,85 @@ func (jr *JokeCRUD) FetchAll(ctx context.Context, limit uint64, offset string, d
 			return jokes, nil, err
 		}
 
-		nextID = &joke.ID
+		if sortField == "score" {
+			next := repositories.EncodeSortCursor(joke.Score, joke.ID)
+			nextID = &next
+		} else if sortField != "" {
+			next := repositories.EncodeSortCursor(float64(joke.Reactions[strings.TrimPrefix(sortField, "reactions.")]), joke.ID)
+			nextID = &next
+		} else {
+			nextID = &joke.ID
+		}
 	}
 
 	return jokes, nextID, nil
 }
 
 func (jr *JokeCRUD) FetchRatings(ctx context.Context, jokeID string, limit uint64, offset string, direction repositories.FetchDirection) (data.JokeRatings, *string, error) {
-	//, _ := paginate(offset, "ratings.id", limit+1, direction)
-	return nil, nil, nil
+	var ratings data.JokeRatings
+
+	count, err := jr.c.CountDocuments(ctx, bson.M{"id": jokeID, "deleted_at": nil})
+
+	if err != nil {
+		return ratings, nil, err
+	}
+
+	if count == 0 {
+		return ratings, nil, repositories.ErrUnknownID
+	}
+
+	condition, _ := paginate(offset, "ratings.id", limit+1, direction)
+
+	cursor, err := jr.c.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{"id": jokeID, "deleted_at": nil}},
+		bson.M{"$unwind": "$ratings"},
+		bson.M{"$match": condition},
+		bson.M{"$sort": bson.M{"ratings.id": direction}},
+		bson.M{"$limit": limit + 1},
+		bson.M{"$replaceRoot": bson.M{"newRoot": "$ratings"}},
+	})
+
+	if err != nil {
+		return ratings, nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	i := uint64(0)
+
+	ratings = make(data.JokeRatings, 0, limit)
+
+	var rating *data.JokeRating
+
+	for i != limit && cursor.Next(ctx) {
+		rating = new(data.JokeRating)
+
+		if err = cursor.Decode(rating); err != nil {
+			return ratings, nil, err
+		}
+
+		ratings = append(ratings, rating)
+		i++
+	}
+
+	var nextID *string
+
+	if cursor.Next(ctx) && rating != nil {
+		rating := new(data.JokeRating)
+
+		if err = cursor.Decode(rating); err != nil {
+			return ratings, nil, err
+		}
+
+		nextID = &rating.ID
+	}
+
+	return ratings, nextID, nil
 }
 
 func (jr *JokeCRUD) FetchOne(ctx context.Context, id string) (*data.Joke, error) {
-	result := jr.c.FindOne(ctx, bson.M{"id": id})
+	// Ratings are fetched by page with FetchRatings.
+	result := jr.c.FindOne(ctx, bson.M{"id": id, "deleted_at": nil}, options.FindOne().SetProjection(bson.M{"ratings": 0}))
 
 	if result.Err() != nil {
 		if result.Err() == mongo.ErrNoDocuments {
@@ -124,6 +222,115 @@ func (jr *JokeCRUD) FetchOne(ctx context.Context, id string) (*data.Joke, error)
 	return joke, nil
 }
 
+// FetchFirstRatings fetches the first ratings of each of the jokes, up to
+// limit, oldest first.
+func (jr *JokeCRUD) FetchFirstRatings(ctx context.Context, jokeIDs []string, limit uint64) (map[string]data.JokeRatings, error) {
+	ratings := make(map[string]data.JokeRatings, len(jokeIDs))
+
+	if len(jokeIDs) == 0 || limit == 0 {
+		return ratings, nil
+	}
+
+	cursor, err := jr.c.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{"id": bson.M{"$in": jokeIDs}, "deleted_at": nil}},
+		bson.M{"$unwind": "$ratings"},
+		bson.M{"$sort": bson.D{{Key: "ratings.rated_at", Value: 1}, {Key: "ratings.id", Value: 1}}},
+		bson.M{"$group": bson.M{"_id": "$id", "ratings": bson.M{"$push": "$ratings"}}},
+		bson.M{"$project": bson.M{"ratings": bson.M{"$slice": bson.A{"$ratings", limit}}}},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var group struct {
+			JokeID  string           `bson:"_id"`
+			Ratings data.JokeRatings `bson:"ratings"`
+		}
+
+		if err = cursor.Decode(&group); err != nil {
+			return nil, err
+		}
+
+		ratings[group.JokeID] = group.Ratings
+	}
+
+	return ratings, cursor.Err()
+}
+
+func (jr *JokeCRUD) FetchMany(ctx context.Context, ids []string) (data.Jokes, error) {
+	if len(ids) == 0 {
+		return data.Jokes{}, nil
+	}
+
+	cursor, err := jr.c.Find(ctx, bson.M{"id": bson.M{"$in": ids}, "deleted_at": nil, "hidden": bson.M{"$ne": true}},
+		options.Find().SetProjection(bson.M{"ratings": 0, "rating_histogram": 0}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	jokes := make(data.Jokes, 0, len(ids))
+
+	if err = cursor.All(ctx, &jokes); err != nil {
+		return nil, err
+	}
+
+	return jokes, nil
+}
+
+func (jr *JokeCRUD) FetchLanguages(ctx context.Context) ([]string, error) {
+	values, err := jr.c.Distinct(ctx, "language", bson.M{"status": data.JokeStatusApproved, "deleted_at": nil})
+
+	if err != nil {
+		return nil, err
+	}
+
+	languages := make([]string, 0, len(values))
+
+	for _, value := range values {
+		if language, ok := value.(string); ok {
+			languages = append(languages, language)
+		}
+	}
+
+	return languages, nil
+}
+
+func (jr *JokeCRUD) FetchRatingEvents(ctx context.Context, since time.Time) (data.JokeRatingEvents, error) {
+	cursor, err := jr.c.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{"status": data.JokeStatusApproved, "deleted_at": nil, "ratings.rated_at": bson.M{"$gte": since}}},
+		bson.M{"$unwind": "$ratings"},
+		bson.M{"$match": bson.M{"ratings.rated_at": bson.M{"$gte": since}}},
+		bson.M{"$project": bson.M{
+			"_id":      0,
+			"joke_id":  "$id",
+			"language": "$language",
+			"rating":   "$ratings.rating",
+			"rated_at": "$ratings.rated_at",
+		}},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	events := make(data.JokeRatingEvents, 0)
+
+	if err = cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
 func (jr *JokeCRUD) Insert(ctx context.Context, joke *data.Joke) (string, error) {
 	result, err := jr.c.InsertOne(ctx, joke)
 
@@ -136,53 +343,166 @@ func (jr *JokeCRUD) Insert(ctx context.Context, joke *data.Joke) (string, error)
 	return objectID.Hex(), nil
 }
 
-func (jr *JokeCRUD) DeleteRating(ctx context.Context, jokeID string, ratingID string, authID string) (string, error) {
-	return "", nil
+// InsertMany inserts the jokes in a single batch.
+func (jr *JokeCRUD) InsertMany(ctx context.Context, jokes data.Jokes) (int64, error) {
+	if len(jokes) == 0 {
+		return 0, nil
+	}
+
+	documents := make([]interface{}, len(jokes))
+
+	for i, joke := range jokes {
+		documents[i] = joke
+	}
+
+	result, err := jr.c.InsertMany(ctx, documents)
+
+	if err != nil {
+		// Ordered inserts stop at the first failing joke, after inserting
+		// the ones before it.
+		if bwe, ok := err.(mongo.BulkWriteException); ok && len(bwe.WriteErrors) > 0 {
+			return int64(bwe.WriteErrors[0].Index), err
+		}
+
+		return 0, err
+	}
+
+	return int64(len(result.InsertedIDs)), nil
 }
 
-func (jr *JokeCRUD) RateJoke(ctx context.Context, jokeID string, jokeRating *data.JokeRating) (string, error) {
-	session, err := jr.c.Database().Client().StartSession()
+func (jr *JokeCRUD) DeleteRating(ctx context.Context, jokeID string, ratingID string, authID string, admin bool) (string, error) {
+	_, err := transaction(ctx, jr.c.Database().Client(), func(sc mongo.SessionContext) (interface{}, error) {
+		joke := new(data.Joke)
+
+		err := jr.c.FindOne(sc, bson.M{"id": jokeID, "deleted_at": nil, "ratings.id": ratingID}, options.FindOne().SetProjection(bson.M{
+			"ratings": bson.M{"$elemMatch": bson.M{"id": ratingID}},
+		})).Decode(joke)
+
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, repositories.ErrUnknownID
+			}
+
+			return nil, err
+		}
+
+		rating := joke.Ratings[0]
+
+		if !admin && (rating.UserID == nil || *rating.UserID != authID) {
+			return nil, repositories.ErrNotOwner
+		}
+
+		return nil, jr.updateRatingAggregates(sc, bson.M{"id": jokeID, "ratings.id": ratingID}, bson.M{
+			"$pull": bson.M{"ratings": bson.M{"id": ratingID}},
+			"$inc": bson.M{
+				"rating_count": -1,
+				"rating_sum":   -rating.Rating,
+				"rating_histogram." + data.RatingBucket(rating.Rating): -1,
+			},
+		})
+	})
 
 	if err != nil {
-		return "", err
+		return ratingID, err
 	}
 
-	defer session.EndSession(ctx)
+	return ratingID, nil
+}
 
-	if err = session.StartTransaction(); err != nil {
-		return "", err
+func (jr *JokeCRUD) RateJoke(ctx context.Context, jokeID string, jokeRating *data.JokeRating) (string, error) {
+	if jokeRating.UserID == nil {
+		return "", repositories.ErrNoUser
 	}
 
-	result := jr.c.FindOne(ctx, bson.M{"id": jokeID})
+	_, err := transaction(ctx, jr.c.Database().Client(), func(sc mongo.SessionContext) (interface{}, error) {
+		joke := new(data.Joke)
 
-	err = result.Err()
+		err := jr.c.FindOne(sc, bson.M{"id": jokeID, "deleted_at": nil}, options.FindOne().SetProjection(bson.M{
+			"ratings": bson.M{"$elemMatch": bson.M{"user_id": *jokeRating.UserID}},
+		})).Decode(joke)
 
-	if err != nil {
-		session.AbortTransaction(ctx)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, repositories.ErrUnknownID
+			}
 
-		if err == mongo.ErrNoDocuments {
-			return "", repositories.ErrUnknownID
+			return nil, err
+		}
+
+		if len(joke.Ratings) > 0 {
+			previous := joke.Ratings[0]
+			jokeRating.ID = previous.ID
+
+			inc := bson.M{"rating_sum": jokeRating.Rating - previous.Rating}
+
+			if oldBucket, newBucket := data.RatingBucket(previous.Rating), data.RatingBucket(jokeRating.Rating); oldBucket != newBucket {
+				inc["rating_histogram."+oldBucket] = -1
+				inc["rating_histogram."+newBucket] = 1
+			}
+
+			return nil, jr.updateRatingAggregates(sc, bson.M{"id": jokeID, "ratings.id": jokeRating.ID}, bson.M{
+				"$set": bson.M{"ratings.$.rating": jokeRating.Rating, "ratings.$.rated_at": jokeRating.RatedAt},
+				"$inc": inc,
+			})
+		}
+
+		// Embedded arrays can't carry a per-document unique index, so the
+		// filter guarantees at most one rating per user instead.
+		err = jr.updateRatingAggregates(sc, bson.M{"id": jokeID, "ratings.user_id": bson.M{"$ne": *jokeRating.UserID}}, bson.M{
+			"$push": bson.M{"ratings": jokeRating},
+			"$inc": bson.M{
+				"rating_count": 1,
+				"rating_sum":   jokeRating.Rating,
+				"rating_histogram." + data.RatingBucket(jokeRating.Rating): 1,
+			},
+		})
+
+		if err == repositories.ErrUnknownID {
+			return nil, repositories.ErrDuplicateRating
 		}
 
+		return nil, err
+	})
+
+	if err != nil {
 		return "", err
 	}
 
-	_, err = jr.c.UpdateOne(ctx, bson.M{"id": jokeID}, bson.M{"$push": bson.M{
-		"ratings": jokeRating,
-	}})
+	return jokeRating.ID, nil
+}
+
+// updateRatingAggregates applies update to the joke matching filter and
+// recomputes its score from the updated rating count and sum.
+func (jr *JokeCRUD) updateRatingAggregates(sc mongo.SessionContext, filter bson.M, update bson.M) error {
+	joke := new(data.Joke)
+
+	err := jr.c.FindOneAndUpdate(sc, filter, update, options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"id": 1, "rating_count": 1, "rating_sum": 1})).Decode(joke)
 
 	if err != nil {
-		session.AbortTransaction(ctx)
-		return "", err
+		if err == mongo.ErrNoDocuments {
+			return repositories.ErrUnknownID
+		}
+
+		return err
 	}
 
-	session.CommitTransaction(ctx)
+	joke.UpdateScore()
 
-	return jokeRating.ID, nil
+	_, err = jr.c.UpdateOne(sc, bson.M{"id": joke.ID}, bson.M{"$set": bson.M{
+		"score":     joke.Score,
+		"avgRating": joke.AvgRating,
+	}})
+
+	return err
 }
 
-func (jr *JokeCRUD) Update(ctx context.Context, id string, joke *data.Joke) (string, error) {
-	result, err := jr.c.ReplaceOne(ctx, bson.M{"id": id}, joke)
+func (jr *JokeCRUD) Moderate(ctx context.Context, id string, moderation *data.JokeModeration) (string, error) {
+	result, err := jr.c.UpdateOne(ctx, bson.M{"id": id, "deleted_at": nil}, bson.M{"$set": bson.M{
+		"status":           moderation.Status,
+		"rejection_reason": moderation.Reason,
+	}})
 
 	if err != nil {
 		return "", err
@@ -195,16 +515,744 @@ func (jr *JokeCRUD) Update(ctx context.Context, id string, joke *data.Joke) (str
 	return id, nil
 }
 
-func (jr *JokeCRUD) Delete(ctx context.Context, id string) (string, error) {
-	result, err := jr.c.DeleteOne(ctx, bson.M{"id": id})
+func (jr *JokeCRUD) Update(ctx context.Context, id string, joke *data.Joke, editorID string) (string, error) {
+	return jr.Patch(ctx, id, joke, repositories.JokePatchFields, editorID)
+}
+
+// Patch sets only the given JSON fields of the joke, recording a revision if
+// they changed its content.
+func (jr *JokeCRUD) Patch(ctx context.Context, id string, joke *data.Joke, fields []string, editorID string) (string, error) {
+	_, err := transaction(ctx, jr.c.Database().Client(), func(sc mongo.SessionContext) (interface{}, error) {
+		current := new(data.Joke)
+
+		if err := jr.c.FindOne(sc, bson.M{"id": id, "deleted_at": nil}).Decode(current); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, repositories.ErrUnknownID
+			}
+
+			return nil, err
+		}
+
+		values := map[string]bson.E{
+			"author_id":   {Key: "author_id", Value: joke.AuthorID},
+			"type":        {Key: "type", Value: joke.Type},
+			"text":        {Key: "text", Value: joke.Text},
+			"setup":       {Key: "setup", Value: joke.Setup},
+			"delivery":    {Key: "delivery", Value: joke.Delivery},
+			"explanation": {Key: "explanation", Value: joke.Explanation},
+			"lang":        {Key: "language", Value: joke.Language},
+			"flags":       {Key: "flags", Value: joke.Flags},
+		}
+
+		set := bson.M{}
+
+		for _, field := range fields {
+			if value, ok := values[field]; ok {
+				set[value.Key] = value.Value
+			}
+		}
+
+		update := bson.M{}
+
+		if len(set) > 0 {
+			update["$set"] = set
+		}
+
+		if joke.Revision != current.Revision {
+			return nil, repositories.ErrStaleRevision
+		}
+
+		if changes := current.Diff(joke); len(changes) > 0 {
+			revision := &data.JokeRevision{
+				JokeID:    id,
+				Revision:  current.Revision + 1,
+				EditorID:  &editorID,
+				CreatedAt: time.Now().UTC(),
+				Changes:   changes,
+			}
+
+			if err := revision.GenerateID(); err != nil {
+				return nil, err
+			}
+
+			if _, err := jr.revisions.InsertOne(sc, revision); err != nil {
+				return nil, err
+			}
+
+			update["$inc"] = bson.M{"revision": 1}
+			joke.Revision = revision.Revision
+		}
+
+		if len(update) == 0 {
+			return nil, nil
+		}
+
+		res, err := jr.c.UpdateOne(sc, bson.M{"id": id, "revision": current.Revision}, update)
+
+		if err == nil && res.MatchedCount == 0 {
+			err = repositories.ErrStaleRevision
+		}
+
+		return nil, err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (jr *JokeCRUD) FetchRevisions(ctx context.Context, jokeID string) (data.JokeRevisions, error) {
+	cursor, err := jr.revisions.Find(ctx, bson.M{"joke_id": jokeID}, options.Find().SetSort(bson.M{"revision": 1}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	revisions := make(data.JokeRevisions, 0)
+
+	if err = cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (jr *JokeCRUD) Delete(ctx context.Context, id string, revision uint64, deletedBy string) (string, error) {
+	result, err := jr.c.UpdateOne(ctx, bson.M{"id": id, "revision": revision, "deleted_at": nil}, bson.M{"$set": bson.M{
+		"deleted_at": time.Now().UTC(),
+		"deleted_by": deletedBy,
+	}})
 
 	if err != nil {
 		return "", err
 	}
 
-	if result.DeletedCount == 0 {
+	if result.MatchedCount == 0 {
+		count, err := jr.c.CountDocuments(ctx, bson.M{"id": id, "deleted_at": nil})
+
+		if err != nil {
+			return "", err
+		}
+
+		if count > 0 {
+			return id, repositories.ErrStaleRevision
+		}
+
 		return id, repositories.ErrUnknownID
 	}
 
 	return id, nil
 }
+
+func (jr *JokeCRUD) FetchTrash(ctx context.Context, limit uint64, offset string, direction repositories.FetchDirection) (data.Jokes, *string, error) {
+	var jokes data.Jokes
+
+	condition, options := paginate(offset, "id", limit+1, direction)
+	condition["deleted_at"] = bson.M{"$ne": nil}
+
+	cursor, err := jr.c.Find(ctx, condition, options.SetProjection(bson.M{"ratings": 0}))
+
+	if err != nil {
+		return jokes, nil, repositories.ErrInvalidOffset
+	}
+
+	defer cursor.Close(ctx)
+
+	i := uint64(0)
+
+	jokes = make(data.Jokes, 0, limit)
+
+	var joke *data.Joke
+
+	for i != limit && cursor.Next(ctx) {
+		joke = new(data.Joke)
+
+		if err = cursor.Decode(joke); err != nil {
+			return jokes, nil, err
+		}
+
+		jokes = append(jokes, joke)
+		i++
+	}
+
+	var nextID *string
+
+	if cursor.Next(ctx) && joke != nil {
+		joke := new(data.Joke)
+
+		if err = cursor.Decode(joke); err != nil {
+			return jokes, nil, err
+		}
+
+		nextID = &joke.ID
+	}
+
+	return jokes, nextID, nil
+}
+
+func (jr *JokeCRUD) Restore(ctx context.Context, id string) (string, error) {
+	result, err := jr.c.UpdateOne(ctx, bson.M{"id": id, "deleted_at": bson.M{"$ne": nil}}, bson.M{"$unset": bson.M{
+		"deleted_at": "",
+		"deleted_by": "",
+	}})
+
+	if err != nil {
+		return "", err
+	}
+
+	if result.MatchedCount == 0 {
+		return id, repositories.ErrUnknownID
+	}
+
+	return id, nil
+}
+
+func (jr *JokeCRUD) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	condition := bson.M{"deleted_at": bson.M{"$lt": deletedBefore}}
+
+	ids, err := jr.c.Distinct(ctx, "id", condition)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result, err := jr.c.DeleteMany(ctx, condition)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = jr.revisions.DeleteMany(ctx, bson.M{"joke_id": bson.M{"$in": ids}}); err != nil {
+		return result.DeletedCount, err
+	}
+
+	if _, err = jr.favorites.DeleteMany(ctx, bson.M{"joke_id": bson.M{"$in": ids}}); err != nil {
+		return result.DeletedCount, err
+	}
+
+	if _, err = jr.comments.DeleteMany(ctx, bson.M{"joke_id": bson.M{"$in": ids}}); err != nil {
+		return result.DeletedCount, err
+	}
+
+	if _, err = jr.reports.DeleteMany(ctx, bson.M{"joke_id": bson.M{"$in": ids}}); err != nil {
+		return result.DeletedCount, err
+	}
+
+	if _, err = jr.reactions.DeleteMany(ctx, bson.M{"joke_id": bson.M{"$in": ids}}); err != nil {
+		return result.DeletedCount, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+func (jr *JokeCRUD) AddFavorite(ctx context.Context, favorite *data.JokeFavorite) (string, error) {
+	_, err := transaction(ctx, jr.c.Database().Client(), func(sc mongo.SessionContext) (interface{}, error) {
+		result, err := jr.c.UpdateOne(sc, bson.M{"id": favorite.JokeID, "deleted_at": nil}, bson.M{
+			"$inc": bson.M{"favorite_count": 1},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if result.MatchedCount == 0 {
+			return nil, repositories.ErrUnknownID
+		}
+
+		if _, err = jr.favorites.InsertOne(sc, favorite); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return nil, repositories.ErrDuplicateFavorite
+			}
+
+			return nil, err
+		}
+
+		return nil, nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return favorite.ID, nil
+}
+
+func (jr *JokeCRUD) RemoveFavorite(ctx context.Context, jokeID string, userID string) (string, error) {
+	_, err := transaction(ctx, jr.c.Database().Client(), func(sc mongo.SessionContext) (interface{}, error) {
+		result, err := jr.favorites.DeleteOne(sc, bson.M{"joke_id": jokeID, "user_id": userID})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if result.DeletedCount == 0 {
+			return nil, repositories.ErrUnknownID
+		}
+
+		_, err = jr.c.UpdateOne(sc, bson.M{"id": jokeID}, bson.M{"$inc": bson.M{"favorite_count": -1}})
+
+		return nil, err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return jokeID, nil
+}
+
+func (jr *JokeCRUD) FetchFavorites(ctx context.Context, userID string, limit uint64, offset string, direction repositories.FetchDirection) (data.Jokes, *string, error) {
+	condition, options, err := paginateByTime(offset, "created_at", limit+1, direction)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	condition["user_id"] = userID
+
+	cursor, err := jr.favorites.Find(ctx, condition, options)
+
+	if err != nil {
+		return nil, nil, repositories.ErrInvalidOffset
+	}
+
+	defer cursor.Close(ctx)
+
+	favorites := make([]*data.JokeFavorite, 0, limit+1)
+
+	if err = cursor.All(ctx, &favorites); err != nil {
+		return nil, nil, err
+	}
+
+	var nextID *string
+
+	if uint64(len(favorites)) > limit {
+		next := repositories.EncodeTimeCursor(favorites[limit].CreatedAt, favorites[limit].ID)
+		nextID = &next
+		favorites = favorites[:limit]
+	}
+
+	ids := make([]string, 0, len(favorites))
+
+	for _, favorite := range favorites {
+		ids = append(ids, favorite.JokeID)
+	}
+
+	jokes, err := jr.FetchMany(ctx, ids)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return jokes.InOrder(ids), nextID, nil
+}
+
+func (jr *JokeCRUD) FetchComments(ctx context.Context, jokeID string, limit uint64, offset string, direction repositories.FetchDirection) (data.JokeComments, *string, error) {
+	condition, options, err := paginateByTime(offset, "created_at", limit+1, direction)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	condition["joke_id"] = jokeID
+	condition["parent_id"] = nil
+
+	cursor, err := jr.comments.Find(ctx, condition, options)
+
+	if err != nil {
+		return nil, nil, repositories.ErrInvalidOffset
+	}
+
+	defer cursor.Close(ctx)
+
+	comments := make(data.JokeComments, 0, limit+1)
+
+	if err = cursor.All(ctx, &comments); err != nil {
+		return nil, nil, err
+	}
+
+	var nextID *string
+
+	if uint64(len(comments)) > limit {
+		next := repositories.EncodeTimeCursor(comments[limit].CreatedAt, comments[limit].ID)
+		nextID = &next
+		comments = comments[:limit]
+	}
+
+	if len(comments) == 0 {
+		return comments, nextID, nil
+	}
+
+	parentIDs := make([]string, 0, len(comments))
+
+	for _, comment := range comments {
+		parentIDs = append(parentIDs, comment.ID)
+	}
+
+	replyCursor, err := jr.comments.Find(ctx, bson.M{"joke_id": jokeID, "parent_id": bson.M{"$in": parentIDs}})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer replyCursor.Close(ctx)
+
+	replies := make(data.JokeComments, 0)
+
+	if err = replyCursor.All(ctx, &replies); err != nil {
+		return nil, nil, err
+	}
+
+	comments.Thread(replies)
+
+	return comments, nextID, nil
+}
+
+func (jr *JokeCRUD) FetchComment(ctx context.Context, jokeID string, commentID string) (*data.JokeComment, error) {
+	comment := new(data.JokeComment)
+
+	if err := jr.comments.FindOne(ctx, bson.M{"id": commentID, "joke_id": jokeID}).Decode(comment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, repositories.ErrUnknownID
+		}
+
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+func (jr *JokeCRUD) InsertComment(ctx context.Context, comment *data.JokeComment) (string, error) {
+	_, err := transaction(ctx, jr.c.Database().Client(), func(sc mongo.SessionContext) (interface{}, error) {
+		if comment.ParentID != nil {
+			// Replies can't be replied to, which keeps threads one level deep.
+			err := jr.comments.FindOne(sc, bson.M{
+				"id":         *comment.ParentID,
+				"joke_id":    comment.JokeID,
+				"parent_id":  nil,
+				"removed_at": nil,
+			}).Err()
+
+			if err == mongo.ErrNoDocuments {
+				return nil, repositories.ErrInvalidParent
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		result, err := jr.c.UpdateOne(sc, bson.M{"id": comment.JokeID, "deleted_at": nil}, bson.M{
+			"$inc": bson.M{"comment_count": 1},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if result.MatchedCount == 0 {
+			return nil, repositories.ErrUnknownID
+		}
+
+		_, err = jr.comments.InsertOne(sc, comment)
+
+		return nil, err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return comment.ID, nil
+}
+
+func (jr *JokeCRUD) UpdateComment(ctx context.Context, comment *data.JokeComment) (string, error) {
+	result, err := jr.comments.UpdateOne(ctx, bson.M{"id": comment.ID, "joke_id": comment.JokeID, "removed_at": nil}, bson.M{
+		"$set": bson.M{"text": comment.Text, "updated_at": comment.UpdatedAt},
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if result.MatchedCount == 0 {
+		return comment.ID, repositories.ErrUnknownID
+	}
+
+	return comment.ID, nil
+}
+
+// DeleteComment deletes a comment, or blanks it out like RemoveComment if it
+// has replies so that they keep their context.
+func (jr *JokeCRUD) DeleteComment(ctx context.Context, jokeID string, commentID string) (string, error) {
+	_, err := transaction(ctx, jr.c.Database().Client(), func(sc mongo.SessionContext) (interface{}, error) {
+		comment := new(data.JokeComment)
+
+		if err := jr.comments.FindOne(sc, bson.M{"id": commentID, "joke_id": jokeID}).Decode(comment); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, repositories.ErrUnknownID
+			}
+
+			return nil, err
+		}
+
+		replies, err := jr.comments.CountDocuments(sc, bson.M{"joke_id": jokeID, "parent_id": commentID})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if replies == 0 {
+			_, err = jr.comments.DeleteOne(sc, bson.M{"id": commentID})
+		} else if comment.RemovedAt == nil {
+			_, err = jr.comments.UpdateOne(sc, bson.M{"id": commentID}, bson.M{
+				"$set": bson.M{"text": "", "removed_at": time.Now().UTC(), "removed_by": comment.AuthorID},
+			})
+		}
+
+		// Removed comments were already discounted from the joke.
+		if err != nil || comment.RemovedAt != nil {
+			return nil, err
+		}
+
+		_, err = jr.c.UpdateOne(sc, bson.M{"id": jokeID}, bson.M{"$inc": bson.M{"comment_count": -1}})
+
+		return nil, err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return commentID, nil
+}
+
+func (jr *JokeCRUD) RemoveComment(ctx context.Context, jokeID string, commentID string, removedBy string) (string, error) {
+	_, err := transaction(ctx, jr.c.Database().Client(), func(sc mongo.SessionContext) (interface{}, error) {
+		result, err := jr.comments.UpdateOne(sc, bson.M{"id": commentID, "joke_id": jokeID, "removed_at": nil}, bson.M{
+			"$set": bson.M{"text": "", "removed_at": time.Now().UTC(), "removed_by": removedBy},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if result.MatchedCount == 0 {
+			return nil, repositories.ErrUnknownID
+		}
+
+		_, err = jr.c.UpdateOne(sc, bson.M{"id": jokeID}, bson.M{"$inc": bson.M{"comment_count": -1}})
+
+		return nil, err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return commentID, nil
+}
+
+func (jr *JokeCRUD) InsertReport(ctx context.Context, report *data.Report, hideThreshold uint64) (string, error) {
+	_, err := transaction(ctx, jr.c.Database().Client(), func(sc mongo.SessionContext) (interface{}, error) {
+		err := jr.c.FindOne(sc, bson.M{"id": report.JokeID, "deleted_at": nil}).Err()
+
+		if err == nil && report.CommentID != nil {
+			err = jr.comments.FindOne(sc, bson.M{"id": *report.CommentID, "joke_id": report.JokeID, "removed_at": nil}).Err()
+		}
+
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, repositories.ErrUnknownID
+			}
+
+			return nil, err
+		}
+
+		err = jr.reports.FindOne(sc, bson.M{
+			"joke_id":     report.JokeID,
+			"comment_id":  report.CommentID,
+			"reporter_id": report.ReporterID,
+			"status":      data.ReportStatusOpen,
+		}).Err()
+
+		if err == nil {
+			return nil, repositories.ErrDuplicateReport
+		}
+
+		if err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+
+		if _, err = jr.reports.InsertOne(sc, report); err != nil {
+			return nil, err
+		}
+
+		if report.TargetType != data.ReportTargetJoke {
+			return nil, nil
+		}
+
+		joke := new(data.Joke)
+
+		err = jr.c.FindOneAndUpdate(sc, bson.M{"id": report.JokeID}, bson.M{"$inc": bson.M{"report_count": 1}},
+			options.FindOneAndUpdate().
+				SetReturnDocument(options.After).
+				SetProjection(bson.M{"report_count": 1})).Decode(joke)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if hideThreshold > 0 && joke.ReportCount >= hideThreshold {
+			_, err = jr.c.UpdateOne(sc, bson.M{"id": report.JokeID}, bson.M{"$set": bson.M{"hidden": true}})
+		}
+
+		return nil, err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return report.ID, nil
+}
+
+func (jr *JokeCRUD) FetchReports(ctx context.Context, status string, limit uint64, offset string, direction repositories.FetchDirection) (data.Reports, *string, error) {
+	condition, options := paginate(offset, "id", limit+1, direction)
+
+	if status != "" {
+		condition["status"] = status
+	}
+
+	cursor, err := jr.reports.Find(ctx, condition, options)
+
+	if err != nil {
+		return nil, nil, repositories.ErrInvalidOffset
+	}
+
+	defer cursor.Close(ctx)
+
+	reports := make(data.Reports, 0, limit+1)
+
+	if err = cursor.All(ctx, &reports); err != nil {
+		return nil, nil, err
+	}
+
+	var nextID *string
+
+	if uint64(len(reports)) > limit {
+		nextID = &reports[limit].ID
+		reports = reports[:limit]
+	}
+
+	return reports, nextID, nil
+}
+
+func (jr *JokeCRUD) FetchReport(ctx context.Context, id string) (*data.Report, error) {
+	report := new(data.Report)
+
+	if err := jr.reports.FindOne(ctx, bson.M{"id": id}).Decode(report); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, repositories.ErrUnknownID
+		}
+
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (jr *JokeCRUD) ResolveReports(ctx context.Context, report *data.Report, resolution *data.ReportResolution, resolvedBy string) (int64, error) {
+	resolved, err := transaction(ctx, jr.c.Database().Client(), func(sc mongo.SessionContext) (interface{}, error) {
+		result, err := jr.reports.UpdateMany(sc, bson.M{
+			"joke_id":    report.JokeID,
+			"comment_id": report.CommentID,
+			"status":     data.ReportStatusOpen,
+		}, bson.M{"$set": bson.M{
+			"status":          resolution.Status,
+			"resolved_at":     time.Now().UTC(),
+			"resolved_by":     resolvedBy,
+			"resolution_note": resolution.Note,
+		}})
+
+		if err != nil {
+			return int64(0), err
+		}
+
+		if report.TargetType == data.ReportTargetJoke {
+			_, err = jr.c.UpdateOne(sc, bson.M{"id": report.JokeID}, bson.M{"$set": bson.M{"report_count": 0, "hidden": false}})
+		}
+
+		return result.ModifiedCount, err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return resolved.(int64), nil
+}
+
+func (jr *JokeCRUD) AddReaction(ctx context.Context, reaction *data.JokeReaction) (string, error) {
+	_, err := transaction(ctx, jr.c.Database().Client(), func(sc mongo.SessionContext) (interface{}, error) {
+		result, err := jr.c.UpdateOne(sc, bson.M{"id": reaction.JokeID, "deleted_at": nil}, bson.M{
+			"$inc": bson.M{"reactions." + reaction.Kind: 1},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if result.MatchedCount == 0 {
+			return nil, repositories.ErrUnknownID
+		}
+
+		if _, err = jr.reactions.InsertOne(sc, reaction); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return nil, repositories.ErrDuplicateReaction
+			}
+
+			return nil, err
+		}
+
+		return nil, nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return reaction.Kind, nil
+}
+
+func (jr *JokeCRUD) RemoveReaction(ctx context.Context, jokeID string, userID string, kind string) (string, error) {
+	_, err := transaction(ctx, jr.c.Database().Client(), func(sc mongo.SessionContext) (interface{}, error) {
+		result, err := jr.reactions.DeleteOne(sc, bson.M{"joke_id": jokeID, "user_id": userID, "kind": kind})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if result.DeletedCount == 0 {
+			return nil, repositories.ErrUnknownID
+		}
+
+		_, err = jr.c.UpdateOne(sc, bson.M{"id": jokeID}, bson.M{"$inc": bson.M{"reactions." + kind: -1}})
+
+		return nil, err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return kind, nil
+}
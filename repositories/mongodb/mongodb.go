@@ -1,11 +1,49 @@
 package mongodb
 
 import (
+	"context"
+
 	"github.com/davq23/jokeapi/repositories"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Transactor runs repository calls in a single transaction.
+type Transactor struct {
+	client *mongo.Client
+}
+
+func NewTransactor(client *mongo.Client) *Transactor {
+	return &Transactor{client}
+}
+
+func (t *Transactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	_, err := transaction(ctx, t.client, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+
+	return err
+}
+
+// transaction runs fn in a new transaction, or in the one ctx is already in
+// so that it commits or aborts along with the rest of it.
+func transaction(ctx context.Context, client *mongo.Client, fn func(sc mongo.SessionContext) (interface{}, error)) (interface{}, error) {
+	if session := mongo.SessionFromContext(ctx); session != nil {
+		return fn(mongo.NewSessionContext(ctx, session))
+	}
+
+	session, err := client.StartSession()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer session.EndSession(ctx)
+
+	return session.WithTransaction(ctx, fn)
+}
+
 func paginate(offset interface{}, cursorName string, limit uint64, direction repositories.FetchDirection) (bson.M, *options.FindOptions) {
 	options := options.Find()
 
@@ -23,3 +61,67 @@ func paginate(offset interface{}, cursorName string, limit uint64, direction rep
 
 	return bson.M{cursorName: bson.M{filterCondition: offset}}, options
 }
+
+func paginateSorted(offset string, sortField string, limit uint64, direction repositories.FetchDirection) (bson.M, *options.FindOptions, error) {
+	options := options.Find()
+
+	options.SetSort(bson.D{{Key: sortField, Value: -direction}, {Key: "id", Value: direction}})
+	options.SetLimit(int64(limit))
+
+	if offset == "" {
+		return bson.M{}, options, nil
+	}
+
+	value, id, err := repositories.DecodeSortCursor(offset)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var valueCondition, idCondition string
+
+	switch direction {
+	case repositories.FetchNext:
+		valueCondition, idCondition = "$lt", "$gte"
+	case repositories.FetchBack:
+		valueCondition, idCondition = "$gt", "$lt"
+	}
+
+	return bson.M{"$or": bson.A{
+		bson.M{sortField: bson.M{valueCondition: value}},
+		bson.M{sortField: value, "id": bson.M{idCondition: id}},
+	}}, options, nil
+}
+
+// paginateByTime pages through documents oldest first by timeField, with the
+// ID as a tie-breaker, from an offset made by repositories.EncodeTimeCursor.
+func paginateByTime(offset string, timeField string, limit uint64, direction repositories.FetchDirection) (bson.M, *options.FindOptions, error) {
+	options := options.Find()
+
+	options.SetSort(bson.D{{Key: timeField, Value: direction}, {Key: "id", Value: direction}})
+	options.SetLimit(int64(limit))
+
+	if offset == "" {
+		return bson.M{}, options, nil
+	}
+
+	t, id, err := repositories.DecodeTimeCursor(offset)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var timeCondition, idCondition string
+
+	switch direction {
+	case repositories.FetchNext:
+		timeCondition, idCondition = "$gt", "$gte"
+	case repositories.FetchBack:
+		timeCondition, idCondition = "$lt", "$lt"
+	}
+
+	return bson.M{"$or": bson.A{
+		bson.M{timeField: bson.M{timeCondition: t}},
+		bson.M{timeField: t, "id": bson.M{idCondition: id}},
+	}}, options, nil
+}
@@ -39,7 +39,7 @@ func TestMain(m *testing.M) {
 
 	db := client.Database("jokeapi")
 
-	_, _ = config.MongoDBMigration(context.Background(), db)
+	_, _, _ = config.MongoDBMigration(context.Background(), db)
 
 	exitVal := m.Run()
 
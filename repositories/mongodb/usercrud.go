@@ -2,6 +2,7 @@ package mongodb
 
 import (
 	"context"
+	"time"
 
 	"github.com/davq23/jokeapi/data"
 	"github.com/davq23/jokeapi/repositories"
@@ -30,6 +31,7 @@ func (jr *UserCRUD) FetchAll(ctx context.Context, limit uint64, offset string, d
 	var jokes data.Users
 
 	condition, options := paginate(offset, "id", limit+1, direction)
+	condition["deleted_at"] = nil
 
 	cursor, err := jr.c.Find(ctx, condition, options)
 
@@ -66,7 +68,7 @@ func (jr *UserCRUD) FetchAll(ctx context.Context, limit uint64, offset string, d
 }
 
 func (jr *UserCRUD) FetchOne(ctx context.Context, id string) (*data.User, error) {
-	result := jr.c.FindOne(ctx, bson.M{"id": id})
+	result := jr.c.FindOne(ctx, bson.M{"id": id, "deleted_at": nil})
 
 	if err := result.Err(); err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -85,8 +87,32 @@ func (jr *UserCRUD) FetchOne(ctx context.Context, id string) (*data.User, error)
 	return user, nil
 }
 
+// FetchMany fetches the users of the ids that aren't in the trash, in no
+// particular order.
+func (jr *UserCRUD) FetchMany(ctx context.Context, ids []string) (data.Users, error) {
+	users := make(data.Users, 0, len(ids))
+
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	cursor, err := jr.c.Find(ctx, bson.M{"id": bson.M{"$in": ids}, "deleted_at": nil})
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
 func (jr *UserCRUD) FetchOneByEmail(ctx context.Context, email string) (*data.User, error) {
-	result := jr.c.FindOne(ctx, bson.M{"email": email})
+	result := jr.c.FindOne(ctx, bson.M{"email": email, "deleted_at": nil})
 
 	if err := result.Err(); err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -118,7 +144,64 @@ func (jr *UserCRUD) Insert(ctx context.Context, user *data.User) (string, error)
 }
 
 func (jr *UserCRUD) Update(ctx context.Context, id string, user *data.User) (string, error) {
-	result, err := jr.c.ReplaceOne(ctx, bson.M{"id": id}, user)
+	result, err := jr.c.ReplaceOne(ctx, bson.M{"id": id, "deleted_at": nil}, user)
+
+	if err != nil {
+		return "", err
+	}
+
+	if result.MatchedCount == 0 {
+		return id, repositories.ErrUnknownID
+	}
+
+	return id, nil
+}
+
+// Patch sets only the given JSON fields of the user.
+func (jr *UserCRUD) Patch(ctx context.Context, id string, user *data.User, fields []string) (string, error) {
+	values := bson.M{"email": user.Email, "password": user.Password, "admin": user.Admin}
+	set := bson.M{}
+
+	for _, field := range fields {
+		if value, ok := values[field]; ok {
+			set[field] = value
+		}
+	}
+
+	filter := bson.M{"id": id, "deleted_at": nil}
+
+	if len(set) == 0 {
+		count, err := jr.c.CountDocuments(ctx, filter)
+
+		if err != nil {
+			return "", err
+		}
+
+		if count == 0 {
+			return id, repositories.ErrUnknownID
+		}
+
+		return id, nil
+	}
+
+	result, err := jr.c.UpdateOne(ctx, filter, bson.M{"$set": set})
+
+	if err != nil {
+		return "", err
+	}
+
+	if result.MatchedCount == 0 {
+		return id, repositories.ErrUnknownID
+	}
+
+	return id, nil
+}
+
+func (jr *UserCRUD) Delete(ctx context.Context, id string, deletedBy string) (string, error) {
+	result, err := jr.c.UpdateOne(ctx, bson.M{"id": id, "deleted_at": nil}, bson.M{"$set": bson.M{
+		"deleted_at": time.Now().UTC(),
+		"deleted_by": deletedBy,
+	}})
 
 	if err != nil {
 		return "", err
@@ -131,16 +214,75 @@ func (jr *UserCRUD) Update(ctx context.Context, id string, user *data.User) (str
 	return id, nil
 }
 
-func (jr *UserCRUD) Delete(ctx context.Context, id string) (string, error) {
-	result, err := jr.c.DeleteOne(ctx, bson.M{"id": id})
+func (jr *UserCRUD) FetchTrash(ctx context.Context, limit uint64, offset string, direction repositories.FetchDirection) (data.Users, *string, error) {
+	var users data.Users
+
+	condition, options := paginate(offset, "id", limit+1, direction)
+	condition["deleted_at"] = bson.M{"$ne": nil}
+
+	cursor, err := jr.c.Find(ctx, condition, options)
+
+	if err != nil {
+		return users, nil, repositories.ErrInvalidOffset
+	}
+
+	defer cursor.Close(ctx)
+
+	i := uint64(0)
+
+	users = make(data.Users, 0, limit)
+
+	var user *data.User
+
+	for i != limit && cursor.Next(ctx) {
+		user = new(data.User)
+
+		if err = cursor.Decode(user); err != nil {
+			return users, nil, err
+		}
+
+		users = append(users, user)
+		i++
+	}
+
+	var nextID *string
+
+	if cursor.Next(ctx) && user != nil {
+		user := new(data.User)
+
+		if err = cursor.Decode(user); err != nil {
+			return users, nil, err
+		}
+
+		nextID = &user.ID
+	}
+
+	return users, nextID, nil
+}
+
+func (jr *UserCRUD) Restore(ctx context.Context, id string) (string, error) {
+	result, err := jr.c.UpdateOne(ctx, bson.M{"id": id, "deleted_at": bson.M{"$ne": nil}}, bson.M{"$unset": bson.M{
+		"deleted_at": "",
+		"deleted_by": "",
+	}})
 
 	if err != nil {
 		return "", err
 	}
 
-	if result.DeletedCount == 0 {
+	if result.MatchedCount == 0 {
 		return id, repositories.ErrUnknownID
 	}
 
 	return id, nil
 }
+
+func (jr *UserCRUD) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := jr.c.DeleteMany(ctx, bson.M{"deleted_at": bson.M{"$lt": deletedBefore}})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
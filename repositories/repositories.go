@@ -1,6 +1,12 @@
 package repositories
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type FetchDirection int8
 
@@ -10,3 +16,65 @@ const FetchBack FetchDirection = -1
 var ErrInvalidOffset error = errors.New("invalid offset ID")
 var ErrUnknownID error = errors.New("unknown ID")
 var ErrUnknownEmail error = errors.New("unknown email")
+var ErrNoUser error = errors.New("no user")
+var ErrDuplicateRating error = errors.New("duplicate rating")
+var ErrNotOwner error = errors.New("not owner")
+var ErrDuplicateFavorite error = errors.New("duplicate favorite")
+var ErrInvalidParent error = errors.New("invalid parent comment")
+var ErrDuplicateReport error = errors.New("duplicate report")
+var ErrDuplicateReaction error = errors.New("duplicate reaction")
+
+// ErrStaleRevision is returned by edits made to a revision of a resource
+// that has since been edited by someone else.
+var ErrStaleRevision error = errors.New("stale revision")
+
+// Transactor runs fn in a transaction, which the repository calls made with
+// the context fn gets join. The transaction commits if fn returns nil and is
+// rolled back otherwise.
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// EncodeSortCursor builds the offset for listings sorted by a numeric value,
+// which need the joke ID as a tie-breaker.
+func EncodeSortCursor(value float64, id string) string {
+	return strconv.FormatFloat(value, 'g', -1, 64) + "," + id
+}
+
+func DecodeSortCursor(offset string) (float64, string, error) {
+	parts := strings.SplitN(offset, ",", 2)
+
+	if len(parts) != 2 {
+		return 0, "", ErrInvalidOffset
+	}
+
+	value, err := strconv.ParseFloat(parts[0], 64)
+
+	if err != nil {
+		return 0, "", ErrInvalidOffset
+	}
+
+	return value, parts[1], nil
+}
+
+// EncodeTimeCursor builds the offset for listings in the order of a time,
+// which need the ID as a tie-breaker.
+func EncodeTimeCursor(t time.Time, id string) string {
+	return t.UTC().Format(time.RFC3339Nano) + "," + id
+}
+
+func DecodeTimeCursor(offset string) (time.Time, string, error) {
+	parts := strings.SplitN(offset, ",", 2)
+
+	if len(parts) != 2 {
+		return time.Time{}, "", ErrInvalidOffset
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+
+	if err != nil {
+		return time.Time{}, "", ErrInvalidOffset
+	}
+
+	return t, parts[1], nil
+}
@@ -0,0 +1,245 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/davq23/jokeapi/data"
+	"github.com/davq23/jokeapi/repositories"
+	"github.com/jmoiron/sqlx"
+)
+
+const collectionColumns = "id, owner_id, name, description, visibility, slug, created_at, updated_at"
+
+func scanCollection(s scanner, collection *data.Collection) error {
+	return s.Scan(&collection.ID, &collection.OwnerID, &collection.Name, &collection.Description,
+		&collection.Visibility, &collection.Slug, &collection.CreatedAt, &collection.UpdatedAt)
+}
+
+type CollectionCRUD struct {
+	db *sqlx.DB
+}
+
+func NewCollectionCRUD(db *sqlx.DB) *CollectionCRUD {
+	return &CollectionCRUD{
+		db: db,
+	}
+}
+
+func (cr *CollectionCRUD) FetchAll(ctx context.Context, ownerID string, limit uint64, offset string, direction repositories.FetchDirection) (data.Collections, *string, error) {
+	var collections data.Collections
+
+	var condition string
+	var order string
+
+	if direction == repositories.FetchBack {
+		condition = "<"
+		order = "DESC"
+	} else {
+		condition = ">="
+		order = "ASC"
+	}
+
+	rows, err := conn(ctx, cr.db).QueryContext(ctx,
+		"SELECT "+collectionColumns+" FROM collections WHERE owner_id = ? AND id "+condition+" ? ORDER BY id "+order+" LIMIT ?",
+		ownerID, offset, limit+1)
+
+	if err != nil {
+		return collections, nil, err
+	}
+
+	defer rows.Close()
+
+	collections = make(data.Collections, 0, limit)
+
+	var nextID *string
+
+	for rows.Next() {
+		collection := new(data.Collection)
+
+		if err = scanCollection(rows, collection); err != nil {
+			return collections, nil, err
+		}
+
+		if uint64(len(collections)) == limit {
+			nextID = &collection.ID
+			break
+		}
+
+		collections = append(collections, collection)
+	}
+
+	for _, collection := range collections {
+		if collection.JokeIDs, err = cr.fetchJokeIDs(ctx, collection.ID); err != nil {
+			return collections, nil, err
+		}
+	}
+
+	return collections, nextID, nil
+}
+
+func (cr *CollectionCRUD) FetchOne(ctx context.Context, id string) (*data.Collection, error) {
+	return cr.fetchOne(ctx, "id", id)
+}
+
+func (cr *CollectionCRUD) FetchBySlug(ctx context.Context, slug string) (*data.Collection, error) {
+	return cr.fetchOne(ctx, "slug", slug)
+}
+
+func (cr *CollectionCRUD) fetchOne(ctx context.Context, column string, value string) (*data.Collection, error) {
+	row := conn(ctx, cr.db).QueryRowContext(ctx, "SELECT "+collectionColumns+" FROM collections WHERE "+column+" = ?", value)
+	collection := new(data.Collection)
+
+	if err := scanCollection(row, collection); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repositories.ErrUnknownID
+		}
+
+		return nil, err
+	}
+
+	var err error
+
+	collection.JokeIDs, err = cr.fetchJokeIDs(ctx, collection.ID)
+
+	return collection, err
+}
+
+func (cr *CollectionCRUD) fetchJokeIDs(ctx context.Context, id string) ([]string, error) {
+	rows, err := conn(ctx, cr.db).QueryContext(ctx, "SELECT joke_id FROM collection_jokes WHERE collection_id = ? ORDER BY position", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make([]string, 0)
+
+	for rows.Next() {
+		var jokeID string
+
+		if err = rows.Scan(&jokeID); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, jokeID)
+	}
+
+	return ids, rows.Err()
+}
+
+// replaceJokes rewrites the joke list of a collection so positions follow ids.
+func replaceJokes(ctx context.Context, tx *txn, id string, ids []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM collection_jokes WHERE collection_id = ?", id); err != nil {
+		return err
+	}
+
+	for position, jokeID := range ids {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO collection_jokes (collection_id, joke_id, position) VALUES (?, ?, ?)", id, jokeID, position)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cr *CollectionCRUD) Insert(ctx context.Context, collection *data.Collection) (string, error) {
+	tx, err := beginTx(ctx, cr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO collections ("+collectionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		collection.ID, collection.OwnerID, collection.Name, collection.Description,
+		collection.Visibility, collection.Slug, collection.CreatedAt, collection.UpdatedAt)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if err = replaceJokes(ctx, tx, collection.ID, collection.JokeIDs); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	tx.Commit()
+
+	return collection.ID, nil
+}
+
+func (cr *CollectionCRUD) Update(ctx context.Context, id string, collection *data.Collection) (string, error) {
+	tx, err := beginTx(ctx, cr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	row := tx.QueryRowContext(ctx, "SELECT id FROM collections WHERE id = ? FOR UPDATE", id)
+
+	if err = row.Scan(&id); err != nil {
+		tx.Rollback()
+
+		if err == sql.ErrNoRows {
+			return "", repositories.ErrUnknownID
+		}
+
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE collections SET name = ?, description = ?, visibility = ?, updated_at = ? WHERE id = ?",
+		collection.Name, collection.Description, collection.Visibility, collection.UpdatedAt, id)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if err = replaceJokes(ctx, tx, id, collection.JokeIDs); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	tx.Commit()
+
+	return id, nil
+}
+
+func (cr *CollectionCRUD) Delete(ctx context.Context, id string) (string, error) {
+	tx, err := beginTx(ctx, cr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM collection_jokes WHERE collection_id = ?", id); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM collections WHERE id = ?", id)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return "", err
+	} else if affected == 0 {
+		tx.Rollback()
+		return id, repositories.ErrUnknownID
+	}
+
+	tx.Commit()
+
+	return id, nil
+}
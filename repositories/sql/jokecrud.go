@@ -3,12 +3,105 @@ package sql
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
 
 	"github.com/davq23/jokeapi/data"
 	"github.com/davq23/jokeapi/repositories"
 	"github.com/jmoiron/sqlx"
 )
 
+const jokeColumns = "id, author_id, type, text, setup, delivery, explanation, lang, " +
+	"flag_nsfw, flag_religious, flag_political, flag_racist, flag_sexist, flag_explicit, status, rejection_reason, revision, deleted_at, deleted_by, " +
+	"rating_count, rating_sum, avg_rating, score, favorite_count, comment_count, report_count, hidden, " +
+	"reaction_laugh, reaction_groan, reaction_facepalm, reaction_clap, reaction_confused"
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanJoke scans the jokeColumns of a joke, followed by whatever columns
+// extra is for.
+func scanJoke(s scanner, joke *data.Joke, extra ...interface{}) error {
+	// The reaction_* columns follow the order of data.JokeReactionKinds.
+	reactions := make([]uint64, len(data.JokeReactionKinds))
+
+	dest := []interface{}{&joke.ID, &joke.AuthorID, &joke.Type, &joke.Text, &joke.Setup, &joke.Delivery, &joke.Explanation, &joke.Language,
+		&joke.Flags.NSFW, &joke.Flags.Religious, &joke.Flags.Political, &joke.Flags.Racist, &joke.Flags.Sexist, &joke.Flags.Explicit,
+		&joke.Status, &joke.RejectionReason, &joke.Revision, &joke.DeletedAt, &joke.DeletedBy,
+		&joke.RatingCount, &joke.RatingSum, &joke.AvgRating, &joke.Score, &joke.FavoriteCount, &joke.CommentCount, &joke.ReportCount, &joke.Hidden}
+
+	for i := range reactions {
+		dest = append(dest, &reactions[i])
+	}
+
+	if err := s.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	joke.Reactions = make(map[string]uint64, len(reactions))
+
+	for i, kind := range data.JokeReactionKinds {
+		joke.Reactions[kind] = reactions[i]
+	}
+
+	return nil
+}
+
+// histogramColumns hold the rating histogram of a joke, a column per bucket
+// in the order of ratingBuckets.
+const histogramColumns = "rating_1, rating_2, rating_3, rating_4, rating_5"
+
+var ratingBuckets = []string{"1", "2", "3", "4", "5"}
+
+// updateRatingAggregates recomputes the rating count, sum, histogram and score
+// of a joke from joke_ratings within tx.
+func updateRatingAggregates(ctx context.Context, tx *txn, jokeID string) error {
+	joke := data.Joke{ID: jokeID, RatingHistogram: make(map[string]uint64)}
+
+	rows, err := tx.QueryContext(ctx, "SELECT rating, COUNT(*) FROM joke_ratings WHERE joke_id = ? GROUP BY rating", jokeID)
+
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var rating float64
+		var count uint64
+
+		if err = rows.Scan(&rating, &count); err != nil {
+			rows.Close()
+			return err
+		}
+
+		joke.RatingCount += count
+		joke.RatingSum += rating * float64(count)
+		joke.RatingHistogram[data.RatingBucket(rating)] += count
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	joke.UpdateScore()
+
+	args := []interface{}{joke.RatingCount, joke.RatingSum, joke.AvgRating, joke.Score}
+
+	for _, bucket := range ratingBuckets {
+		args = append(args, joke.RatingHistogram[bucket])
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE jokes SET rating_count = ?, rating_sum = ?, avg_rating = ?, score = ?, "+
+			"rating_1 = ?, rating_2 = ?, rating_3 = ?, rating_4 = ?, rating_5 = ? WHERE id = ?",
+		append(args, jokeID)...)
+
+	return err
+}
+
 type JokeCRUD struct {
 	db *sqlx.DB
 }
@@ -19,15 +112,16 @@ func NewJokeCRUD(db *sqlx.DB) *JokeCRUD {
 	}
 }
 
-func (jr *JokeCRUD) Delete(ctx context.Context, id string) (string, error) {
-	tx, err := jr.db.BeginTx(ctx, nil)
+func (jr *JokeCRUD) Delete(ctx context.Context, id string, revision uint64, deletedBy string) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
 
 	if err != nil {
 		return "", err
 	}
 
-	row := tx.QueryRowContext(ctx, "SELECT id FROM jokes WHERE id = ?", id)
-	err = row.Scan(&id)
+	var current uint64
+	row := tx.QueryRowContext(ctx, "SELECT id, revision FROM jokes WHERE id = ? AND deleted_at IS NULL FOR UPDATE", id)
+	err = row.Scan(&id, &current)
 
 	if err != nil {
 		tx.Rollback()
@@ -39,7 +133,12 @@ func (jr *JokeCRUD) Delete(ctx context.Context, id string) (string, error) {
 		return "", err
 	}
 
-	_, err = tx.ExecContext(ctx, "DELETE FROM jokes WHERE id = ?", id)
+	if revision != current {
+		tx.Rollback()
+		return "", repositories.ErrStaleRevision
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE jokes SET deleted_at = ?, deleted_by = ? WHERE id = ?", time.Now().UTC(), deletedBy, id)
 
 	if err != nil {
 		tx.Rollback()
@@ -51,18 +150,74 @@ func (jr *JokeCRUD) Delete(ctx context.Context, id string) (string, error) {
 	return id, nil
 }
 
-func (jr *JokeCRUD) FetchAll(ctx context.Context, limit uint64, offset string, direction repositories.FetchDirection) (data.Jokes, *string, error) {
+func (jr *JokeCRUD) FetchAll(ctx context.Context, limit uint64, offset string, direction repositories.FetchDirection, filter *repositories.JokeFilter) (data.Jokes, *string, error) {
+	query := " AND deleted_at IS NULL"
+	var args []interface{}
+
+	if filter != nil && filter.Type != "" {
+		query += " AND type = ?"
+		args = append(args, filter.Type)
+	}
+
+	if filter != nil && filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+
+	if filter != nil && filter.Language != "" {
+		query += " AND lang = ?"
+		args = append(args, filter.Language)
+	}
+
+	if filter == nil || !filter.IncludeHidden {
+		query += " AND hidden = FALSE"
+	}
+
+	if filter != nil {
+		for _, flag := range filter.BlacklistFlags {
+			query += " AND flag_" + flag + " = FALSE"
+		}
+	}
+
+	if filter != nil && filter.Sort == repositories.SortScore {
+		return jr.fetchSorted(ctx, limit, offset, direction, "score", func(joke *data.Joke) float64 {
+			return joke.Score
+		}, query, args...)
+	}
+
+	if filter != nil {
+		if kind, ok := repositories.SortReactionKind(filter.Sort); ok {
+			return jr.fetchSorted(ctx, limit, offset, direction, "reaction_"+kind, func(joke *data.Joke) float64 {
+				return float64(joke.Reactions[kind])
+			}, query, args...)
+		}
+	}
+
+	return jr.fetch(ctx, limit, offset, direction, query, args...)
+}
+
+func (jr *JokeCRUD) FetchTrash(ctx context.Context, limit uint64, offset string, direction repositories.FetchDirection) (data.Jokes, *string, error) {
+	return jr.fetch(ctx, limit, offset, direction, " AND deleted_at IS NOT NULL")
+}
+
+func (jr *JokeCRUD) fetch(ctx context.Context, limit uint64, offset string, direction repositories.FetchDirection, conditions string, args ...interface{}) (data.Jokes, *string, error) {
 	var jokes data.Jokes
 
 	var condition string
+	var order string
 
 	if direction == repositories.FetchBack {
 		condition = "<"
+		order = "DESC"
 	} else {
 		condition = ">="
+		order = "ASC"
 	}
 
-	rows, err := jr.db.QueryContext(ctx, "SELECT id, author_id, text, explanation, lang FROM jokes WHERE id "+condition+" ? LIMIT ?", offset, limit+1)
+	query := "SELECT " + jokeColumns + " FROM jokes WHERE id " + condition + " ?" + conditions + " ORDER BY id " + order + " LIMIT ?"
+	args = append([]interface{}{offset}, args...)
+
+	rows, err := conn(ctx, jr.db).QueryContext(ctx, query, append(args, limit+1)...)
 
 	if err != nil {
 		return jokes, nil, err
@@ -79,7 +234,7 @@ func (jr *JokeCRUD) FetchAll(ctx context.Context, limit uint64, offset string, d
 	for i != limit && rows.Next() {
 		joke = new(data.Joke)
 
-		if err = rows.Scan(&joke.ID, &joke.AuthorID, &joke.Text, &joke.Explanation, &joke.Language); err != nil {
+		if err = scanJoke(rows, joke); err != nil {
 			return jokes, nil, err
 		}
 
@@ -92,116 +247,1391 @@ func (jr *JokeCRUD) FetchAll(ctx context.Context, limit uint64, offset string, d
 	if rows.Next() && joke != nil {
 		joke := new(data.Joke)
 
-		rows.Scan(&joke.ID, &joke.AuthorID, &joke.Text, &joke.Explanation, &joke.Language)
+		scanJoke(rows, joke)
 		nextID = &joke.ID
 	}
 
 	return jokes, nextID, nil
 }
 
-func (jr *JokeCRUD) FetchOne(ctx context.Context, id string) (*data.Joke, error) {
-	row := jr.db.QueryRowContext(ctx, "SELECT id, author_id, text, explanation, lang FROM jokes WHERE id = ?", id)
-	joke := new(data.Joke)
+func (jr *JokeCRUD) fetchSorted(ctx context.Context, limit uint64, offset string, direction repositories.FetchDirection, sortColumn string, sortValue func(joke *data.Joke) float64, conditions string, args ...interface{}) (data.Jokes, *string, error) {
+	var jokes data.Jokes
 
-	if err := row.Scan(&joke.ID, &joke.AuthorID, &joke.Text, &joke.Explanation, &joke.Language); err != nil {
+	var valueCondition, idCondition string
+	var valueOrder, idOrder string
 
-		if err == sql.ErrNoRows {
-			return nil, repositories.ErrUnknownID
+	if direction == repositories.FetchBack {
+		valueCondition, idCondition = ">", "<"
+		valueOrder, idOrder = "ASC", "DESC"
+	} else {
+		valueCondition, idCondition = "<", ">="
+		valueOrder, idOrder = "DESC", "ASC"
+	}
+
+	query := "SELECT " + jokeColumns + " FROM jokes WHERE TRUE" + conditions
+
+	if offset != "" {
+		value, id, err := repositories.DecodeSortCursor(offset)
+
+		if err != nil {
+			return jokes, nil, err
 		}
 
-		return nil, err
+		query += " AND (" + sortColumn + " " + valueCondition + " ? OR (" + sortColumn + " = ? AND id " + idCondition + " ?))"
+		args = append(args, value, value, id)
 	}
 
-	return joke, nil
+	query += " ORDER BY " + sortColumn + " " + valueOrder + ", id " + idOrder + " LIMIT ?"
+
+	rows, err := conn(ctx, jr.db).QueryContext(ctx, query, append(args, limit+1)...)
+
+	if err != nil {
+		return jokes, nil, err
+	}
+
+	defer rows.Close()
+
+	i := uint64(0)
+
+	jokes = make(data.Jokes, 0, limit)
+
+	var joke *data.Joke
+
+	for i != limit && rows.Next() {
+		joke = new(data.Joke)
+
+		if err = scanJoke(rows, joke); err != nil {
+			return jokes, nil, err
+		}
+
+		jokes = append(jokes, joke)
+		i++
+	}
+
+	var nextID *string
+
+	if rows.Next() && joke != nil {
+		joke := new(data.Joke)
+
+		scanJoke(rows, joke)
+
+		next := repositories.EncodeSortCursor(sortValue(joke), joke.ID)
+		nextID = &next
+	}
+
+	return jokes, nextID, nil
 }
 
-func (jr *JokeCRUD) Insert(ctx context.Context, joke *data.Joke) (string, error) {
-	tx, err := jr.db.BeginTx(ctx, nil)
+func (jr *JokeCRUD) Restore(ctx context.Context, id string) (string, error) {
+	result, err := conn(ctx, jr.db).ExecContext(ctx, "UPDATE jokes SET deleted_at = NULL, deleted_by = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
 
 	if err != nil {
 		return "", err
 	}
 
-	_, err = tx.ExecContext(ctx,
-		"INSERT INTO jokes (id, author_id, text, explanation, lang) VALUES (?, ?, ?, ?, ?)",
-		joke.ID, joke.AuthorID, joke.Text, joke.Explanation, joke.Language)
+	if affected, err := result.RowsAffected(); err != nil {
+		return "", err
+	} else if affected == 0 {
+		return id, repositories.ErrUnknownID
+	}
+
+	return id, nil
+}
+
+func (jr *JokeCRUD) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, table := range []string{"joke_ratings", "joke_revisions", "joke_favorites", "joke_comments", "reports", "joke_reactions"} {
+		_, err = tx.ExecContext(ctx,
+			"DELETE FROM "+table+" WHERE joke_id IN (SELECT id FROM jokes WHERE deleted_at < ?)", deletedBefore)
+
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM jokes WHERE deleted_at < ?", deletedBefore)
 
 	if err != nil {
 		tx.Rollback()
+		return 0, err
+	}
 
-		return "", err
+	purged, err := result.RowsAffected()
+
+	if err != nil {
+		tx.Rollback()
+		return 0, err
 	}
 
 	tx.Commit()
 
-	return joke.ID, nil
+	return purged, nil
 }
 
-func (jr *JokeCRUD) RateJoke(ctx context.Context, jokeID string, jokeRating *data.JokeRating) (string, error) {
-	tx, err := jr.db.BeginTx(ctx, nil)
+func (jr *JokeCRUD) FetchRatings(ctx context.Context, jokeID string, limit uint64, offset string, direction repositories.FetchDirection) (data.JokeRatings, *string, error) {
+	var ratings data.JokeRatings
 
-	if err != nil {
-		return "", err
+	row := conn(ctx, jr.db).QueryRowContext(ctx, "SELECT id FROM jokes WHERE id = ? AND deleted_at IS NULL", jokeID)
+
+	if err := row.Scan(&jokeID); err != nil {
+		if err == sql.ErrNoRows {
+			return ratings, nil, repositories.ErrUnknownID
+		}
+
+		return ratings, nil, err
 	}
 
-	row := tx.QueryRowContext(ctx, "SELECT id FROM jokes WHERE id = ?", jokeID)
-	err = row.Scan(&jokeID)
+	var condition string
+	var order string
+
+	if direction == repositories.FetchBack {
+		condition = "<"
+		order = "DESC"
+	} else {
+		condition = ">="
+		order = "ASC"
+	}
+
+	rows, err := conn(ctx, jr.db).QueryContext(ctx,
+		"SELECT id, user_id, rating, rated_at FROM joke_ratings WHERE joke_id = ? AND id "+condition+" ? ORDER BY id "+order+" LIMIT ?",
+		jokeID, offset, limit+1)
 
 	if err != nil {
-		tx.Rollback()
+		return ratings, nil, err
+	}
+
+	defer rows.Close()
+
+	i := uint64(0)
+
+	ratings = make(data.JokeRatings, 0, limit)
+
+	var rating *data.JokeRating
+
+	for i != limit && rows.Next() {
+		rating = new(data.JokeRating)
+
+		if err = rows.Scan(&rating.ID, &rating.UserID, &rating.Rating, &rating.RatedAt); err != nil {
+			return ratings, nil, err
+		}
+
+		ratings = append(ratings, rating)
+		i++
+	}
+
+	var nextID *string
+
+	if rows.Next() && rating != nil {
+		rating := new(data.JokeRating)
+
+		rows.Scan(&rating.ID, &rating.UserID, &rating.Rating, &rating.RatedAt)
+		nextID = &rating.ID
+	}
+
+	return ratings, nextID, nil
+}
+
+func (jr *JokeCRUD) FetchOne(ctx context.Context, id string) (*data.Joke, error) {
+	row := conn(ctx, jr.db).QueryRowContext(ctx, "SELECT "+jokeColumns+", "+histogramColumns+" FROM jokes WHERE id = ? AND deleted_at IS NULL", id)
+	joke := new(data.Joke)
+
+	histogram := make([]uint64, len(ratingBuckets))
+	extra := make([]interface{}, len(histogram))
+
+	for i := range histogram {
+		extra[i] = &histogram[i]
+	}
+
+	if err := scanJoke(row, joke, extra...); err != nil {
 
 		if err == sql.ErrNoRows {
-			return "", repositories.ErrUnknownID
+			return nil, repositories.ErrUnknownID
 		}
 
-		return "", err
+		return nil, err
 	}
 
-	_, err = tx.ExecContext(ctx,
-		"INSERT INTO joke_ratings (id, rating, joke_id) VALUES (?, ?, ?)",
-		jokeRating.ID, jokeRating.Rating, jokeID)
+	joke.RatingHistogram = make(map[string]uint64)
+
+	for i, bucket := range ratingBuckets {
+		if histogram[i] > 0 {
+			joke.RatingHistogram[bucket] = histogram[i]
+		}
+	}
+
+	return joke, nil
+}
+
+// FetchFirstRatings fetches the first ratings of each of the jokes, up to
+// limit, oldest first.
+func (jr *JokeCRUD) FetchFirstRatings(ctx context.Context, jokeIDs []string, limit uint64) (map[string]data.JokeRatings, error) {
+	ratings := make(map[string]data.JokeRatings, len(jokeIDs))
+
+	if len(jokeIDs) == 0 || limit == 0 {
+		return ratings, nil
+	}
+
+	query, args, err := sqlx.In("SELECT joke_id, id, user_id, rating, rated_at FROM ("+
+		"SELECT joke_id, id, user_id, rating, rated_at, ROW_NUMBER() OVER (PARTITION BY joke_id ORDER BY rated_at, id) AS n "+
+		"FROM joke_ratings WHERE joke_id IN (?)) AS ranked WHERE n <= ? ORDER BY joke_id, n", jokeIDs, limit)
 
 	if err != nil {
-		tx.Rollback()
+		return nil, err
+	}
 
-		return "", err
+	rows, err := conn(ctx, jr.db).QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
 	}
 
-	tx.Commit()
+	defer rows.Close()
 
-	return jokeRating.ID, nil
+	for rows.Next() {
+		var jokeID string
+		rating := new(data.JokeRating)
+
+		if err = rows.Scan(&jokeID, &rating.ID, &rating.UserID, &rating.Rating, &rating.RatedAt); err != nil {
+			return nil, err
+		}
+
+		ratings[jokeID] = append(ratings[jokeID], rating)
+	}
+
+	return ratings, rows.Err()
 }
 
-func (jr *JokeCRUD) Update(ctx context.Context, id string, joke *data.Joke) (string, error) {
-	tx, err := jr.db.BeginTx(ctx, nil)
+func (jr *JokeCRUD) FetchMany(ctx context.Context, ids []string) (data.Jokes, error) {
+	jokes := make(data.Jokes, 0, len(ids))
+
+	if len(ids) == 0 {
+		return jokes, nil
+	}
+
+	query, args, err := sqlx.In("SELECT "+jokeColumns+" FROM jokes WHERE id IN (?) AND deleted_at IS NULL AND hidden = FALSE", ids)
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	row := tx.QueryRowContext(ctx, "SELECT id FROM jokes WHERE id = ?", id)
-	err = row.Scan(&id)
+	rows, err := conn(ctx, jr.db).QueryContext(ctx, query, args...)
 
 	if err != nil {
-		tx.Rollback()
+		return nil, err
+	}
 
-		if err == sql.ErrNoRows {
-			return "", repositories.ErrUnknownID
+	defer rows.Close()
+
+	for rows.Next() {
+		joke := new(data.Joke)
+
+		if err = scanJoke(rows, joke); err != nil {
+			return jokes, err
 		}
 
-		return "", err
+		jokes = append(jokes, joke)
 	}
 
-	_, err = tx.ExecContext(ctx,
-		"UPDATE jokes SET author_id = ?, text = ?, explanation = ?, lang = ? WHERE id = ?",
-		joke.AuthorID, joke.Text, joke.Explanation, joke.Language, id)
+	return jokes, rows.Err()
+}
+
+func (jr *JokeCRUD) FetchLanguages(ctx context.Context) ([]string, error) {
+	rows, err := conn(ctx, jr.db).QueryContext(ctx, "SELECT DISTINCT lang FROM jokes WHERE status = ? AND deleted_at IS NULL", data.JokeStatusApproved)
 
 	if err != nil {
-		tx.Rollback()
-		return "", err
+		return nil, err
 	}
 
-	tx.Commit()
+	defer rows.Close()
 
-	return id, nil
+	languages := make([]string, 0)
+
+	for rows.Next() {
+		var language string
+
+		if err = rows.Scan(&language); err != nil {
+			return languages, err
+		}
+
+		languages = append(languages, language)
+	}
+
+	return languages, rows.Err()
+}
+
+func (jr *JokeCRUD) FetchRatingEvents(ctx context.Context, since time.Time) (data.JokeRatingEvents, error) {
+	rows, err := conn(ctx, jr.db).QueryContext(ctx,
+		"SELECT r.joke_id, j.lang, r.rating, r.rated_at FROM joke_ratings r JOIN jokes j ON j.id = r.joke_id "+
+			"WHERE r.rated_at >= ? AND j.status = ? AND j.deleted_at IS NULL",
+		since, data.JokeStatusApproved)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := make(data.JokeRatingEvents, 0)
+
+	for rows.Next() {
+		event := new(data.JokeRatingEvent)
+
+		if err = rows.Scan(&event.JokeID, &event.Language, &event.Rating, &event.RatedAt); err != nil {
+			return events, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (jr *JokeCRUD) Insert(ctx context.Context, joke *data.Joke) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	args := jokeArgs(joke)
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO jokes ("+jokeColumns+") VALUES (?"+strings.Repeat(", ?", len(args)-1)+")", args...)
+
+	if err != nil {
+		tx.Rollback()
+
+		return "", err
+	}
+
+	tx.Commit()
+
+	return joke.ID, nil
+}
+
+// InsertMany inserts the jokes in a single multi-row statement.
+func (jr *JokeCRUD) InsertMany(ctx context.Context, jokes data.Jokes) (int64, error) {
+	if len(jokes) == 0 {
+		return 0, nil
+	}
+
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var args []interface{}
+
+	rows := make([]string, len(jokes))
+
+	for i, joke := range jokes {
+		jokeArgs := jokeArgs(joke)
+		rows[i] = "(?" + strings.Repeat(", ?", len(jokeArgs)-1) + ")"
+		args = append(args, jokeArgs...)
+	}
+
+	result, err := tx.ExecContext(ctx, "INSERT INTO jokes ("+jokeColumns+") VALUES "+strings.Join(rows, ", "), args...)
+
+	if err != nil {
+		tx.Rollback()
+
+		return 0, err
+	}
+
+	tx.Commit()
+
+	return result.RowsAffected()
+}
+
+// jokeArgs returns the values of the joke's jokeColumns.
+func jokeArgs(joke *data.Joke) []interface{} {
+	args := []interface{}{joke.ID, joke.AuthorID, joke.Type, joke.Text, joke.Setup, joke.Delivery, joke.Explanation, joke.Language,
+		joke.Flags.NSFW, joke.Flags.Religious, joke.Flags.Political, joke.Flags.Racist, joke.Flags.Sexist, joke.Flags.Explicit,
+		joke.Status, joke.RejectionReason, joke.Revision, joke.DeletedAt, joke.DeletedBy,
+		joke.RatingCount, joke.RatingSum, joke.AvgRating, joke.Score, joke.FavoriteCount, joke.CommentCount, joke.ReportCount, joke.Hidden}
+
+	for _, kind := range data.JokeReactionKinds {
+		args = append(args, joke.Reactions[kind])
+	}
+
+	return args
+}
+
+func (jr *JokeCRUD) RateJoke(ctx context.Context, jokeID string, jokeRating *data.JokeRating) (string, error) {
+	if jokeRating.UserID == nil {
+		return "", repositories.ErrNoUser
+	}
+
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	row := tx.QueryRowContext(ctx, "SELECT id FROM jokes WHERE id = ? AND deleted_at IS NULL FOR UPDATE", jokeID)
+	err = row.Scan(&jokeID)
+
+	if err != nil {
+		tx.Rollback()
+
+		if err == sql.ErrNoRows {
+			return "", repositories.ErrUnknownID
+		}
+
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO joke_ratings (id, user_id, rating, rated_at, joke_id) VALUES (?, ?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE rating = VALUES(rating), rated_at = VALUES(rated_at)",
+		jokeRating.ID, jokeRating.UserID, jokeRating.Rating, jokeRating.RatedAt, jokeID)
+
+	if err != nil {
+		tx.Rollback()
+
+		return "", err
+	}
+
+	row = tx.QueryRowContext(ctx, "SELECT id FROM joke_ratings WHERE joke_id = ? AND user_id = ?", jokeID, jokeRating.UserID)
+
+	if err = row.Scan(&jokeRating.ID); err != nil {
+		tx.Rollback()
+
+		return "", err
+	}
+
+	if err = updateRatingAggregates(ctx, tx, jokeID); err != nil {
+		tx.Rollback()
+
+		return "", err
+	}
+
+	tx.Commit()
+
+	return jokeRating.ID, nil
+}
+
+func (jr *JokeCRUD) DeleteRating(ctx context.Context, jokeID string, ratingID string, authID string, admin bool) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	var userID *string
+
+	row := tx.QueryRowContext(ctx,
+		"SELECT r.user_id FROM joke_ratings r JOIN jokes j ON j.id = r.joke_id WHERE r.id = ? AND r.joke_id = ? AND j.deleted_at IS NULL FOR UPDATE",
+		ratingID, jokeID)
+	err = row.Scan(&userID)
+
+	if err != nil {
+		tx.Rollback()
+
+		if err == sql.ErrNoRows {
+			return "", repositories.ErrUnknownID
+		}
+
+		return "", err
+	}
+
+	if !admin && (userID == nil || *userID != authID) {
+		tx.Rollback()
+		return ratingID, repositories.ErrNotOwner
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM joke_ratings WHERE id = ?", ratingID)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if err = updateRatingAggregates(ctx, tx, jokeID); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	tx.Commit()
+
+	return ratingID, nil
+}
+
+func (jr *JokeCRUD) Moderate(ctx context.Context, id string, moderation *data.JokeModeration) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	row := tx.QueryRowContext(ctx, "SELECT id FROM jokes WHERE id = ? AND deleted_at IS NULL", id)
+	err = row.Scan(&id)
+
+	if err != nil {
+		tx.Rollback()
+
+		if err == sql.ErrNoRows {
+			return "", repositories.ErrUnknownID
+		}
+
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE jokes SET status = ?, rejection_reason = ? WHERE id = ?",
+		moderation.Status, moderation.Reason, id)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	tx.Commit()
+
+	return id, nil
+}
+
+func (jr *JokeCRUD) Update(ctx context.Context, id string, joke *data.Joke, editorID string) (string, error) {
+	return jr.Patch(ctx, id, joke, repositories.JokePatchFields, editorID)
+}
+
+// Patch sets only the given JSON fields of the joke, recording a revision if
+// they changed its content.
+func (jr *JokeCRUD) Patch(ctx context.Context, id string, joke *data.Joke, fields []string, editorID string) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	current := new(data.Joke)
+	row := tx.QueryRowContext(ctx, "SELECT "+jokeColumns+" FROM jokes WHERE id = ? AND deleted_at IS NULL FOR UPDATE", id)
+	err = scanJoke(row, current)
+
+	if err != nil {
+		tx.Rollback()
+
+		if err == sql.ErrNoRows {
+			return "", repositories.ErrUnknownID
+		}
+
+		return "", err
+	}
+
+	if joke.Revision != current.Revision {
+		tx.Rollback()
+		return "", repositories.ErrStaleRevision
+	}
+
+	if changes := current.Diff(joke); len(changes) > 0 {
+		revision := &data.JokeRevision{
+			JokeID:    id,
+			Revision:  current.Revision + 1,
+			EditorID:  &editorID,
+			CreatedAt: time.Now().UTC(),
+			Changes:   changes,
+		}
+
+		if err = revision.GenerateID(); err != nil {
+			tx.Rollback()
+			return "", err
+		}
+
+		changesJSON, err := json.Marshal(revision.Changes)
+
+		if err != nil {
+			tx.Rollback()
+			return "", err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO joke_revisions (id, joke_id, revision, editor_id, created_at, changes) VALUES (?, ?, ?, ?, ?, ?)",
+			revision.ID, revision.JokeID, revision.Revision, revision.EditorID, revision.CreatedAt, string(changesJSON))
+
+		if err != nil {
+			tx.Rollback()
+			return "", err
+		}
+
+		joke.Revision = revision.Revision
+	}
+
+	values := map[string][]interface{}{
+		"author_id":   {"author_id", joke.AuthorID},
+		"type":        {"type", joke.Type},
+		"text":        {"text", joke.Text},
+		"setup":       {"setup", joke.Setup},
+		"delivery":    {"delivery", joke.Delivery},
+		"explanation": {"explanation", joke.Explanation},
+		"lang":        {"lang", joke.Language},
+		"flags": {
+			"flag_nsfw", joke.Flags.NSFW, "flag_religious", joke.Flags.Religious, "flag_political", joke.Flags.Political,
+			"flag_racist", joke.Flags.Racist, "flag_sexist", joke.Flags.Sexist, "flag_explicit", joke.Flags.Explicit,
+		},
+	}
+
+	columns := []string{"revision = ?"}
+	args := []interface{}{joke.Revision}
+
+	for _, field := range fields {
+		pairs := values[field]
+
+		for i := 0; i < len(pairs); i += 2 {
+			columns = append(columns, pairs[i].(string)+" = ?")
+			args = append(args, pairs[i+1])
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE jokes SET "+strings.Join(columns, ", ")+" WHERE id = ?", append(args, id)...)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	tx.Commit()
+
+	return id, nil
+}
+
+func (jr *JokeCRUD) FetchRevisions(ctx context.Context, jokeID string) (data.JokeRevisions, error) {
+	rows, err := conn(ctx, jr.db).QueryContext(ctx,
+		"SELECT id, joke_id, revision, editor_id, created_at, changes FROM joke_revisions WHERE joke_id = ? ORDER BY revision",
+		jokeID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := make(data.JokeRevisions, 0)
+
+	for rows.Next() {
+		revision := new(data.JokeRevision)
+
+		var changesJSON string
+
+		if err = rows.Scan(&revision.ID, &revision.JokeID, &revision.Revision, &revision.EditorID, &revision.CreatedAt, &changesJSON); err != nil {
+			return revisions, err
+		}
+
+		if err = json.Unmarshal([]byte(changesJSON), &revision.Changes); err != nil {
+			return revisions, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (jr *JokeCRUD) AddFavorite(ctx context.Context, favorite *data.JokeFavorite) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	row := tx.QueryRowContext(ctx, "SELECT id FROM jokes WHERE id = ? AND deleted_at IS NULL FOR UPDATE", favorite.JokeID)
+
+	if err = row.Scan(&favorite.JokeID); err != nil {
+		tx.Rollback()
+
+		if err == sql.ErrNoRows {
+			return "", repositories.ErrUnknownID
+		}
+
+		return "", err
+	}
+
+	var existing uint64
+
+	row = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM joke_favorites WHERE joke_id = ? AND user_id = ?", favorite.JokeID, favorite.UserID)
+
+	if err = row.Scan(&existing); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if existing > 0 {
+		tx.Rollback()
+		return "", repositories.ErrDuplicateFavorite
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO joke_favorites (id, user_id, joke_id, created_at) VALUES (?, ?, ?, ?)",
+		favorite.ID, favorite.UserID, favorite.JokeID, favorite.CreatedAt)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE jokes SET favorite_count = favorite_count + 1 WHERE id = ?", favorite.JokeID); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	tx.Commit()
+
+	return favorite.ID, nil
+}
+
+func (jr *JokeCRUD) RemoveFavorite(ctx context.Context, jokeID string, userID string) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM joke_favorites WHERE joke_id = ? AND user_id = ?", jokeID, userID)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return "", err
+	} else if affected == 0 {
+		tx.Rollback()
+		return jokeID, repositories.ErrUnknownID
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE jokes SET favorite_count = favorite_count - 1 WHERE id = ?", jokeID); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	tx.Commit()
+
+	return jokeID, nil
+}
+
+func (jr *JokeCRUD) FetchFavorites(ctx context.Context, userID string, limit uint64, offset string, direction repositories.FetchDirection) (data.Jokes, *string, error) {
+	var timeCondition, idCondition string
+	var order string
+
+	if direction == repositories.FetchBack {
+		timeCondition, idCondition = "<", "<"
+		order = "DESC"
+	} else {
+		timeCondition, idCondition = ">", ">="
+		order = "ASC"
+	}
+
+	query := "SELECT id, joke_id, created_at FROM joke_favorites WHERE user_id = ?"
+	args := []interface{}{userID}
+
+	if offset != "" {
+		createdAt, id, err := repositories.DecodeTimeCursor(offset)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		query += " AND (created_at " + timeCondition + " ? OR (created_at = ? AND id " + idCondition + " ?))"
+		args = append(args, createdAt, createdAt, id)
+	}
+
+	query += " ORDER BY created_at " + order + ", id " + order + " LIMIT ?"
+
+	rows, err := conn(ctx, jr.db).QueryContext(ctx, query, append(args, limit+1)...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make([]string, 0, limit)
+
+	var nextID *string
+
+	for rows.Next() {
+		favorite := new(data.JokeFavorite)
+
+		if err = rows.Scan(&favorite.ID, &favorite.JokeID, &favorite.CreatedAt); err != nil {
+			return nil, nil, err
+		}
+
+		if uint64(len(ids)) == limit {
+			next := repositories.EncodeTimeCursor(favorite.CreatedAt, favorite.ID)
+			nextID = &next
+			break
+		}
+
+		ids = append(ids, favorite.JokeID)
+	}
+
+	found, err := jr.FetchMany(ctx, ids)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return found.InOrder(ids), nextID, nil
+}
+
+const commentColumns = "id, joke_id, parent_id, author_id, text, created_at, updated_at, removed_at, removed_by"
+
+func scanComment(s scanner, comment *data.JokeComment) error {
+	return s.Scan(&comment.ID, &comment.JokeID, &comment.ParentID, &comment.AuthorID, &comment.Text,
+		&comment.CreatedAt, &comment.UpdatedAt, &comment.RemovedAt, &comment.RemovedBy)
+}
+
+func (jr *JokeCRUD) FetchComments(ctx context.Context, jokeID string, limit uint64, offset string, direction repositories.FetchDirection) (data.JokeComments, *string, error) {
+	var timeCondition, idCondition string
+	var order string
+
+	if direction == repositories.FetchBack {
+		timeCondition, idCondition = "<", "<"
+		order = "DESC"
+	} else {
+		timeCondition, idCondition = ">", ">="
+		order = "ASC"
+	}
+
+	query := "SELECT " + commentColumns + " FROM joke_comments WHERE joke_id = ? AND parent_id IS NULL"
+	args := []interface{}{jokeID}
+
+	if offset != "" {
+		createdAt, id, err := repositories.DecodeTimeCursor(offset)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		query += " AND (created_at " + timeCondition + " ? OR (created_at = ? AND id " + idCondition + " ?))"
+		args = append(args, createdAt, createdAt, id)
+	}
+
+	query += " ORDER BY created_at " + order + ", id " + order + " LIMIT ?"
+
+	rows, err := conn(ctx, jr.db).QueryContext(ctx, query, append(args, limit+1)...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	comments := make(data.JokeComments, 0, limit)
+
+	var nextID *string
+
+	for rows.Next() {
+		comment := new(data.JokeComment)
+
+		if err = scanComment(rows, comment); err != nil {
+			return nil, nil, err
+		}
+
+		if uint64(len(comments)) == limit {
+			next := repositories.EncodeTimeCursor(comment.CreatedAt, comment.ID)
+			nextID = &next
+			break
+		}
+
+		comments = append(comments, comment)
+	}
+
+	if len(comments) == 0 {
+		return comments, nextID, nil
+	}
+
+	parentIDs := make([]string, 0, len(comments))
+
+	for _, comment := range comments {
+		parentIDs = append(parentIDs, comment.ID)
+	}
+
+	query, args, err = sqlx.In("SELECT "+commentColumns+" FROM joke_comments WHERE parent_id IN (?)", parentIDs)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replyRows, err := conn(ctx, jr.db).QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer replyRows.Close()
+
+	replies := make(data.JokeComments, 0)
+
+	for replyRows.Next() {
+		reply := new(data.JokeComment)
+
+		if err = scanComment(replyRows, reply); err != nil {
+			return nil, nil, err
+		}
+
+		replies = append(replies, reply)
+	}
+
+	comments.Thread(replies)
+
+	return comments, nextID, replyRows.Err()
+}
+
+func (jr *JokeCRUD) FetchComment(ctx context.Context, jokeID string, commentID string) (*data.JokeComment, error) {
+	row := conn(ctx, jr.db).QueryRowContext(ctx, "SELECT "+commentColumns+" FROM joke_comments WHERE id = ? AND joke_id = ?", commentID, jokeID)
+	comment := new(data.JokeComment)
+
+	if err := scanComment(row, comment); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repositories.ErrUnknownID
+		}
+
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+func (jr *JokeCRUD) InsertComment(ctx context.Context, comment *data.JokeComment) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	row := tx.QueryRowContext(ctx, "SELECT id FROM jokes WHERE id = ? AND deleted_at IS NULL FOR UPDATE", comment.JokeID)
+
+	if err = row.Scan(&comment.JokeID); err != nil {
+		tx.Rollback()
+
+		if err == sql.ErrNoRows {
+			return "", repositories.ErrUnknownID
+		}
+
+		return "", err
+	}
+
+	if comment.ParentID != nil {
+		// Replies can't be replied to, which keeps threads one level deep.
+		row = tx.QueryRowContext(ctx,
+			"SELECT id FROM joke_comments WHERE id = ? AND joke_id = ? AND parent_id IS NULL AND removed_at IS NULL",
+			*comment.ParentID, comment.JokeID)
+
+		var parentID string
+
+		if err = row.Scan(&parentID); err != nil {
+			tx.Rollback()
+
+			if err == sql.ErrNoRows {
+				return "", repositories.ErrInvalidParent
+			}
+
+			return "", err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO joke_comments ("+commentColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		comment.ID, comment.JokeID, comment.ParentID, comment.AuthorID, comment.Text,
+		comment.CreatedAt, comment.UpdatedAt, comment.RemovedAt, comment.RemovedBy)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE jokes SET comment_count = comment_count + 1 WHERE id = ?", comment.JokeID); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	tx.Commit()
+
+	return comment.ID, nil
+}
+
+func (jr *JokeCRUD) UpdateComment(ctx context.Context, comment *data.JokeComment) (string, error) {
+	result, err := conn(ctx, jr.db).ExecContext(ctx,
+		"UPDATE joke_comments SET text = ?, updated_at = ? WHERE id = ? AND joke_id = ? AND removed_at IS NULL",
+		comment.Text, comment.UpdatedAt, comment.ID, comment.JokeID)
+
+	if err != nil {
+		return "", err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return "", err
+	} else if affected == 0 {
+		return comment.ID, repositories.ErrUnknownID
+	}
+
+	return comment.ID, nil
+}
+
+// DeleteComment deletes a comment, or blanks it out like RemoveComment if it
+// has replies so that they keep their context.
+func (jr *JokeCRUD) DeleteComment(ctx context.Context, jokeID string, commentID string) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	comment := new(data.JokeComment)
+	row := tx.QueryRowContext(ctx, "SELECT "+commentColumns+" FROM joke_comments WHERE id = ? AND joke_id = ? FOR UPDATE", commentID, jokeID)
+
+	if err = scanComment(row, comment); err != nil {
+		tx.Rollback()
+
+		if err == sql.ErrNoRows {
+			return "", repositories.ErrUnknownID
+		}
+
+		return "", err
+	}
+
+	var replies int64
+
+	row = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM joke_comments WHERE joke_id = ? AND parent_id = ?", jokeID, commentID)
+
+	if err = row.Scan(&replies); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if replies == 0 {
+		_, err = tx.ExecContext(ctx, "DELETE FROM joke_comments WHERE id = ?", commentID)
+	} else if comment.RemovedAt == nil {
+		_, err = tx.ExecContext(ctx, "UPDATE joke_comments SET text = '', removed_at = ?, removed_by = ? WHERE id = ?",
+			time.Now().UTC(), comment.AuthorID, commentID)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	// Removed comments were already discounted from the joke.
+	if comment.RemovedAt == nil {
+		if _, err = tx.ExecContext(ctx, "UPDATE jokes SET comment_count = comment_count - 1 WHERE id = ?", jokeID); err != nil {
+			tx.Rollback()
+			return "", err
+		}
+	}
+
+	tx.Commit()
+
+	return commentID, nil
+}
+
+func (jr *JokeCRUD) RemoveComment(ctx context.Context, jokeID string, commentID string, removedBy string) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE joke_comments SET text = '', removed_at = ?, removed_by = ? WHERE id = ? AND joke_id = ? AND removed_at IS NULL",
+		time.Now().UTC(), removedBy, commentID, jokeID)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return "", err
+	} else if affected == 0 {
+		tx.Rollback()
+		return commentID, repositories.ErrUnknownID
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE jokes SET comment_count = comment_count - 1 WHERE id = ?", jokeID); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	tx.Commit()
+
+	return commentID, nil
+}
+
+const reportColumns = "id, target_type, joke_id, comment_id, reporter_id, reason, note, status, created_at, resolved_at, resolved_by, resolution_note"
+
+func scanReport(s scanner, report *data.Report) error {
+	return s.Scan(&report.ID, &report.TargetType, &report.JokeID, &report.CommentID, &report.ReporterID, &report.Reason,
+		&report.Note, &report.Status, &report.CreatedAt, &report.ResolvedAt, &report.ResolvedBy, &report.ResolutionNote)
+}
+
+func (jr *JokeCRUD) InsertReport(ctx context.Context, report *data.Report, hideThreshold uint64) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	var reportCount uint64
+
+	row := tx.QueryRowContext(ctx, "SELECT report_count FROM jokes WHERE id = ? AND deleted_at IS NULL FOR UPDATE", report.JokeID)
+	err = row.Scan(&reportCount)
+
+	if err == nil && report.CommentID != nil {
+		row = tx.QueryRowContext(ctx,
+			"SELECT id FROM joke_comments WHERE id = ? AND joke_id = ? AND removed_at IS NULL", *report.CommentID, report.JokeID)
+		err = row.Scan(report.CommentID)
+	}
+
+	if err != nil {
+		tx.Rollback()
+
+		if err == sql.ErrNoRows {
+			return "", repositories.ErrUnknownID
+		}
+
+		return "", err
+	}
+
+	var existing uint64
+
+	row = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM reports WHERE joke_id = ? AND comment_id <=> ? AND reporter_id = ? AND status = ?",
+		report.JokeID, report.CommentID, report.ReporterID, data.ReportStatusOpen)
+
+	if err = row.Scan(&existing); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if existing > 0 {
+		tx.Rollback()
+		return "", repositories.ErrDuplicateReport
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO reports ("+reportColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		report.ID, report.TargetType, report.JokeID, report.CommentID, report.ReporterID, report.Reason,
+		report.Note, report.Status, report.CreatedAt, report.ResolvedAt, report.ResolvedBy, report.ResolutionNote)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if report.TargetType == data.ReportTargetJoke {
+		reportCount++
+
+		_, err = tx.ExecContext(ctx, "UPDATE jokes SET report_count = ?, hidden = hidden OR ? WHERE id = ?",
+			reportCount, hideThreshold > 0 && reportCount >= hideThreshold, report.JokeID)
+
+		if err != nil {
+			tx.Rollback()
+			return "", err
+		}
+	}
+
+	tx.Commit()
+
+	return report.ID, nil
+}
+
+func (jr *JokeCRUD) FetchReports(ctx context.Context, status string, limit uint64, offset string, direction repositories.FetchDirection) (data.Reports, *string, error) {
+	var condition string
+	var order string
+
+	if direction == repositories.FetchBack {
+		condition = "<"
+		order = "DESC"
+	} else {
+		condition = ">="
+		order = "ASC"
+	}
+
+	query := "SELECT " + reportColumns + " FROM reports WHERE id " + condition + " ?"
+	args := []interface{}{offset}
+
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+
+	rows, err := conn(ctx, jr.db).QueryContext(ctx, query+" ORDER BY id "+order+" LIMIT ?", append(args, limit+1)...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	reports := make(data.Reports, 0, limit)
+
+	var nextID *string
+
+	for rows.Next() {
+		report := new(data.Report)
+
+		if err = scanReport(rows, report); err != nil {
+			return nil, nil, err
+		}
+
+		if uint64(len(reports)) == limit {
+			nextID = &report.ID
+			break
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports, nextID, nil
+}
+
+func (jr *JokeCRUD) FetchReport(ctx context.Context, id string) (*data.Report, error) {
+	row := conn(ctx, jr.db).QueryRowContext(ctx, "SELECT "+reportColumns+" FROM reports WHERE id = ?", id)
+	report := new(data.Report)
+
+	if err := scanReport(row, report); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repositories.ErrUnknownID
+		}
+
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (jr *JokeCRUD) ResolveReports(ctx context.Context, report *data.Report, resolution *data.ReportResolution, resolvedBy string) (int64, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE reports SET status = ?, resolved_at = ?, resolved_by = ?, resolution_note = ? "+
+			"WHERE joke_id = ? AND comment_id <=> ? AND status = ?",
+		resolution.Status, time.Now().UTC(), resolvedBy, resolution.Note,
+		report.JokeID, report.CommentID, data.ReportStatusOpen)
+
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	resolved, err := result.RowsAffected()
+
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if report.TargetType == data.ReportTargetJoke {
+		if _, err = tx.ExecContext(ctx, "UPDATE jokes SET report_count = 0, hidden = FALSE WHERE id = ?", report.JokeID); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	tx.Commit()
+
+	return resolved, nil
+}
+
+func (jr *JokeCRUD) AddReaction(ctx context.Context, reaction *data.JokeReaction) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	row := tx.QueryRowContext(ctx, "SELECT id FROM jokes WHERE id = ? AND deleted_at IS NULL FOR UPDATE", reaction.JokeID)
+
+	if err = row.Scan(&reaction.JokeID); err != nil {
+		tx.Rollback()
+
+		if err == sql.ErrNoRows {
+			return "", repositories.ErrUnknownID
+		}
+
+		return "", err
+	}
+
+	var existing uint64
+
+	row = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM joke_reactions WHERE joke_id = ? AND user_id = ? AND kind = ?",
+		reaction.JokeID, reaction.UserID, reaction.Kind)
+
+	if err = row.Scan(&existing); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if existing > 0 {
+		tx.Rollback()
+		return "", repositories.ErrDuplicateReaction
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO joke_reactions (joke_id, user_id, kind, created_at) VALUES (?, ?, ?, ?)",
+		reaction.JokeID, reaction.UserID, reaction.Kind, reaction.CreatedAt)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	// kind is one of data.JokeReactionKinds, checked by the handler.
+	_, err = tx.ExecContext(ctx, "UPDATE jokes SET reaction_"+reaction.Kind+" = reaction_"+reaction.Kind+" + 1 WHERE id = ?", reaction.JokeID)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	tx.Commit()
+
+	return reaction.Kind, nil
+}
+
+func (jr *JokeCRUD) RemoveReaction(ctx context.Context, jokeID string, userID string, kind string) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM joke_reactions WHERE joke_id = ? AND user_id = ? AND kind = ?", jokeID, userID, kind)
+
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		tx.Rollback()
+		return "", err
+	} else if affected == 0 {
+		tx.Rollback()
+		return kind, repositories.ErrUnknownID
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE jokes SET reaction_"+kind+" = reaction_"+kind+" - 1 WHERE id = ?", jokeID); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	tx.Commit()
+
+	return kind, nil
 }
@@ -1 +1,91 @@
 package sql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type txKey struct{}
+
+// Transactor runs repository calls in a single transaction.
+type Transactor struct {
+	db *sqlx.DB
+}
+
+func NewTransactor(db *sqlx.DB) *Transactor {
+	return &Transactor{db}
+}
+
+func (t *Transactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// txn is a transaction begun by a repository method. Within the transaction
+// of a Transactor it's that one, and leaves committing and rolling back to it.
+type txn struct {
+	*sql.Tx
+	outer bool
+}
+
+func (t *txn) Commit() error {
+	if t.outer {
+		return nil
+	}
+
+	return t.Tx.Commit()
+}
+
+func (t *txn) Rollback() error {
+	if t.outer {
+		return nil
+	}
+
+	return t.Tx.Rollback()
+}
+
+func beginTx(ctx context.Context, db *sqlx.DB) (*txn, error) {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return &txn{tx, true}, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &txn{Tx: tx}, nil
+}
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn returns the transaction of ctx if any, so that reads see its writes,
+// or else db.
+func conn(ctx context.Context, db *sqlx.DB) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return db
+}
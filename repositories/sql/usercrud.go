@@ -3,6 +3,8 @@ package sql
 import (
 	"context"
 	"database/sql"
+	"strings"
+	"time"
 
 	"github.com/davq23/jokeapi/data"
 	"github.com/davq23/jokeapi/repositories"
@@ -20,17 +22,30 @@ func NewUserCRUD(db *sqlx.DB) *UserCRUD {
 }
 
 func (jr *UserCRUD) FetchAll(ctx context.Context, limit uint64, offset string, direction repositories.FetchDirection) (data.Users, *string, error) {
+	return jr.fetch(ctx, limit, offset, direction, " AND deleted_at IS NULL")
+}
+
+func (jr *UserCRUD) FetchTrash(ctx context.Context, limit uint64, offset string, direction repositories.FetchDirection) (data.Users, *string, error) {
+	return jr.fetch(ctx, limit, offset, direction, " AND deleted_at IS NOT NULL")
+}
+
+func (jr *UserCRUD) fetch(ctx context.Context, limit uint64, offset string, direction repositories.FetchDirection, conditions string) (data.Users, *string, error) {
 	var users data.Users
 
 	var condition string
+	var order string
 
 	if direction == repositories.FetchBack {
 		condition = "<"
+		order = "DESC"
 	} else {
 		condition = ">="
+		order = "ASC"
 	}
 
-	rows, err := jr.db.QueryContext(ctx, "SELECT id, author_id, text, explanation FROM users WHERE id "+condition+" $1 LIMIT $2", offset, limit)
+	rows, err := conn(ctx, jr.db).QueryContext(ctx,
+		"SELECT id, email, admin, deleted_at, deleted_by FROM users WHERE id "+condition+" ?"+conditions+" ORDER BY id "+order+" LIMIT ?",
+		offset, limit+1)
 
 	if err != nil {
 		return users, nil, repositories.ErrInvalidOffset
@@ -47,7 +62,7 @@ func (jr *UserCRUD) FetchAll(ctx context.Context, limit uint64, offset string, d
 	for i != limit && rows.Next() {
 		user = new(data.User)
 
-		if err = rows.Scan(user); err != nil {
+		if err = rows.Scan(&user.ID, &user.Email, &user.Admin, &user.DeletedAt, &user.DeletedBy); err != nil {
 			return users, nil, err
 		}
 
@@ -58,6 +73,9 @@ func (jr *UserCRUD) FetchAll(ctx context.Context, limit uint64, offset string, d
 	var nextID *string
 
 	if rows.Next() && user != nil {
+		user := new(data.User)
+
+		rows.Scan(&user.ID, &user.Email, &user.Admin, &user.DeletedAt, &user.DeletedBy)
 		nextID = &user.ID
 	}
 
@@ -65,7 +83,7 @@ func (jr *UserCRUD) FetchAll(ctx context.Context, limit uint64, offset string, d
 }
 
 func (jr *UserCRUD) FetchOne(ctx context.Context, id string) (*data.User, error) {
-	result := jr.db.QueryRowContext(ctx, "SELECT id, email, admin FROM users WHERE id = $1", id)
+	result := conn(ctx, jr.db).QueryRowContext(ctx, "SELECT id, email, admin FROM users WHERE id = $1 AND deleted_at IS NULL", id)
 	user := new(data.User)
 
 	if err := result.Scan(&user.ID, &user.Email, &user.Admin); err != nil {
@@ -79,8 +97,44 @@ func (jr *UserCRUD) FetchOne(ctx context.Context, id string) (*data.User, error)
 	return user, nil
 }
 
+// FetchMany fetches the users of the ids that aren't in the trash, in no
+// particular order.
+func (jr *UserCRUD) FetchMany(ctx context.Context, ids []string) (data.Users, error) {
+	users := make(data.Users, 0, len(ids))
+
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	query, args, err := sqlx.In("SELECT id, email, admin FROM users WHERE id IN (?) AND deleted_at IS NULL", ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, jr.db).QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		user := new(data.User)
+
+		if err = rows.Scan(&user.ID, &user.Email, &user.Admin); err != nil {
+			return users, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
 func (jr *UserCRUD) Insert(ctx context.Context, user *data.User) (string, error) {
-	tx, err := jr.db.BeginTx(ctx, nil)
+	tx, err := beginTx(ctx, jr.db)
 
 	if err != nil {
 		return "", err
@@ -102,13 +156,13 @@ func (jr *UserCRUD) Insert(ctx context.Context, user *data.User) (string, error)
 }
 
 func (jr *UserCRUD) Update(ctx context.Context, id string, user *data.User) (string, error) {
-	tx, err := jr.db.BeginTx(ctx, nil)
+	tx, err := beginTx(ctx, jr.db)
 
 	if err != nil {
 		return "", err
 	}
 
-	row := tx.QueryRowContext(ctx, "SELECT id FROM jokes WHERE id = ?", id)
+	row := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = ? AND deleted_at IS NULL", id)
 	err = row.Scan(&id)
 
 	if err != nil {
@@ -135,14 +189,15 @@ func (jr *UserCRUD) Update(ctx context.Context, id string, user *data.User) (str
 	return id, nil
 }
 
-func (jr *UserCRUD) Delete(ctx context.Context, id string) (string, error) {
-	tx, err := jr.db.BeginTx(ctx, nil)
+// Patch sets only the given JSON fields of the user.
+func (jr *UserCRUD) Patch(ctx context.Context, id string, user *data.User, fields []string) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
 
 	if err != nil {
 		return "", err
 	}
 
-	row := tx.QueryRowContext(ctx, "SELECT id FROM jokes WHERE id = ?", id)
+	row := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = ? AND deleted_at IS NULL", id)
 	err = row.Scan(&id)
 
 	if err != nil {
@@ -155,7 +210,52 @@ func (jr *UserCRUD) Delete(ctx context.Context, id string) (string, error) {
 		return "", err
 	}
 
-	_, err = jr.db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+	values := map[string]interface{}{"email": user.Email, "password": user.Password, "admin": user.Admin}
+	columns := []string{}
+	args := []interface{}{}
+
+	for _, field := range fields {
+		if value, ok := values[field]; ok {
+			columns = append(columns, field+" = ?")
+			args = append(args, value)
+		}
+	}
+
+	if len(columns) > 0 {
+		_, err = tx.ExecContext(ctx, "UPDATE users SET "+strings.Join(columns, ", ")+" WHERE id = ?", append(args, id)...)
+
+		if err != nil {
+			tx.Rollback()
+			return "", err
+		}
+	}
+
+	tx.Commit()
+
+	return id, nil
+}
+
+func (jr *UserCRUD) Delete(ctx context.Context, id string, deletedBy string) (string, error) {
+	tx, err := beginTx(ctx, jr.db)
+
+	if err != nil {
+		return "", err
+	}
+
+	row := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = ? AND deleted_at IS NULL", id)
+	err = row.Scan(&id)
+
+	if err != nil {
+		tx.Rollback()
+
+		if err == sql.ErrNoRows {
+			return "", repositories.ErrUnknownID
+		}
+
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE users SET deleted_at = ?, deleted_by = ? WHERE id = ?", time.Now().UTC(), deletedBy, id)
 
 	if err != nil {
 		tx.Rollback()
@@ -167,3 +267,29 @@ func (jr *UserCRUD) Delete(ctx context.Context, id string) (string, error) {
 
 	return id, nil
 }
+
+func (jr *UserCRUD) Restore(ctx context.Context, id string) (string, error) {
+	result, err := conn(ctx, jr.db).ExecContext(ctx, "UPDATE users SET deleted_at = NULL, deleted_by = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+
+	if err != nil {
+		return "", err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return "", err
+	} else if affected == 0 {
+		return id, repositories.ErrUnknownID
+	}
+
+	return id, nil
+}
+
+func (jr *UserCRUD) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := conn(ctx, jr.db).ExecContext(ctx, "DELETE FROM users WHERE deleted_at < ?", deletedBefore)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -2,15 +2,24 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/davq23/jokeapi/data"
 )
 
+// UserPatchFields are the JSON fields of a user Patch writes.
+var UserPatchFields = []string{"email", "password", "admin"}
+
 type UserCRUD interface {
-	Delete(ctx context.Context, id string) (string, error)
+	Delete(ctx context.Context, id string, deletedBy string) (string, error)
+	FetchTrash(ctx context.Context, limit uint64, offset string, direction FetchDirection) (data.Users, *string, error)
+	Restore(ctx context.Context, id string) (string, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
 	FetchAll(ctx context.Context, limit uint64, offset string, direction FetchDirection) (data.Users, *string, error)
 	FetchOne(ctx context.Context, id string) (*data.User, error)
+	FetchMany(ctx context.Context, ids []string) (data.Users, error)
 	FetchOneByEmail(ctx context.Context, email string) (*data.User, error)
 	Insert(ctx context.Context, user *data.User) (string, error)
 	Update(ctx context.Context, id string, user *data.User) (string, error)
+	Patch(ctx context.Context, id string, user *data.User, fields []string) (string, error)
 }
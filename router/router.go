@@ -0,0 +1,214 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ParamsKey is the context key under which the path params of the matched
+// route are stored.
+type ParamsKey struct{}
+
+// Params maps the names of a route's {params} to the path segments they
+// matched.
+type Params map[string]string
+
+// Param returns the value of the named path param, or "" if the matched
+// route has no such param.
+func Param(r *http.Request, name string) string {
+	params, ok := r.Context().Value(ParamsKey{}).(Params)
+
+	if !ok {
+		return ""
+	}
+
+	return params[name]
+}
+
+type route struct {
+	method   string
+	pattern  string
+	segments []string
+	handler  http.Handler
+}
+
+// RouteInfo describes a registered route.
+type RouteInfo struct {
+	Method  string
+	Pattern string
+}
+
+// Router dispatches requests by method and path pattern. Patterns are made
+// of literal segments and {name} params matching exactly one non-empty
+// segment, e.g. /jokes/{id}/ratings/{ratingID}. Trailing slashes are
+// ignored.
+//
+// When several patterns match a path, the one with a literal segment at the
+// first position where they differ wins, so /jokes/top takes precedence over
+// /jokes/{id} regardless of registration order.
+type Router struct {
+	routes []*route
+
+	// NotFound and MethodNotAllowed reply when no route matches the path,
+	// or the method. They default to plain text errors.
+	NotFound         http.HandlerFunc
+	MethodNotAllowed http.HandlerFunc
+}
+
+func New() *Router {
+	return &Router{}
+}
+
+func (rt *Router) Handle(method string, pattern string, handler http.Handler) {
+	segments := splitPath(pattern)
+
+	rt.routes = append(rt.routes, &route{
+		method:   method,
+		pattern:  "/" + strings.Join(segments, "/"),
+		segments: segments,
+		handler:  handler,
+	})
+}
+
+func (rt *Router) HandleFunc(method string, pattern string, handler http.HandlerFunc) {
+	rt.Handle(method, pattern, handler)
+}
+
+// Mount adds the routes registered so far on sub under prefix, wrapping
+// their handlers with wrap unless it's nil.
+func (rt *Router) Mount(prefix string, sub *Router, wrap func(http.Handler) http.Handler) {
+	for _, rte := range sub.routes {
+		handler := rte.handler
+
+		if wrap != nil {
+			handler = wrap(handler)
+		}
+
+		segments := append(splitPath(prefix), rte.segments...)
+
+		rt.routes = append(rt.routes, &route{
+			method:   rte.method,
+			pattern:  "/" + strings.Join(segments, "/"),
+			segments: segments,
+			handler:  handler,
+		})
+	}
+}
+
+// Routes returns the registered routes in registration order.
+func (rt *Router) Routes() []RouteInfo {
+	routes := make([]RouteInfo, len(rt.routes))
+
+	for i, rte := range rt.routes {
+		routes[i] = RouteInfo{Method: rte.method, Pattern: rte.pattern}
+	}
+
+	return routes
+}
+
+// ServeHTTP calls the handler of the route best matching the request. If the
+// path matches but the method doesn't, it replies 405 with an Allow header,
+// or 204 with the same header to OPTIONS requests.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path)
+
+	var best []*route
+	var bestParams []Params
+	var bestRank string
+
+	for _, rte := range rt.routes {
+		params, rank, ok := rte.match(segments)
+
+		if !ok || best != nil && rank < bestRank {
+			continue
+		}
+
+		if best == nil || rank > bestRank {
+			best, bestParams, bestRank = nil, nil, rank
+		}
+
+		best = append(best, rte)
+		bestParams = append(bestParams, params)
+	}
+
+	if best == nil {
+		if rt.NotFound != nil {
+			rt.NotFound(w, r)
+		} else {
+			http.NotFound(w, r)
+		}
+
+		return
+	}
+
+	allowed := make([]string, 0, len(best))
+
+	for i, rte := range best {
+		if rte.method == r.Method {
+			ctx := context.WithValue(r.Context(), ParamsKey{}, bestParams[i])
+			rte.handler.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		allowed = append(allowed, rte.method)
+	}
+
+	sort.Strings(allowed)
+
+	w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if rt.MethodNotAllowed != nil {
+		rt.MethodNotAllowed(w, r)
+	} else {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// match reports whether the route matches the path segments, along with the
+// params it captured and a rank with a '1' for every literal segment and a
+// '0' for every param, for comparing matches of the same path.
+func (rte *route) match(segments []string) (Params, string, bool) {
+	if len(segments) != len(rte.segments) {
+		return nil, "", false
+	}
+
+	params := Params{}
+	rank := make([]byte, len(segments))
+
+	for i, segment := range rte.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segments[i] == "" {
+				return nil, "", false
+			}
+
+			params[segment[1:len(segment)-1]] = segments[i]
+			rank[i] = '0'
+			continue
+		}
+
+		if segment != segments[i] {
+			return nil, "", false
+		}
+
+		rank[i] = '1'
+	}
+
+	return params, string(rank), true
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+
+	if path == "" {
+		return []string{}
+	}
+
+	return strings.Split(path, "/")
+}
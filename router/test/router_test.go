@@ -0,0 +1,149 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/davq23/jokeapi/router"
+)
+
+// named replies with its name and the path params it was given.
+func named(name string, params ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name))
+
+		for _, param := range params {
+			w.Write([]byte(" " + param + "=" + router.Param(r, param)))
+		}
+	}
+}
+
+func serve(h http.Handler, method string, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+
+	return w
+}
+
+func TestRouting(t *testing.T) {
+	rt := router.New()
+
+	// Registered before the literal routes they'd otherwise shadow.
+	rt.HandleFunc(http.MethodGet, "/jokes/{id}", named("joke", "id"))
+	rt.HandleFunc(http.MethodGet, "/jokes/{id}/ratings/{ratingID}", named("rating", "id", "ratingID"))
+	rt.HandleFunc(http.MethodGet, "/jokes/top", named("top"))
+	rt.HandleFunc(http.MethodGet, "/jokes/{id}/ratings/latest", named("latest", "id"))
+	rt.HandleFunc(http.MethodGet, "/jokes", named("jokes"))
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/jokes", http.StatusOK, "jokes"},
+		{"/jokes/", http.StatusOK, "jokes"},
+		{"/jokes/top", http.StatusOK, "top"},
+		{"/jokes/42", http.StatusOK, "joke id=42"},
+		{"/jokes/42/", http.StatusOK, "joke id=42"},
+		{"/jokes/42/ratings/7", http.StatusOK, "rating id=42 ratingID=7"},
+		{"/jokes/42/ratings/latest", http.StatusOK, "latest id=42"},
+		{"/jokes//ratings/7", http.StatusNotFound, ""},
+		{"/jokes/42/ratings", http.StatusNotFound, ""},
+		{"/users", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		w := serve(rt, http.MethodGet, test.target)
+
+		if w.Code != test.status || test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s: got %d %q, want %d %q", test.target, w.Code, w.Body.String(), test.status, test.body)
+		}
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	rt := router.New()
+	rt.HandleFunc(http.MethodGet, "/jokes/{id}", named("get"))
+	rt.HandleFunc(http.MethodPut, "/jokes/{id}", named("put"))
+	rt.HandleFunc(http.MethodDelete, "/jokes/{id}", named("delete"))
+	rt.HandleFunc(http.MethodPost, "/jokes/top", named("top"))
+
+	w := serve(rt, http.MethodPatch, "/jokes/42")
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got %d, want 405", w.Code)
+	}
+
+	// The literal /jokes/top doesn't match /jokes/42, so POST isn't allowed.
+	if allow := w.Header().Get("Allow"); allow != "DELETE, GET, PUT, OPTIONS" {
+		t.Errorf("got Allow %q", allow)
+	}
+
+	w = serve(rt, http.MethodOptions, "/jokes/top")
+
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "POST, OPTIONS" {
+		t.Errorf("OPTIONS: got %d with Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	rt.MethodNotAllowed = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+
+	if w = serve(rt, http.MethodGet, "/jokes/top"); w.Code != http.StatusTeapot {
+		t.Errorf("got %d, want the custom MethodNotAllowed", w.Code)
+	}
+}
+
+func TestMount(t *testing.T) {
+	api := router.New()
+	api.HandleFunc(http.MethodGet, "/jokes/{id}", named("joke", "id"))
+	api.HandleFunc(http.MethodGet, "/jokes/top", named("top"))
+
+	rt := router.New()
+	rt.HandleFunc(http.MethodGet, "/openapi.json", named("spec"))
+	rt.Mount("/v1/", api, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("v1 "))
+			next.ServeHTTP(w, r)
+		})
+	})
+	rt.Mount("/", api, nil)
+
+	// Routes registered after mounting aren't mounted.
+	api.HandleFunc(http.MethodGet, "/users", named("users"))
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/v1/jokes/42", http.StatusOK, "v1 joke id=42"},
+		{"/v1/jokes/top", http.StatusOK, "v1 top"},
+		{"/jokes/42", http.StatusOK, "joke id=42"},
+		{"/openapi.json", http.StatusOK, "spec"},
+		{"/v1/openapi.json", http.StatusNotFound, ""},
+		{"/v1/users", http.StatusNotFound, ""},
+		{"/v2/jokes/42", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		w := serve(rt, http.MethodGet, test.target)
+
+		if w.Code != test.status || test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s: got %d %q, want %d %q", test.target, w.Code, w.Body.String(), test.status, test.body)
+		}
+	}
+
+	patterns := map[string]bool{}
+
+	for _, route := range rt.Routes() {
+		patterns[route.Pattern] = true
+	}
+
+	for _, pattern := range []string{"/v1/jokes/{id}", "/v1/jokes/top", "/jokes/{id}", "/openapi.json"} {
+		if !patterns[pattern] {
+			t.Errorf("%s is missing from the routes", pattern)
+		}
+	}
+}